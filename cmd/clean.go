@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/models"
+	"littlevsx/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cleanKeep           int
+	cleanKeepPreRelease bool
+	cleanDryRun         bool
+)
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Removes old extension versions, keeping the newest N per extension",
+	Long: `Removes old .vsix packages from the extensions directory, keeping the newest
+N versions (by semantic version) of every extension. The builds of a
+version for different target platforms count as one version and are kept
+or removed together.
+
+The latest version registered in the database is never removed, and pinned
+extensions (see pin) are skipped entirely. Older versions registered in the
+database are removed from it together with their file.
+
+Examples:
+  littlevsx clean --keep 3
+  littlevsx clean --keep 1 --keep-pre-release --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runClean()
+	},
+}
+
+func init() {
+	cleanCmd.Flags().IntVar(&cleanKeep, "keep", 3, "Number of newest versions to keep per extension")
+	cleanCmd.Flags().BoolVar(&cleanKeepPreRelease, "keep-pre-release", false, "Never remove pre-release versions")
+	cleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "Only print what would be removed")
+	rootCmd.AddCommand(cleanCmd)
+}
+
+func runClean() error {
+	if cleanKeep < 1 {
+		return fmt.Errorf("--keep must be at least 1")
+	}
+
+	config := config.GetConfig()
+
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	files, err := extManager.ListVSIXFiles(config.ExtensionsDir, true)
+	if err != nil {
+		return fmt.Errorf("error listing extensions directory: %w", err)
+	}
+
+	versionsByID := make(map[string][]*models.Extension)
+	for _, file := range files {
+		ext, err := extManager.ReadExtensionInfo(file)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping %s: %v\n", file, err)
+			continue
+		}
+		versionsByID[ext.ID] = append(versionsByID[ext.ID], ext)
+	}
+
+	ids := make([]string, 0, len(versionsByID))
+	for id := range versionsByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var removed int
+	var freed int64
+
+	for _, id := range ids {
+		builds := versionsByID[id]
+		sort.SliceStable(builds, func(i, j int) bool {
+			return utils.CompareVersions(builds[i].Version, builds[j].Version) > 0
+		})
+
+		currentVersion := ""
+		if current, exists := extManager.GetByID(id); exists {
+			if current.Pinned {
+				if countVersions(builds) > cleanKeep {
+					fmt.Printf("📌 Keeping all versions of %s: it is pinned\n", id)
+				}
+				continue
+			}
+			currentVersion = current.Version
+		}
+
+		versions, previous := 0, ""
+		for _, ext := range builds {
+			if versions == 0 || ext.Version != previous {
+				versions++
+				previous = ext.Version
+			}
+			if versions <= cleanKeep {
+				continue
+			}
+			if cleanKeepPreRelease && ext.PreRelease {
+				continue
+			}
+			if ext.Version == currentVersion {
+				fmt.Printf("ℹ️  Keeping %s %s (%s): it is the latest version in the database\n", id, ext.Version, ext.TargetPlatform)
+				continue
+			}
+			registered, isRegistered := extManager.GetBuild(id, ext.Version, ext.TargetPlatform)
+			isRegistered = isRegistered && filepath.Clean(registered.FilePath) == filepath.Clean(ext.FilePath)
+
+			if cleanDryRun {
+				fmt.Printf("Would remove %s %s (%s): %s\n", id, ext.Version, ext.TargetPlatform, ext.FilePath)
+			} else {
+				if err := os.Remove(ext.FilePath); err != nil {
+					fmt.Printf("❌ Failed to remove %s: %v\n", ext.FilePath, err)
+					continue
+				}
+				if isRegistered {
+					if err := extManager.GetDB().DeleteExtensionVersion(id, ext.Version, ext.TargetPlatform); err != nil {
+						fmt.Printf("⚠️  Removed %s but failed to remove %s %s from the database: %v\n", ext.FilePath, id, ext.Version, err)
+					}
+				}
+				fmt.Printf("🗑️  Removed %s %s (%s): %s\n", id, ext.Version, ext.TargetPlatform, ext.FilePath)
+			}
+
+			removed++
+			freed += ext.FileSize
+		}
+	}
+
+	if cleanDryRun {
+		fmt.Printf("\nDry run: %d files (%d bytes) would be removed\n", removed, freed)
+	} else {
+		fmt.Printf("\n✅ Removed %d files, freed %d bytes\n", removed, freed)
+	}
+	return nil
+}
+
+// countVersions returns the number of distinct versions among builds sorted
+// by version.
+func countVersions(builds []*models.Extension) int {
+	count := 0
+	for i, ext := range builds {
+		if i == 0 || ext.Version != builds[i-1].Version {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"littlevsx/internal/config"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configFormat string
+
+// redactedKeyParts marks settings whose values are secrets. Paths to key
+// files are not secret and are shown.
+var redactedKeyParts = []string{"token", "password", "secret"}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspects the configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Prints the effective configuration",
+	Long: `Prints the configuration the other commands use, after applying defaults,
+the config file and environment variables. Secrets such as tokens are redacted.
+
+Examples:
+  littlevsx config show
+  littlevsx config show --format json --config /etc/littlevsx/config.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runConfigShow()
+	},
+}
+
+func init() {
+	configShowCmd.Flags().StringVar(&configFormat, "format", "yaml", "Output format: yaml, json")
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigShow() error {
+	settings, err := effectiveSettings(config.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	switch configFormat {
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(settings); err != nil {
+			return fmt.Errorf("error encoding configuration: %w", err)
+		}
+		return encoder.Close()
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(settings)
+	default:
+		return fmt.Errorf("unknown format %q, use yaml or json", configFormat)
+	}
+}
+
+// effectiveSettings nests the resolved settings by section, in the layout of
+// config.yaml, so the output can be copied into a config file.
+func effectiveSettings(cfg config.Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding configuration: %w", err)
+	}
+
+	var flat map[string]interface{}
+	if err := json.Unmarshal(data, &flat); err != nil {
+		return nil, fmt.Errorf("error encoding configuration: %w", err)
+	}
+
+	settings := make(map[string]interface{})
+	for key, value := range flat {
+		parts := strings.Split(key, ".")
+		name := parts[len(parts)-1]
+
+		section := settings
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := section[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				section[part] = child
+			}
+			section = child
+		}
+
+		if isSecretKey(name) && value != "" {
+			value = "<redacted>"
+		}
+		section[name] = value
+	}
+	return settings, nil
+}
+
+func isSecretKey(name string) bool {
+	if strings.HasSuffix(name, "_file") {
+		return false
+	}
+	for _, part := range redactedKeyParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/database"
+	"littlevsx/internal/extensions"
+
+	"github.com/spf13/cobra"
+)
+
+const dumpPageSize = 500
+
+var dumpDBCmd = &cobra.Command{
+	Use:   "dump-db",
+	Short: "Writes the metadata of all extensions as JSON lines to stdout",
+	Long: `Writes one JSON object per extension version to stdout. Only database
+metadata is written, not the .vsix files. Restore it with load-db.
+
+Example:
+  littlevsx dump-db > catalog.jsonl`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runDumpDB(os.Stdout)
+	},
+}
+
+var loadDBCmd = &cobra.Command{
+	Use:   "load-db",
+	Short: "Adds or updates extensions from JSON lines read from stdin",
+	Long: `Reads the output of dump-db from stdin and upserts every extension.
+The .vsix files do not need to be present; entries whose file is missing are
+marked as metadata-only.
+
+Example:
+  littlevsx load-db < catalog.jsonl`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runLoadDB(os.Stdin)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dumpDBCmd)
+	rootCmd.AddCommand(loadDBCmd)
+}
+
+func runDumpDB(out io.Writer) error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	encoder := json.NewEncoder(out)
+	count := 0
+	for page := 1; ; page++ {
+		exts, err := extManager.GetDB().GetAllExtensionVersions(page, dumpPageSize)
+		if err != nil {
+			return fmt.Errorf("error reading extensions: %w", err)
+		}
+
+		for i := range exts {
+			if err := encoder.Encode(&exts[i]); err != nil {
+				return fmt.Errorf("error writing %s: %w", exts[i].ID, err)
+			}
+		}
+		count += len(exts)
+
+		if len(exts) < dumpPageSize {
+			break
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "✅ Dumped %d extension versions\n", count)
+	return nil
+}
+
+func runLoadDB(in io.Reader) error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	cfg := config.GetConfig()
+	db := extManager.GetDB()
+	decoder := json.NewDecoder(in)
+	loaded, metadataOnly, rejected := 0, 0, 0
+
+	for line := 1; ; line++ {
+		var ext database.ExtensionDB
+		if err := decoder.Decode(&ext); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("error parsing entry %d: %w", line, err)
+		}
+
+		if ext.ID == "" {
+			return fmt.Errorf("entry %d has no id", line)
+		}
+
+		if err := cfg.CheckPolicy(ext.ID); err != nil {
+			fmt.Printf("⚠️  Skipping %s: %v\n", ext.ID, err)
+			rejected++
+			continue
+		}
+		if err := cfg.CheckEngine(ext.ID, database.ToExtension(&ext).Engines.VSCode); err != nil {
+			fmt.Printf("⚠️  Skipping %s: %v\n", ext.ID, err)
+			rejected++
+			continue
+		}
+
+		_, statErr := extManager.Storage().Stat(ext.FilePath)
+		ext.MetadataOnly = ext.FilePath == "" || statErr != nil
+		if ext.MetadataOnly {
+			metadataOnly++
+		}
+
+		if err := db.UpsertExtension(&ext); err != nil {
+			return fmt.Errorf("error saving %s: %w", ext.ID, err)
+		}
+		if ext.Featured {
+			if _, err := db.SetFeatured(ext.ID, true); err != nil {
+				return fmt.Errorf("error saving %s: %w", ext.ID, err)
+			}
+		}
+		if ext.Pinned {
+			if _, err := db.SetPinned(ext.ID, true); err != nil {
+				return fmt.Errorf("error saving %s: %w", ext.ID, err)
+			}
+		}
+		loaded++
+	}
+
+	fmt.Printf("✅ Loaded %d extensions (%d without a local .vsix file)\n", loaded, metadataOnly)
+	if rejected > 0 {
+		fmt.Printf("⚠️  Skipped %d extensions rejected by policy\n", rejected)
+	}
+	return nil
+}
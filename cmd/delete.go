@@ -10,7 +10,7 @@ import (
 
 var deleteCmd = &cobra.Command{
 	Use:   "delete [EXTENSION_ID]",
-	Short: "Deletes an extension from the database and all associated files",
+	Short: "Deletes an extension, all its versions and all associated files",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
@@ -40,6 +40,9 @@ func runDelete(extensionID string) error {
 	fmt.Printf("  Publisher: %s\n", ext.Publisher)
 	fmt.Printf("  Version: %s\n", ext.Version)
 	fmt.Printf("  File: %s\n", ext.FilePath)
+	if builds := extManager.GetVersions(extensionID); len(builds) > 1 {
+		fmt.Printf("  Other versions and platform builds: %d\n", len(builds)-1)
+	}
 
 	fmt.Printf("\n⚠️  WARNING: This action will permanently delete the extension and all associated files!\n")
 	fmt.Printf("Continue with deletion? (y/N): ")
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/models"
+
+	"github.com/spf13/cobra"
+)
+
+var readmeCmd = &cobra.Command{
+	Use:   "readme EXTENSION_ID",
+	Short: "Prints the README of an extension",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPrintDocument(args[0], "README", (*extensions.Manager).ReadReadme)
+	},
+}
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog EXTENSION_ID",
+	Short: "Prints the CHANGELOG of an extension",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPrintDocument(args[0], "CHANGELOG", (*extensions.Manager).ReadChangelog)
+	},
+}
+
+var licenseCmd = &cobra.Command{
+	Use:   "license EXTENSION_ID",
+	Short: "Prints the LICENSE of an extension",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPrintDocument(args[0], "LICENSE", (*extensions.Manager).ReadLicense)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(readmeCmd)
+	rootCmd.AddCommand(changelogCmd)
+	rootCmd.AddCommand(licenseCmd)
+}
+
+func runPrintDocument(extensionID, document string, read func(*extensions.Manager, *models.Extension) ([]byte, error)) error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	ext, exists := extManager.GetByID(extensionID)
+	if !exists {
+		return fmt.Errorf("extension with ID %s not found", extensionID)
+	}
+
+	content, err := read(extManager, ext)
+	if err != nil {
+		return fmt.Errorf("%s is not available for %s: %w", document, extensionID, err)
+	}
+
+	_, err = os.Stdout.Write(content)
+	return err
+}
@@ -1,42 +1,106 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
 
 	"littlevsx/internal/config"
 	"littlevsx/internal/database"
 	"littlevsx/internal/extensions"
 	"littlevsx/internal/marketplace"
+	"littlevsx/internal/models"
+	"littlevsx/internal/storage"
+	"littlevsx/internal/utils"
 
 	"github.com/spf13/cobra"
 )
 
 var (
 	marketplaceType string
+	targetPlatform  string
+
+	downloadOutputDir string
+
+	downloadFromFile    string
+	downloadConcurrency int
+	downloadResume      bool
+	downloadFailedOnly  bool
+
+	// noAssets and prefetch are shared by download, import and reindex.
+	noAssets bool
+	prefetch bool
+
+	// prefetchedFiles counts the files extracted by --prefetch in this run.
+	// Workers of download --from-file add to it concurrently.
+	prefetchedFiles atomic.Int64
 )
 
 var downloadCmd = &cobra.Command{
-	Use:   "download --type MARKETPLACE_TYPE EXTENSION_ID",
+	Use:   "download --type MARKETPLACE_TYPE (EXTENSION_ID | --from-file FILE)",
 	Short: "Downloads an extension from specified marketplace",
 	Long: `Downloads an extension from the specified marketplace.
 	
 Supported marketplaces:
 - microsoft: Microsoft Marketplace
 - open-vsx: Open VSX Registry (open-vsx.org)
+- auto: try the marketplaces listed in marketplace.order until one has the extension
+
+With --from-file the extensions listed in FILE are downloaded, one per line
+as "MARKETPLACE_TYPE EXTENSION_ID", or just "EXTENSION_ID" when --type is
+given for all of them. Blank lines and lines starting with # are ignored.
+A failed extension does not stop the others; a report of the succeeded and
+failed IDs is printed at the end. The outcome of every extension, with the
+reason of failures, is saved in FILE.state.json. --resume skips the
+extensions recorded there as done, so an interrupted or partly failed run
+picks up where it left off; --failed-only retries only the recorded
+failures.
 
 Examples:
   littlevsx download --type microsoft ms-python.python
-  littlevsx download --type open-vsx jeanp413.open-remote-ssh`,
-	Args: cobra.ExactArgs(1),
+  littlevsx download --type open-vsx jeanp413.open-remote-ssh
+  littlevsx download --type auto redhat.vscode-yaml
+  littlevsx download --type microsoft ms-vscode.cpptools --target-platform linux-x64
+  littlevsx download --type open-vsx redhat.java --no-assets
+  littlevsx download --type auto redhat.java --output-dir /scratch/vsix
+  littlevsx download --type open-vsx redhat.java --prefetch
+  littlevsx download --from-file extensions.txt --concurrency 4
+  littlevsx download --type open-vsx --from-file extensions.txt
+  littlevsx download --from-file extensions.txt --resume`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if downloadFromFile != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
+		if downloadFromFile != "" {
+			return runDownloadManifest(downloadFromFile)
+		}
+		if downloadResume || downloadFailedOnly {
+			return fmt.Errorf("--resume and --failed-only need --from-file")
+		}
 		return runDownload(args[0])
 	},
 }
 
 func init() {
-	downloadCmd.Flags().StringVarP(&marketplaceType, "type", "t", "", "Marketplace type: microsoft, open-vsx (required)")
-	downloadCmd.MarkFlagRequired("type")
+	downloadCmd.Flags().StringVarP(&marketplaceType, "type", "t", "", "Marketplace type: microsoft, open-vsx, auto (required unless every line of --from-file names one)")
+	downloadCmd.Flags().StringVar(&targetPlatform, "target-platform", "", "Target platform, e.g. linux-x64, win32-x64, darwin-arm64 (default universal)")
+	downloadCmd.Flags().StringVarP(&downloadOutputDir, "output-dir", "o", "", "Save the .vsix in this directory instead of extensions.directory; the database points at it there")
+	downloadCmd.Flags().BoolVar(&prefetch, "prefetch", false, "Extract the package.json, vsixmanifest and icon into the assets directory after indexing")
+	downloadCmd.Flags().StringVar(&downloadFromFile, "from-file", "", "Download the extensions listed in this file instead of EXTENSION_ID")
+	downloadCmd.Flags().IntVar(&downloadConcurrency, "concurrency", 1, "Extensions of --from-file downloaded in parallel")
+	downloadCmd.Flags().BoolVar(&downloadResume, "resume", false, "With --from-file, skip the extensions FILE.state.json records as done")
+	downloadCmd.Flags().BoolVar(&downloadFailedOnly, "failed-only", false, "With --from-file, only retry the extensions FILE.state.json records as failed")
+	downloadCmd.Flags().BoolVar(&noAssets, "no-assets", false, "Keep README images at their remote URLs instead of downloading them (overrides assets.process)")
 	rootCmd.AddCommand(downloadCmd)
 }
 
@@ -45,7 +109,10 @@ func runDownload(extensionID string) error {
 		return fmt.Errorf("marketplace type is required, use --type flag")
 	}
 
-	config := config.GetConfig()
+	outputDir, err := downloadDir()
+	if err != nil {
+		return err
+	}
 
 	extManager, err := extensions.New()
 	if err != nil {
@@ -53,20 +120,86 @@ func runDownload(extensionID string) error {
 	}
 	defer extManager.Close()
 
-	factory := marketplace.NewFactory()
-	marketplaceTypeEnum := marketplace.MarketplaceType(marketplaceType)
-
-	mp, err := factory.CreateByType(marketplaceTypeEnum)
+	fetched, err := fetchExtension(marketplace.NewFactory(), marketplace.MarketplaceType(marketplaceType), extensionID, outputDir)
 	if err != nil {
-		return fmt.Errorf("error creating marketplace provider: %w", err)
+		return err
 	}
+	return storeFetched(extManager, fetched, func(filePath, source, checksum string, stats *marketplace.Statistics) error {
+		return addDownloadedExtension(extManager, filePath, source, checksum, stats)
+	})
+}
 
-	fmt.Printf("Using marketplace: %s\n", mp.GetName())
-	fmt.Println("Getting extension information...")
+// downloadDir returns the directory downloads are saved in: --output-dir
+// if set, extensions.directory otherwise.
+func downloadDir() (string, error) {
+	config := config.GetConfig()
 
-	info, err := mp.GetExtensionInfoByID(extensionID)
+	if downloadOutputDir == "" {
+		return config.ExtensionsDir, nil
+	}
+	// The S3 backend maps file paths to keys relative to
+	// extensions.directory, so it could never find files stored elsewhere.
+	if config.StorageBackend == storage.BackendS3 {
+		return "", fmt.Errorf("--output-dir is only supported with the local storage backend")
+	}
+	// Stored absolute, so the server finds the file whatever its working
+	// directory.
+	dir, err := filepath.Abs(downloadOutputDir)
 	if err != nil {
-		return fmt.Errorf("error getting extension information: %w", err)
+		return "", fmt.Errorf("invalid --output-dir: %w", err)
+	}
+	return dir, nil
+}
+
+// fetchedExtension is a package downloaded by fetchExtension and not yet
+// stored.
+type fetchedExtension struct {
+	info   *marketplace.ExtensionInfo
+	result *marketplace.DownloadResult
+	source marketplace.MarketplaceType
+}
+
+// fetchExtension looks the extension up in the marketplace of type source,
+// or in those of marketplace.order for auto, and downloads it into
+// outputDir. It does not touch the database.
+func fetchExtension(factory *marketplace.Factory, source marketplace.MarketplaceType, extensionID, outputDir string) (*fetchedExtension, error) {
+	config := config.GetConfig()
+
+	if err := config.CheckPolicy(extensionID); err != nil {
+		return nil, fmt.Errorf("download rejected: %w", err)
+	}
+
+	var mp marketplace.MarketplaceProvider
+	var info *marketplace.ExtensionInfo
+	var err error
+
+	if source == marketplace.MarketplaceTypeAuto {
+		order := make([]marketplace.MarketplaceType, 0, len(config.MarketplaceOrder))
+		for _, name := range config.MarketplaceOrder {
+			order = append(order, marketplace.MarketplaceType(name))
+		}
+
+		fmt.Printf("Looking up extension in: %s\n", strings.Join(config.MarketplaceOrder, ", "))
+		resolved, err := factory.Resolve(order, extensionID, targetPlatform)
+		if err != nil {
+			return nil, fmt.Errorf("error getting extension information: %w", err)
+		}
+
+		mp, info, source = resolved.Provider, resolved.Info, resolved.Type
+		fmt.Printf("Found in marketplace: %s\n", mp.GetName())
+	} else {
+		mp, err = factory.CreateByType(source)
+		if err != nil {
+			return nil, fmt.Errorf("error creating marketplace provider: %w", err)
+		}
+
+		fmt.Printf("Using marketplace: %s\n", mp.GetName())
+		fmt.Println("Getting extension information...")
+
+		info, err = mp.GetExtensionInfoByID(extensionID, targetPlatform)
+		if err != nil {
+			return nil, fmt.Errorf("error getting extension information: %w", err)
+		}
 	}
 
 	fmt.Printf("\nExtension information:\n")
@@ -74,78 +207,176 @@ func runDownload(extensionID string) error {
 	fmt.Printf("  Name: %s\n", info.DisplayName)
 	fmt.Printf("  Publisher: %s\n", info.Publisher)
 	fmt.Printf("  Version: %s\n", info.Version)
+	fmt.Printf("  Target platform: %s\n", info.TargetPlatform)
 	if info.Description != "" {
 		fmt.Printf("  Description: %s\n", info.Description)
 	}
 
 	fmt.Println("\nDownloading extension...")
-	result, err := mp.DownloadExtension(info, config.ExtensionsDir)
+	result, err := mp.DownloadExtension(info, outputDir)
 	if err != nil {
-		return fmt.Errorf("error downloading extension: %w", err)
+		return nil, fmt.Errorf("error downloading extension: %w", err)
 	}
+	return &fetchedExtension{info: info, result: result, source: source}, nil
+}
+
+// addFunc stores a downloaded package with the statistics the marketplace
+// reported. checksum is its SHA-256, or empty when it still has to be
+// computed.
+type addFunc func(filePath, source, checksum string, stats *marketplace.Statistics) error
+
+// storeFetched adds a package downloaded by fetchExtension to the database
+// with add unless that build is already there. A freshly downloaded package
+// that is rejected is removed again.
+func storeFetched(extManager *extensions.Manager, fetched *fetchedExtension, add addFunc) error {
+	info, result := fetched.info, fetched.result
 
 	if result.WasDownloaded {
 		fmt.Printf("\n✅ Extension successfully downloaded: %s\n", result.FilePath)
 		fmt.Println("Adding extension to database...")
-		ext, err := extManager.ReadExtensionInfo(result.FilePath)
+		err := add(result.FilePath, string(fetched.source), result.SHA256, info.Statistics)
+		if discardDownload(err) {
+			os.Remove(result.FilePath)
+		}
+		return err
+	}
+
+	fmt.Printf("\nℹ️  Extension already exists: %s\n", result.FilePath)
+
+	if existingExt, exists := extManager.GetBuild(info.FullName(), info.Version, info.TargetPlatform); exists {
+		fmt.Printf("ℹ️  Extension already in database: %s\n", existingExt.DisplayName)
+		return storeStatistics(extManager, existingExt.ID, info.Statistics)
+	}
+
+	fmt.Println("Adding existing extension to database...")
+	err := add(result.FilePath, string(fetched.source), "", info.Statistics)
+	if errors.Is(err, extensions.ErrInvalidVSIX) {
+		return fmt.Errorf("%w (delete the file and download it again)", err)
+	}
+	return err
+}
+
+// discardDownload reports whether err is a CheckEngine rejection or an
+// unreadable package, after which a freshly downloaded package is not kept.
+func discardDownload(err error) bool {
+	return errors.Is(err, config.ErrEngineNotAllowed) || errors.Is(err, extensions.ErrInvalidVSIX)
+}
+
+// addDownloadedExtension reads a downloaded .vsix, localizes its README
+// assets unless disabled by assets.process or --no-assets, and stores it
+// together with where it came from and the statistics the marketplace
+// reported, if any. checksum is the SHA-256 computed while downloading the
+// package, or empty to hash the file.
+func addDownloadedExtension(extManager *extensions.Manager, filePath, source, checksum string, stats *marketplace.Statistics) error {
+	ext, err := prepareExtension(extManager, filePath, source, checksum)
+	if err != nil {
+		return err
+	}
+
+	if err := extManager.GetDB().UpsertExtension(database.ToDBExtension(ext)); err != nil {
+		return fmt.Errorf("error saving extension to database: %w", err)
+	}
+	if err := storeStatistics(extManager, ext.ID, stats); err != nil {
+		return err
+	}
+	reportStored(extManager, ext)
+	return nil
+}
+
+// storeStatistics records the install and rating numbers a marketplace
+// reported for an extension; nil stats are ignored.
+func storeStatistics(extManager *extensions.Manager, id string, stats *marketplace.Statistics) error {
+	if stats == nil {
+		return nil
+	}
+	if err := extManager.GetDB().SetStatistics(id, stats.Installs, stats.AverageRating, stats.RatingCount); err != nil {
+		return fmt.Errorf("error saving statistics of %s: %w", id, err)
+	}
+	return nil
+}
+
+// queueDownloadedExtension is addDownloadedExtension for bulk runs: the
+// prepared extension is handed to indexer, which calls stored with the
+// result once it and stats are in the database.
+func queueDownloadedExtension(extManager *extensions.Manager, indexer *extensions.Indexer, filePath, source, checksum string, stats *marketplace.Statistics, stored func(error)) error {
+	ext, err := prepareExtension(extManager, filePath, source, checksum)
+	if err != nil {
+		return err
+	}
+
+	indexer.Add(ext, func(err error) {
 		if err != nil {
-			return fmt.Errorf("error reading extension information: %w", err)
+			err = fmt.Errorf("error saving extension %s to database: %w", ext.ID, err)
+		} else if err = storeStatistics(extManager, ext.ID, stats); err == nil {
+			reportStored(extManager, ext)
 		}
+		stored(err)
+	})
+	return nil
+}
 
-		if ext.ReadmeContent != "" {
-			fmt.Println("Processing README assets...")
-			assetProcessor := extensions.NewAssetProcessor(config.AssetsDir, config.BaseURL)
-			processedReadme, err := assetProcessor.ProcessReadme(ext.ReadmeContent, ext.ID)
-			if err != nil {
-				fmt.Printf("Warning: error processing assets: %v\n", err)
-			} else {
-				ext.ReadmeContent = processedReadme
-				fmt.Println("✅ Assets processed")
-			}
+// prepareExtension does everything addDownloadedExtension does before the
+// extension is stored: reading the .vsix, the engine check, asset
+// processing and --prefetch. The file is only hashed when no checksum is
+// passed in.
+func prepareExtension(extManager *extensions.Manager, filePath, source, checksum string) (*models.Extension, error) {
+	config := config.GetConfig()
+
+	ext, err := extManager.ReadExtensionInfo(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading extension information from %s: %w", filePath, err)
+	}
+	ext.Source = source
+
+	ext.SHA256 = checksum
+	if ext.SHA256 == "" {
+		if ext.SHA256, err = utils.NewFileUtils().SHA256(filePath); err != nil {
+			return nil, fmt.Errorf("error computing checksum of %s: %w", filePath, err)
 		}
+	}
+
+	if err := config.CheckEngine(ext.ID, ext.Engines.VSCode); err != nil {
+		return nil, fmt.Errorf("extension rejected: %w", err)
+	}
 
-		dbExt := database.ToDBExtension(ext)
-		if err := extManager.GetDB().UpsertExtension(dbExt); err != nil {
-			return fmt.Errorf("error saving extension to database: %w", err)
+	if ext.ReadmeContent != "" && config.AssetsProcess && !noAssets {
+		fmt.Println("Processing README assets...")
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		assetProcessor := extensions.NewAssetProcessor(config.AssetsDir, config.BaseURL)
+		processedReadme, err := assetProcessor.ProcessReadme(ctx, ext.ReadmeContent, ext.ID)
+		stop()
+		if err != nil {
+			fmt.Printf("Warning: error processing assets: %v\n", err)
+		} else {
+			ext.ReadmeContent = processedReadme
+			fmt.Println("✅ Assets processed")
 		}
+	}
 
-		fmt.Printf("✅ Extension added to database: %s\n", ext.DisplayName)
-		return nil
-	} else {
-		fmt.Printf("\nℹ️  Extension already exists: %s\n", result.FilePath)
-
-		extensionID := info.ID
-		existingExt, exists := extManager.GetByID(extensionID)
-
-		if !exists {
-			fmt.Println("Adding existing extension to database...")
-			ext, err := extManager.ReadExtensionInfo(result.FilePath)
-			if err != nil {
-				return fmt.Errorf("error reading extension information: %w", err)
-			}
-
-			if ext.ReadmeContent != "" {
-				fmt.Println("Processing README assets...")
-				assetProcessor := extensions.NewAssetProcessor(config.AssetsDir, config.BaseURL)
-				processedReadme, err := assetProcessor.ProcessReadme(ext.ReadmeContent, ext.ID)
-				if err != nil {
-					fmt.Printf("Warning: error processing assets: %v\n", err)
-				} else {
-					ext.ReadmeContent = processedReadme
-					fmt.Println("✅ Assets processed")
-				}
-			}
-
-			dbExt := database.ToDBExtension(ext)
-			if err := extManager.GetDB().UpsertExtension(dbExt); err != nil {
-				return fmt.Errorf("error saving extension to database: %w", err)
-			}
-
-			fmt.Printf("✅ Extension added to database: %s\n", ext.DisplayName)
-			return nil
+	if prefetch {
+		count, err := extManager.Prefetch(ext)
+		prefetchedFiles.Add(int64(count))
+		if err != nil {
+			fmt.Printf("Warning: error prefetching assets: %v\n", err)
 		} else {
-			fmt.Printf("ℹ️  Extension already in database: %s\n", existingExt.DisplayName)
-			return nil
+			fmt.Printf("✅ Prefetched %d files\n", count)
 		}
 	}
+	return ext, nil
+}
+
+// reportStored prints that ext was stored and warns about dependencies
+// missing from the catalog.
+func reportStored(extManager *extensions.Manager, ext *models.Extension) {
+	name := ext.DisplayName
+	if name == "" {
+		name = ext.ID
+	}
+	fmt.Printf("✅ Extension added to database: %s\n", name)
+
+	if missing, err := extManager.MissingDependencies(ext); err != nil {
+		fmt.Printf("Warning: error checking dependencies: %v\n", err)
+	} else if len(missing) > 0 {
+		fmt.Printf("⚠️  Dependencies not in the catalog: %s\n", strings.Join(missing, ", "))
+	}
 }
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/marketplace"
+)
+
+// manifestEntry is one line of a download --from-file list.
+type manifestEntry struct {
+	line   int
+	source marketplace.MarketplaceType
+	id     string
+}
+
+// readManifest parses a download list. Lines name a marketplace type and an
+// extension ID, or only the ID when defaultType is set.
+func readManifest(path, defaultType string) ([]manifestEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var entries []manifestEntry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		entry := manifestEntry{line: line, source: marketplace.MarketplaceType(defaultType)}
+		switch fields := strings.Fields(text); len(fields) {
+		case 1:
+			if defaultType == "" {
+				return nil, fmt.Errorf("%s:%d: no marketplace type for %s, add one or use --type", path, line, fields[0])
+			}
+			entry.id = fields[0]
+		case 2:
+			entry.source, entry.id = marketplace.MarketplaceType(fields[0]), fields[1]
+		default:
+			return nil, fmt.Errorf("%s:%d: expected \"MARKETPLACE_TYPE EXTENSION_ID\", got %q", path, line, text)
+		}
+
+		switch entry.source {
+		case marketplace.MarketplaceTypeMicrosoft, marketplace.MarketplaceTypeOpenVSX, marketplace.MarketplaceTypeAuto:
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown marketplace type: %s", path, line, entry.source)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// manifestState records which entries of a download list are done and why
+// the others failed, in the state file next to the list, so that --resume
+// can pick up an interrupted or partly failed run. Entries are keyed by
+// marketplace type and ID.
+type manifestState struct {
+	path string
+
+	mu      sync.Mutex
+	Entries map[string]manifestResult `json:"entries"`
+}
+
+// manifestResult is the outcome of one entry of a download list.
+type manifestResult struct {
+	Done  bool      `json:"done"`
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+func (e manifestEntry) key() string {
+	return string(e.source) + " " + e.id
+}
+
+// manifestStatePath returns the state file of the download list at path.
+func manifestStatePath(path string) string {
+	return path + ".state.json"
+}
+
+// loadManifestState reads the state file at path, or returns an empty
+// state when resume is false or there is no state file yet.
+func loadManifestState(path string, resume bool) (*manifestState, error) {
+	state := &manifestState{path: path, Entries: make(map[string]manifestResult)}
+	if !resume {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	if state.Entries == nil {
+		state.Entries = make(map[string]manifestResult)
+	}
+	return state, nil
+}
+
+// record stores the outcome of entry and saves the state file. A state file
+// that cannot be written is reported without failing the entry.
+func (st *manifestState) record(entry manifestEntry, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	result := manifestResult{Done: err == nil, Time: time.Now()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	st.Entries[entry.key()] = result
+
+	if err := st.save(); err != nil {
+		fmt.Printf("Warning: error saving download progress: %v\n", err)
+	}
+}
+
+// save writes the state to a temporary file renamed into place, so an
+// interrupted run never leaves a truncated state file behind.
+func (st *manifestState) save() error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(st.path), filepath.Base(st.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), st.path)
+}
+
+// pending returns the indexes of the entries still to be downloaded: all of
+// them, or with resume those not recorded as done, and with failedOnly only
+// those recorded as failed.
+func (st *manifestState) pending(entries []manifestEntry, resume, failedOnly bool) []int {
+	var pending []int
+	for i, entry := range entries {
+		result, seen := st.Entries[entry.key()]
+		switch {
+		case !resume:
+		case result.Done:
+			continue
+		case failedOnly && !seen:
+			continue
+		case seen:
+			fmt.Printf("↻ Retrying %s (line %d), which failed: %s\n", entry.id, entry.line, result.Error)
+		}
+		pending = append(pending, i)
+	}
+	return pending
+}
+
+// runDownloadManifest downloads every extension listed in path with
+// --concurrency workers. Downloads and asset processing run in parallel;
+// the prepared extensions are stored by a single indexer. The outcome of
+// every entry is saved in the state file, which --resume reads to skip the
+// entries that are done.
+func runDownloadManifest(path string) error {
+	entries, err := readManifest(path, marketplaceType)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("%s lists no extensions", path)
+	}
+
+	resume := downloadResume || downloadFailedOnly
+	state, err := loadManifestState(manifestStatePath(path), resume)
+	if err != nil {
+		return err
+	}
+	pending := state.pending(entries, resume, downloadFailedOnly)
+	if skipped := len(entries) - len(pending); skipped > 0 {
+		fmt.Printf("ℹ️  Skipping %d of %d extensions recorded in %s\n", skipped, len(entries), state.path)
+	}
+	if len(pending) == 0 {
+		fmt.Println("✅ Nothing left to download")
+		return nil
+	}
+
+	outputDir, err := downloadDir()
+	if err != nil {
+		return err
+	}
+
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	factory := marketplace.NewFactory()
+	// An entry's error is set either by its worker or, once the entry is
+	// queued, by the indexer; it is read after the indexer is closed.
+	errs := make([]error, len(entries))
+	indexer := extManager.StartIndexer()
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range max(downloadConcurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				i := pending[n]
+				entry := entries[i]
+				fmt.Printf("\n[%d/%d] %s (%s)\n", n+1, len(pending), entry.id, entry.source)
+
+				queued := false
+				fetched, err := fetchExtension(factory, entry.source, entry.id, outputDir)
+				if err == nil {
+					err = storeFetched(extManager, fetched, func(filePath, source, checksum string, stats *marketplace.Statistics) error {
+						err := queueDownloadedExtension(extManager, indexer, filePath, source, checksum, stats, func(err error) {
+							if err != nil {
+								fmt.Printf("❌ %s: %v\n", entry.id, err)
+							}
+							errs[i] = err
+							state.record(entry, err)
+						})
+						queued = err == nil
+						return err
+					})
+				}
+				if err != nil {
+					fmt.Printf("❌ %s: %v\n", entry.id, err)
+					errs[i] = err
+				}
+				if !queued {
+					state.record(entry, err)
+				}
+			}
+		}()
+	}
+	for n := range pending {
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+	indexer.Close()
+
+	var succeeded, failed []string
+	for _, i := range pending {
+		entry := entries[i]
+		if errs[i] == nil {
+			succeeded = append(succeeded, entry.id)
+		} else {
+			failed = append(failed, fmt.Sprintf("  %s (line %d): %v", entry.id, entry.line, errs[i]))
+		}
+	}
+
+	fmt.Printf("\n✅ Succeeded (%d): %s\n", len(succeeded), strings.Join(succeeded, ", "))
+	if prefetch {
+		fmt.Printf("✅ Prefetched %d files\n", prefetchedFiles.Load())
+	}
+	if len(failed) > 0 {
+		fmt.Printf("❌ Failed (%d):\n%s\n", len(failed), strings.Join(failed, "\n"))
+		fmt.Printf("Progress is saved in %s; run again with --resume to retry only what is not done.\n", state.path)
+		return fmt.Errorf("%d of %d extensions could not be downloaded", len(failed), len(pending))
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/database"
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/models"
+
+	"github.com/spf13/cobra"
+)
+
+// catalogSchemaVersion is written to every export and checked by
+// import --from-json. Increase it when the format changes incompatibly.
+const catalogSchemaVersion = 1
+
+// catalogExport is the document written by export.
+type catalogExport struct {
+	SchemaVersion int                 `json:"schemaVersion"`
+	ExportedAt    time.Time           `json:"exportedAt"`
+	Extensions    []*models.Extension `json:"extensions"`
+}
+
+var exportOutput string
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Writes the catalog as a JSON document",
+	Long: `Writes every stored extension version as a JSON document with a schema
+version, for backups and moving a catalog between servers. Only metadata is
+written, not the .vsix files. Restore it with import --from-json.
+
+Unlike dump-db, which writes database rows as JSON lines, the document uses
+the same extension fields as the API.
+
+Examples:
+  littlevsx export --output catalog.json
+  littlevsx export > catalog.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runExport()
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to this file instead of stdout")
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport() error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	catalog := catalogExport{
+		SchemaVersion: catalogSchemaVersion,
+		ExportedAt:    time.Now().UTC(),
+		Extensions:    []*models.Extension{},
+	}
+	for page := 1; ; page++ {
+		exts, err := extManager.GetDB().GetAllExtensionVersions(page, dumpPageSize)
+		if err != nil {
+			return fmt.Errorf("error reading extensions: %w", err)
+		}
+		catalog.Extensions = append(catalog.Extensions, database.ToExtensionSlice(exts)...)
+		if len(exts) < dumpPageSize {
+			break
+		}
+	}
+
+	if exportOutput == "" {
+		err = writeCatalog(os.Stdout, &catalog)
+	} else {
+		err = writeCatalogFile(exportOutput, &catalog)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "✅ Exported %d extension versions\n", len(catalog.Extensions))
+	return nil
+}
+
+func writeCatalog(out io.Writer, catalog *catalogExport) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(catalog); err != nil {
+		return fmt.Errorf("error writing catalog: %w", err)
+	}
+	return nil
+}
+
+func writeCatalogFile(path string, catalog *catalogExport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	err = writeCatalog(file, catalog)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error writing %s: %w", path, closeErr)
+	}
+	return err
+}
+
+// runImportJSON restores a catalog written by export. Entries whose .vsix
+// file no longer exists are rejected, as the server could not serve them.
+func runImportJSON(path string) error {
+	cfg := config.GetConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	var catalog catalogExport
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	if catalog.SchemaVersion < 1 || catalog.SchemaVersion > catalogSchemaVersion {
+		return fmt.Errorf("%s has schema version %d, expected %d", path, catalog.SchemaVersion, catalogSchemaVersion)
+	}
+
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	db := extManager.GetDB()
+	var imported, rejected int
+	for i, ext := range catalog.Extensions {
+		if ext == nil || ext.ID == "" || ext.Version == "" {
+			fmt.Printf("❌ Rejected entry %d: no id or version\n", i+1)
+			rejected++
+			continue
+		}
+		if err := cfg.CheckPolicy(ext.ID); err != nil {
+			fmt.Printf("❌ Rejected %s %s: %v\n", ext.ID, ext.Version, err)
+			rejected++
+			continue
+		}
+		if err := cfg.CheckEngine(ext.ID, ext.Engines.VSCode); err != nil {
+			fmt.Printf("❌ Rejected %s %s: %v\n", ext.ID, ext.Version, err)
+			rejected++
+			continue
+		}
+		if _, err := extManager.Storage().Stat(ext.FilePath); ext.FilePath == "" || err != nil {
+			fmt.Printf("❌ Rejected %s %s: file %q not found\n", ext.ID, ext.Version, ext.FilePath)
+			rejected++
+			continue
+		}
+
+		if importDryRun {
+			fmt.Printf("Would import %s %s (%s)\n", ext.ID, ext.Version, ext.TargetPlatform)
+			imported++
+			continue
+		}
+
+		ext.MetadataOnly = false
+		if err := db.UpsertExtension(database.ToDBExtension(ext)); err != nil {
+			return fmt.Errorf("error saving %s: %w", ext.ID, err)
+		}
+		if ext.Featured {
+			if _, err := db.SetFeatured(ext.ID, true); err != nil {
+				return fmt.Errorf("error saving %s: %w", ext.ID, err)
+			}
+		}
+		if ext.Pinned {
+			if _, err := db.SetPinned(ext.ID, true); err != nil {
+				return fmt.Errorf("error saving %s: %w", ext.ID, err)
+			}
+		}
+		imported++
+	}
+
+	if importDryRun {
+		fmt.Printf("\nDry run: %d would be imported, %d rejected\n", imported, rejected)
+	} else {
+		fmt.Printf("\n✅ Imported %d extension versions, %d rejected\n", imported, rejected)
+	}
+	return nil
+}
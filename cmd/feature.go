@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"littlevsx/internal/extensions"
+
+	"github.com/spf13/cobra"
+)
+
+var featureCmd = &cobra.Command{
+	Use:   "feature EXTENSION_ID",
+	Short: "Marks an extension as featured",
+	Long:  "Marks an extension as featured. Featured extensions are listed first when browsing and are returned for featured gallery queries.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runSetFeatured(args[0], true)
+	},
+}
+
+var unfeatureCmd = &cobra.Command{
+	Use:   "unfeature EXTENSION_ID",
+	Short: "Removes the featured mark from an extension",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runSetFeatured(args[0], false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(featureCmd)
+	rootCmd.AddCommand(unfeatureCmd)
+}
+
+func runSetFeatured(extensionID string, featured bool) error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	if err := extManager.SetFeatured(extensionID, featured); err != nil {
+		return err
+	}
+
+	if featured {
+		fmt.Printf("✅ %s is now featured\n", extensionID)
+	} else {
+		fmt.Printf("✅ %s is no longer featured\n", extensionID)
+	}
+	return nil
+}
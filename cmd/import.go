@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/models"
+
+	"github.com/spf13/cobra"
+)
+
+const importSource = "local"
+
+// hasMarketplace reports whether ext was downloaded from a marketplace it
+// can be looked up in again, rather than imported.
+func hasMarketplace(ext *models.Extension) bool {
+	return ext.Source != "" && ext.Source != importSource
+}
+
+var (
+	importRecursive bool
+	importDryRun    bool
+	importFromJSON  string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import (DIRECTORY | --from-json FILE)",
+	Short: "Adds the .vsix files found in a directory to the marketplace",
+	Long: `Copies every valid .vsix file in DIRECTORY into the extensions directory and
+adds it to the database. Older versions are stored next to the newer ones.
+Files already registered under the same path or version are skipped, files
+that fail validation, policy or the engines.min/engines.max range are
+rejected.
+
+With --dry-run the files are only validated and the outcome is reported;
+nothing is copied, stored or downloaded.
+
+With --from-json the catalog written by export is restored instead. Entries
+whose .vsix file no longer exists are rejected.
+
+Examples:
+  littlevsx import ./incoming
+  littlevsx import ./incoming --recursive --dry-run
+  littlevsx import ./airgap --no-assets
+  littlevsx import ./incoming --prefetch
+  littlevsx import --from-json catalog.json`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if importFromJSON != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if importFromJSON != "" {
+			return runImportJSON(importFromJSON)
+		}
+		return runImport(args[0])
+	},
+}
+
+func init() {
+	importCmd.Flags().BoolVarP(&importRecursive, "recursive", "r", false, "Also import .vsix files in subdirectories")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only report what would be imported, skipped or rejected")
+	importCmd.Flags().StringVar(&importFromJSON, "from-json", "", "Restore a catalog written by export instead of importing a directory")
+	importCmd.Flags().BoolVar(&prefetch, "prefetch", false, "Extract the package.json, vsixmanifest and icon into the assets directory after indexing")
+	importCmd.Flags().BoolVar(&noAssets, "no-assets", false, "Keep README images at their remote URLs instead of downloading them (overrides assets.process)")
+	rootCmd.AddCommand(importCmd)
+}
+
+func runImport(dir string) error {
+	config := config.GetConfig()
+
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	files, err := extManager.ListVSIXFiles(dir, importRecursive)
+	if err != nil {
+		return fmt.Errorf("error listing %s: %w", dir, err)
+	}
+
+	// stored and failed are only touched by the indexer until it is closed.
+	indexer := extManager.StartIndexer()
+	var stored, failed int
+
+	var imported, skipped, rejected int
+	for _, file := range files {
+		target := filepath.Join(config.ExtensionsDir, filepath.Base(file))
+		if registered, err := extManager.GetDB().GetExtensionByFilePath(target); err == nil && registered != nil {
+			fmt.Printf("ℹ️  Skipping %s: already registered as %s %s\n", file, registered.ID, registered.Version)
+			skipped++
+			continue
+		}
+
+		ext, err := checkImport(extManager, config, file)
+		if err != nil {
+			fmt.Printf("❌ Rejected %s: %v\n", file, err)
+			rejected++
+			continue
+		}
+
+		if _, exists := extManager.GetBuild(ext.ID, ext.Version, ext.TargetPlatform); exists {
+			fmt.Printf("ℹ️  Skipping %s %s (%s): already present\n", ext.ID, ext.Version, ext.TargetPlatform)
+			skipped++
+			continue
+		}
+
+		if importDryRun {
+			fmt.Printf("Would import %s %s (%s): %s\n", ext.ID, ext.Version, ext.TargetPlatform, file)
+			imported++
+			continue
+		}
+
+		target, err = copyIntoExtensionsDir(file, config.ExtensionsDir)
+		if err != nil {
+			fmt.Printf("❌ Failed to copy %s: %v\n", file, err)
+			rejected++
+			continue
+		}
+		err = queueDownloadedExtension(extManager, indexer, target, importSource, "", nil, func(err error) {
+			if err != nil {
+				fmt.Printf("❌ Failed to import %s: %v\n", file, err)
+				failed++
+				return
+			}
+			stored++
+		})
+		if err != nil {
+			fmt.Printf("❌ Failed to import %s: %v\n", file, err)
+			rejected++
+		}
+	}
+
+	indexer.Close()
+	imported += stored
+	rejected += failed
+
+	if importDryRun {
+		fmt.Printf("\nDry run: %d would be imported, %d skipped, %d rejected\n", imported, skipped, rejected)
+	} else {
+		fmt.Printf("\n✅ Imported %d extensions, %d skipped, %d rejected\n", imported, skipped, rejected)
+		if prefetch {
+			fmt.Printf("✅ Prefetched %d files\n", prefetchedFiles.Load())
+		}
+	}
+	return nil
+}
+
+// checkImport runs the validation shared by dry runs and real imports.
+func checkImport(extManager *extensions.Manager, cfg config.Config, file string) (*models.Extension, error) {
+	if err := extManager.ValidateVSIX(file); err != nil {
+		return nil, err
+	}
+	ext, err := extManager.ReadExtensionInfo(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.CheckPolicy(ext.ID); err != nil {
+		return nil, err
+	}
+	if err := cfg.CheckEngine(ext.ID, ext.Engines.VSCode); err != nil {
+		return nil, err
+	}
+	return ext, nil
+}
+
+// copyIntoExtensionsDir copies file into dir unless it is already there and
+// returns the path of the copy. Like downloads, the copy is written to a
+// temporary file first so the server never sees a partial package.
+func copyIntoExtensionsDir(file, dir string) (string, error) {
+	target := filepath.Join(dir, filepath.Base(file))
+	if absFile, err := filepath.Abs(file); err == nil {
+		if absTarget, err := filepath.Abs(target); err == nil && absFile == absTarget {
+			return target, nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	src, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(target)+".*.part")
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", fmt.Errorf("failed to move file into place: %w", err)
+	}
+	return target, nil
+}
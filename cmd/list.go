@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"littlevsx/internal/database"
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	listTag   string
+	listPage  int
+	listLimit int
+
+	// listNDJSON is shared by list and search.
+	listNDJSON bool
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the extensions in the database",
+	Long: `Lists the extensions in the database, featured extensions first.
+Featured extensions are marked with ⭐ and pinned ones with 📌.
+
+With --ndjson every matching extension is written as one JSON object per
+line, ignoring --page and --limit. Extensions are read from the database a
+page at a time, so large catalogs can be piped without being held in memory.
+
+Examples:
+  littlevsx list
+  littlevsx list --tag python --limit 20
+  littlevsx list --ndjson | jq -r .id`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runList()
+	},
+}
+
+func init() {
+	listCmd.Flags().StringVar(&listTag, "tag", "", "Only list extensions with this tag")
+	listCmd.Flags().IntVar(&listPage, "page", 1, "Page number")
+	listCmd.Flags().IntVar(&listLimit, "limit", 50, "Extensions per page")
+	listCmd.Flags().BoolVar(&listNDJSON, "ndjson", false, "Write all matching extensions as JSON lines")
+	rootCmd.AddCommand(listCmd)
+}
+
+func runList() error {
+	if listPage < 1 || listLimit < 1 {
+		return fmt.Errorf("--page and --limit must be positive")
+	}
+
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	if listNDJSON {
+		db := extManager.GetDB()
+		return writeNDJSON(os.Stdout, func(page, size int) ([]database.ExtensionDB, error) {
+			var exts []database.ExtensionDB
+			var err error
+			if listTag != "" {
+				exts, _, err = db.GetExtensionsByTag(listTag, page, size)
+			} else {
+				exts, _, err = db.GetAllExtensions(page, size)
+			}
+			return exts, err
+		})
+	}
+
+	var exts []*models.Extension
+	var total int64
+	if listTag != "" {
+		exts, total = extManager.GetPageByTag(listTag, listPage, listLimit)
+	} else {
+		exts, total = extManager.GetPage(listPage, listLimit)
+	}
+
+	if len(exts) == 0 {
+		if listTag != "" {
+			fmt.Printf("ℹ️  No extensions tagged %q found\n", listTag)
+		} else {
+			fmt.Println("ℹ️  No extensions found")
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tVERSION\tPLATFORM\tNAME")
+	for _, ext := range exts {
+		name := ext.DisplayName
+		if ext.Featured {
+			name += " ⭐"
+		}
+		if ext.Pinned {
+			name += " 📌"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ext.ID, ext.Version, ext.TargetPlatform, name)
+	}
+	w.Flush()
+
+	first := (listPage-1)*listLimit + 1
+	fmt.Printf("\nShowing %d-%d of %d extensions\n", first, first+len(exts)-1, total)
+	return nil
+}
+
+// writeNDJSON writes the extensions returned by fetch as JSON lines, reading
+// one page at a time until a page comes back short.
+func writeNDJSON(out io.Writer, fetch func(page, size int) ([]database.ExtensionDB, error)) error {
+	encoder := json.NewEncoder(out)
+	for page := 1; ; page++ {
+		exts, err := fetch(page, dumpPageSize)
+		if err != nil {
+			return fmt.Errorf("error reading extensions: %w", err)
+		}
+
+		for _, ext := range database.ToExtensionSlice(exts) {
+			if err := encoder.Encode(ext); err != nil {
+				return fmt.Errorf("error writing %s: %w", ext.ID, err)
+			}
+		}
+
+		if len(exts) < dumpPageSize {
+			return nil
+		}
+	}
+}
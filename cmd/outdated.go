@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/marketplace"
+	"littlevsx/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	outdatedType       string
+	outdatedAllSources bool
+)
+
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated (--type MARKETPLACE_TYPE | --all-sources)",
+	Short: "Lists extensions with a newer version in their marketplace",
+	Long: `Looks up the latest version of each stored extension in the marketplace it
+was downloaded from and lists those where it is newer than the stored one.
+Nothing is downloaded or changed; update does that.
+
+With --type only extensions downloaded from that marketplace are checked;
+with --all-sources every extension is checked against its own. Extensions
+added with import have no recorded marketplace and are skipped.
+
+Examples:
+  littlevsx outdated --type microsoft
+  littlevsx outdated --all-sources`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runOutdated()
+	},
+}
+
+func init() {
+	outdatedCmd.Flags().StringVarP(&outdatedType, "type", "t", "", "Marketplace type: microsoft, open-vsx")
+	outdatedCmd.Flags().BoolVar(&outdatedAllSources, "all-sources", false, "Check every extension against the marketplace it came from")
+	outdatedCmd.MarkFlagsMutuallyExclusive("type", "all-sources")
+	outdatedCmd.MarkFlagsOneRequired("type", "all-sources")
+	rootCmd.AddCommand(outdatedCmd)
+}
+
+func runOutdated() error {
+	factory := marketplace.NewFactory()
+	providers := make(map[string]marketplace.MarketplaceProvider)
+	if outdatedType != "" {
+		provider, err := factory.CreateByType(marketplace.MarketplaceType(outdatedType))
+		if err != nil {
+			return fmt.Errorf("error creating marketplace provider: %w", err)
+		}
+		providers[outdatedType] = provider
+	}
+
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPLATFORM\tSOURCE\tLOCAL\tREMOTE")
+
+	checked, outdated, noSource, failed := 0, 0, 0, 0
+	for _, ext := range extManager.GetAll() {
+		if ext.MetadataOnly {
+			continue
+		}
+		if !hasMarketplace(ext) {
+			noSource++
+			continue
+		}
+
+		provider, ok := providers[ext.Source]
+		if !ok {
+			if !outdatedAllSources {
+				continue
+			}
+			if provider, err = factory.CreateByType(marketplace.MarketplaceType(ext.Source)); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  %s: %v\n", ext.ID, err)
+				failed++
+				continue
+			}
+			providers[ext.Source] = provider
+		}
+
+		checked++
+		info, err := provider.GetExtensionInfoByID(ext.ID, ext.TargetPlatform)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %s: %v\n", ext.ID, err)
+			failed++
+			continue
+		}
+		if utils.CompareVersions(info.Version, ext.Version) > 0 {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ext.ID, ext.TargetPlatform, ext.Source, ext.Version, info.Version)
+			outdated++
+		}
+	}
+
+	if outdated > 0 {
+		w.Flush()
+		fmt.Println()
+	}
+
+	fmt.Printf("%d of %d checked extensions are outdated", outdated, checked)
+	if noSource > 0 {
+		fmt.Printf(", %d skipped without a recorded marketplace", noSource)
+	}
+	fmt.Println()
+
+	if failed > 0 {
+		return fmt.Errorf("%d extensions could not be checked", failed)
+	}
+	return nil
+}
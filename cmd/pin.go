@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+
+	"littlevsx/internal/extensions"
+
+	"github.com/spf13/cobra"
+)
+
+var pinCmd = &cobra.Command{
+	Use:   "pin EXTENSION_ID",
+	Short: "Freezes an extension at its current version",
+	Long: `Pins an extension. clean keeps every .vsix file of a pinned extension and
+reindex leaves its database entries as they are, so a hand-picked version stays
+in place until the extension is unpinned. Downloading or importing another
+version explicitly still replaces it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runSetPinned(args[0], true)
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin EXTENSION_ID",
+	Short: "Lets clean and reindex manage an extension again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runSetPinned(args[0], false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+	rootCmd.AddCommand(unpinCmd)
+}
+
+func runSetPinned(extensionID string, pinned bool) error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	if err := extManager.SetPinned(extensionID, pinned); err != nil {
+		return err
+	}
+
+	if pinned {
+		fmt.Printf("📌 %s is now pinned\n", extensionID)
+	} else {
+		fmt.Printf("✅ %s is no longer pinned\n", extensionID)
+	}
+	return nil
+}
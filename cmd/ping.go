@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/marketplace"
+
+	"github.com/spf13/cobra"
+)
+
+var pingType string
+
+var pingCmd = &cobra.Command{
+	Use:   "ping",
+	Short: "Checks that the upstream marketplaces are reachable",
+	Long: `Sends a lightweight request to each marketplace and reports whether it
+answered and how long it took. Without --type, the marketplaces listed in
+marketplace.order are checked. Each request gives up after
+marketplace.ping_timeout seconds.
+
+Examples:
+  littlevsx ping
+  littlevsx ping --type open-vsx`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPing()
+	},
+}
+
+func init() {
+	pingCmd.Flags().StringVarP(&pingType, "type", "t", "", "Marketplace type: microsoft, open-vsx (default all in marketplace.order)")
+	rootCmd.AddCommand(pingCmd)
+}
+
+func runPing() error {
+	config := config.GetConfig()
+
+	var types []marketplace.MarketplaceType
+	if pingType != "" {
+		types = append(types, marketplace.MarketplaceType(pingType))
+	} else {
+		for _, name := range config.MarketplaceOrder {
+			types = append(types, marketplace.MarketplaceType(name))
+		}
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("no marketplaces configured to ping")
+	}
+
+	timeout := time.Duration(config.MarketplacePingTimeout) * time.Second
+	results := marketplace.NewFactory().Ping(context.Background(), types, timeout)
+
+	unreachable := 0
+	for _, result := range results {
+		if result.Reachable {
+			fmt.Printf("✅ %s (%s): reachable in %d ms\n", result.Name, result.Type, result.LatencyMS)
+			continue
+		}
+		fmt.Printf("❌ %s (%s): unreachable after %d ms: %s\n", result.Name, result.Type, result.LatencyMS, result.Error)
+		unreachable++
+	}
+
+	if unreachable > 0 {
+		return fmt.Errorf("%d of %d marketplaces unreachable", unreachable, len(results))
+	}
+	return nil
+}
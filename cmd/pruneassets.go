@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/extensions"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneOlderThan string
+	pruneMaxSize   string
+	pruneDryRun    bool
+)
+
+var pruneAssetsCmd = &cobra.Command{
+	Use:   "prune-assets",
+	Short: "Removes cached README assets that are no longer referenced",
+	Long: `Removes downloaded README assets that the current README of their extension
+no longer references, e.g. after an update changed the README.
+
+Unreferenced assets not accessed for --older-than are removed. With --max-size,
+further unreferenced assets are removed, least recently accessed first, until
+the assets directory fits. Referenced assets are never removed. Serving an
+asset refreshes its modification time, which is used as its last access.
+
+Examples:
+  littlevsx prune-assets --older-than 30d
+  littlevsx prune-assets --older-than 7d --max-size 2GB --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPruneAssets()
+	},
+}
+
+func init() {
+	pruneAssetsCmd.Flags().StringVar(&pruneOlderThan, "older-than", "30d", "Remove unreferenced assets not accessed for this long, e.g. 12h, 30d")
+	pruneAssetsCmd.Flags().StringVar(&pruneMaxSize, "max-size", "", "Keep the assets directory below this size, e.g. 500MB, 2GB")
+	pruneAssetsCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Only print what would be removed")
+	rootCmd.AddCommand(pruneAssetsCmd)
+}
+
+type cachedAsset struct {
+	path       string
+	size       int64
+	accessed   time.Time
+	referenced bool
+}
+
+func runPruneAssets() error {
+	maxAge, err := parseAge(pruneOlderThan)
+	if err != nil {
+		return fmt.Errorf("invalid --older-than: %w", err)
+	}
+	var maxSize int64
+	if pruneMaxSize != "" {
+		if maxSize, err = parseSize(pruneMaxSize); err != nil {
+			return fmt.Errorf("invalid --max-size: %w", err)
+		}
+	}
+
+	config := config.GetConfig()
+
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	assets, err := listCachedAssets(extManager, config.AssetsDir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	var candidates []cachedAsset
+	for _, asset := range assets {
+		total += asset.size
+		if !asset.referenced {
+			candidates = append(candidates, asset)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].accessed.Before(candidates[j].accessed)
+	})
+
+	cutoff := time.Now().Add(-maxAge)
+	var removed int
+	var freed int64
+
+	for _, asset := range candidates {
+		expired := maxAge > 0 && asset.accessed.Before(cutoff)
+		overSize := maxSize > 0 && total > maxSize
+		if !expired && !overSize {
+			continue
+		}
+
+		if pruneDryRun {
+			fmt.Printf("Would remove %s (last accessed %s)\n", asset.path, asset.accessed.Format(time.DateOnly))
+		} else {
+			if err := os.Remove(asset.path); err != nil {
+				fmt.Printf("❌ Failed to remove %s: %v\n", asset.path, err)
+				continue
+			}
+			fmt.Printf("🗑️  Removed %s (last accessed %s)\n", asset.path, asset.accessed.Format(time.DateOnly))
+			os.Remove(filepath.Dir(asset.path)) // only succeeds once the directory is empty
+		}
+
+		removed++
+		freed += asset.size
+		total -= asset.size
+	}
+
+	if pruneDryRun {
+		fmt.Printf("\nDry run: %d assets (%d bytes) would be removed, %d bytes would remain\n", removed, freed, total)
+	} else {
+		fmt.Printf("\n✅ Removed %d assets, freed %d bytes, %d bytes remain\n", removed, freed, total)
+	}
+	if maxSize > 0 && total > maxSize {
+		fmt.Printf("⚠️  The remaining assets are still referenced and exceed --max-size %s\n", pruneMaxSize)
+	}
+	return nil
+}
+
+// listCachedAssets returns the files in the per-extension directories of
+// assetsDir and whether the stored README of their extension links to them.
+func listCachedAssets(extManager *extensions.Manager, assetsDir string) ([]cachedAsset, error) {
+	dirs, err := os.ReadDir(assetsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing assets directory: %w", err)
+	}
+
+	var assets []cachedAsset
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		extensionID := dir.Name()
+		// An asset counts as referenced while any stored version uses it.
+		var readmes []string
+		for _, ext := range extManager.GetVersions(extensionID) {
+			readmes = append(readmes, ext.ReadmeContent)
+		}
+		readme := strings.Join(readmes, "\n")
+
+		files, err := os.ReadDir(filepath.Join(assetsDir, extensionID))
+		if err != nil {
+			fmt.Printf("⚠️  Skipping %s: %v\n", extensionID, err)
+			continue
+		}
+
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			// Matched without the route prefix, so READMEs rewritten before
+			// assets.url_prefix changed still count.
+			localPath := fmt.Sprintf("/%s/%s", extensionID, url.PathEscape(file.Name()))
+			assets = append(assets, cachedAsset{
+				path:       filepath.Join(assetsDir, extensionID, file.Name()),
+				size:       info.Size(),
+				accessed:   info.ModTime(),
+				referenced: readme != "" && strings.Contains(readme, localPath),
+			})
+		}
+	}
+	return assets, nil
+}
+
+// parseAge parses a duration that, unlike time.ParseDuration, also accepts
+// days, e.g. "30d".
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// parseSize parses a byte size with an optional KB, MB or GB suffix (powers
+// of 1024), e.g. "2GB".
+func parseSize(value string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			upper, multiplier = strings.TrimSpace(number), unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size such as 500MB or 2GB", value)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	publisherDomain      string
+	publisherDisplayName string
+	publisherVerified    bool
+)
+
+var publisherCmd = &cobra.Command{
+	Use:   "publisher",
+	Short: "Manages publisher details shown in the gallery",
+	Long: `Manages publisher details shown in the gallery. A publisher with a verified
+domain is shown with a checkmark by VS Code and VSCodium.
+
+Examples:
+  littlevsx publisher set ms-python --domain microsoft.com --verified
+  littlevsx publisher list
+  littlevsx publisher remove ms-python`,
+}
+
+var publisherSetCmd = &cobra.Command{
+	Use:   "set PUBLISHER",
+	Short: "Sets the domain, verification or display name of a publisher",
+	Long: `Sets the domain, verification or display name of a publisher. Only the
+given flags are changed; use --verified=false to remove the verification.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPublisherSet(cmd, args[0])
+	},
+}
+
+var publisherListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists publishers with stored details",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPublisherList()
+	},
+}
+
+var publisherRemoveCmd = &cobra.Command{
+	Use:   "remove PUBLISHER",
+	Short: "Removes the stored details of a publisher",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPublisherRemove(args[0])
+	},
+}
+
+func init() {
+	publisherSetCmd.Flags().StringVar(&publisherDomain, "domain", "", "Publisher domain, e.g. microsoft.com")
+	publisherSetCmd.Flags().BoolVar(&publisherVerified, "verified", false, "Mark the domain as verified")
+	publisherSetCmd.Flags().StringVar(&publisherDisplayName, "display-name", "", "Name shown instead of the publisher ID")
+
+	publisherCmd.AddCommand(publisherSetCmd)
+	publisherCmd.AddCommand(publisherListCmd)
+	publisherCmd.AddCommand(publisherRemoveCmd)
+	rootCmd.AddCommand(publisherCmd)
+}
+
+func runPublisherSet(cmd *cobra.Command, name string) error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	pub, exists := extManager.GetPublisher(name)
+	if !exists {
+		pub = &models.Publisher{Name: name}
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("domain") {
+		pub.Domain = normalizeDomain(publisherDomain)
+	}
+	if flags.Changed("verified") {
+		pub.Verified = publisherVerified
+	}
+	if flags.Changed("display-name") {
+		pub.DisplayName = publisherDisplayName
+	}
+
+	if pub.Verified && pub.Domain == "" {
+		return fmt.Errorf("--verified requires a domain, set one with --domain")
+	}
+
+	if err := extManager.SetPublisher(pub); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Publisher %s updated\n", pub.Name)
+	printPublisher(pub)
+	return nil
+}
+
+// normalizeDomain adds the https scheme the gallery API uses for publisher
+// domains when it is missing.
+func normalizeDomain(domain string) string {
+	domain = strings.TrimSpace(domain)
+	if domain == "" || strings.Contains(domain, "://") {
+		return domain
+	}
+	return "https://" + domain
+}
+
+func printPublisher(pub *models.Publisher) {
+	if pub.DisplayName != "" {
+		fmt.Printf("   Display name: %s\n", pub.DisplayName)
+	}
+	if pub.Domain != "" {
+		fmt.Printf("   Domain: %s\n", pub.Domain)
+	}
+	fmt.Printf("   Verified: %t\n", pub.Verified)
+}
+
+func runPublisherList() error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	publishers := extManager.GetPublishers()
+	if len(publishers) == 0 {
+		fmt.Println("ℹ️  No publisher details stored")
+		return nil
+	}
+
+	names := make([]string, 0, len(publishers))
+	for name := range publishers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PUBLISHER\tDISPLAY NAME\tDOMAIN\tVERIFIED")
+	for _, name := range names {
+		pub := publishers[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", pub.Name, pub.DisplayName, pub.Domain, pub.Verified)
+	}
+	return w.Flush()
+}
+
+func runPublisherRemove(name string) error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	if err := extManager.DeletePublisher(name); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Details of publisher %s removed\n", name)
+	return nil
+}
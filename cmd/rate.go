@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+
+	"littlevsx/internal/extensions"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rateAverage float64
+	rateCount   int64
+	rateClear   bool
+	rateAll     bool
+)
+
+var rateCmd = &cobra.Command{
+	Use:   "rate [EXTENSION_ID]",
+	Short: "Sets or clears the rating shown for an extension",
+	Long: `Sets the average rating and rating count shown for an extension in the
+gallery, or clears them with --clear. Extensions without a rating are shown
+as unrated. download and update replace the rating with the marketplace's
+when it has one.
+
+Databases created by older versions store a rating of 5.0 from 100 reviews
+for every extension; "rate --clear --all" resets them.
+
+Examples:
+  littlevsx rate ms-python.python --average 4.2 --count 37
+  littlevsx rate ms-python.python --clear
+  littlevsx rate --clear --all`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runRate(cmd, args)
+	},
+}
+
+func init() {
+	rateCmd.Flags().Float64Var(&rateAverage, "average", 0, "Average rating, from 0 to 5")
+	rateCmd.Flags().Int64Var(&rateCount, "count", 0, "Number of ratings the average is based on")
+	rateCmd.Flags().BoolVar(&rateClear, "clear", false, "Remove the rating")
+	rateCmd.Flags().BoolVar(&rateAll, "all", false, "With --clear, remove the rating of every extension")
+	rootCmd.AddCommand(rateCmd)
+}
+
+func runRate(cmd *cobra.Command, args []string) error {
+	if rateAll {
+		if !rateClear || len(args) > 0 {
+			return fmt.Errorf("--all is only valid with --clear and no extension ID")
+		}
+	} else if len(args) == 0 {
+		return fmt.Errorf("an extension ID is required")
+	}
+
+	if !rateClear {
+		if !cmd.Flags().Changed("average") || !cmd.Flags().Changed("count") {
+			return fmt.Errorf("--average and --count are required unless --clear is given")
+		}
+		if rateAverage < 0 || rateAverage > 5 {
+			return fmt.Errorf("--average must be between 0 and 5")
+		}
+		if rateCount < 1 {
+			return fmt.Errorf("--count must be positive")
+		}
+	}
+
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	if rateAll {
+		cleared, err := extManager.ClearRatings()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Cleared the rating of %d extensions\n", cleared)
+		return nil
+	}
+
+	extensionID := args[0]
+	if rateClear {
+		if err := extManager.SetRating(extensionID, 0, 0); err != nil {
+			return err
+		}
+		fmt.Printf("✅ %s is no longer rated\n", extensionID)
+		return nil
+	}
+
+	if err := extManager.SetRating(extensionID, rateAverage, rateCount); err != nil {
+		return err
+	}
+	fmt.Printf("✅ %s is rated %.1f from %d ratings\n", extensionID, rateAverage, rateCount)
+	return nil
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/models"
+
+	"github.com/spf13/cobra"
+)
+
+var reindexClear bool
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuilds the database from the .vsix files in the extensions directory",
+	Long: `Reads every .vsix file in the extensions directory and its subdirectories
+and stores it in the database, processing README assets as download does.
+Use it to recover the catalog after the database was lost or corrupted.
+
+Every version on disk is indexed, with each of its target platform builds,
+and the newest becomes the latest. Pinned extensions keep the versions in the database. With --clear
+the database is emptied first; featured marks, pins, ratings and source
+marketplaces are then lost as they are not stored in the .vsix.
+
+Examples:
+  littlevsx reindex
+  littlevsx reindex --clear --no-assets
+  littlevsx reindex --prefetch`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runReindex()
+	},
+}
+
+func init() {
+	reindexCmd.Flags().BoolVar(&reindexClear, "clear", false, "Remove every extension from the database first")
+	reindexCmd.Flags().BoolVar(&prefetch, "prefetch", false, "Extract the package.json, vsixmanifest and icon into the assets directory after indexing")
+	reindexCmd.Flags().BoolVar(&noAssets, "no-assets", false, "Keep README images at their remote URLs instead of downloading them (overrides assets.process)")
+	rootCmd.AddCommand(reindexCmd)
+}
+
+func runReindex() error {
+	config := config.GetConfig()
+
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	files, err := extManager.ListVSIXFiles(config.ExtensionsDir, true)
+	if err != nil {
+		return fmt.Errorf("error listing extensions directory: %w", err)
+	}
+
+	var builds []*models.Extension
+	failed := 0
+	for _, file := range files {
+		ext, err := extManager.ReadExtensionInfo(file)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping %s: %v\n", file, err)
+			failed++
+			continue
+		}
+		builds = append(builds, ext)
+	}
+
+	if reindexClear {
+		if err := extManager.GetDB().DeleteAllExtensions(); err != nil {
+			return fmt.Errorf("error clearing database: %w", err)
+		}
+		fmt.Println("Database cleared")
+	}
+
+	// indexed and storeFailed are only touched by the indexer until it is
+	// closed.
+	indexer := extManager.StartIndexer()
+	var indexed, storeFailed int
+	for _, ext := range builds {
+		if current, exists := extManager.GetByID(ext.ID); exists && current.Pinned {
+			fmt.Printf("\n📌 Skipping %s %s: %s is pinned at %s\n", ext.ID, ext.Version, ext.ID, current.Version)
+			continue
+		}
+		fmt.Printf("\nIndexing %s %s (%s)\n", ext.ID, ext.Version, ext.TargetPlatform)
+		err := queueDownloadedExtension(extManager, indexer, ext.FilePath, "", "", nil, func(err error) {
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				storeFailed++
+				return
+			}
+			indexed++
+		})
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			failed++
+		}
+	}
+	indexer.Close()
+	failed += storeFailed
+
+	fmt.Printf("\n✅ Indexed %d extensions from %d files, %d failed\n", indexed, len(files), failed)
+	if prefetch {
+		fmt.Printf("✅ Prefetched %d files\n", prefetchedFiles.Load())
+	}
+	return nil
+}
@@ -1,16 +1,23 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
 	"os"
 
+	"littlevsx/internal/config"
+	"littlevsx/internal/utils"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	cfgFile string
-	rootCmd = &cobra.Command{
+	cfgFile      string
+	strictConfig bool
+	rootCmd      = &cobra.Command{
 		Use:   "littlevsx",
 		Short: "Marketplace for Visual Studio Code",
 	}
@@ -23,6 +30,7 @@ func Execute() error {
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "path to config file (default ./config.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&strictConfig, "strict", false, "treat unknown config keys and type mismatches as errors")
 }
 
 func initConfig() {
@@ -36,9 +44,68 @@ func initConfig() {
 
 	viper.AutomaticEnv()
 
-	if err := viper.ReadInConfig(); err == nil {
+	readConfigFile()
+	validateConfig()
+	setupLogOutput()
+}
+
+// readConfigFile loads the config file. Running without one is fine as every
+// setting has a default, but a file that exists and cannot be parsed is fatal
+// rather than silently replaced by defaults.
+func readConfigFile() {
+	err := viper.ReadInConfig()
+	if err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
-	} else {
-		fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
+		return
+	}
+
+	var notFound viper.ConfigFileNotFoundError
+	var parseErr viper.ConfigParseError
+	switch {
+	case errors.As(err, &notFound):
+		fmt.Fprintln(os.Stderr, "No config file found in the current directory, using defaults")
+		return
+	case errors.Is(err, fs.ErrNotExist):
+		fmt.Fprintf(os.Stderr, "Error: config file %s not found\n", cfgFile)
+	case errors.As(err, &parseErr):
+		fmt.Fprintf(os.Stderr, "Error: invalid config file %s: %v\n", viper.ConfigFileUsed(), parseErr.Unwrap())
+	default:
+		fmt.Fprintf(os.Stderr, "Error reading config file %s: %v\n", viper.ConfigFileUsed(), err)
+	}
+	os.Exit(1)
+}
+
+func validateConfig() {
+	problems := config.Validate()
+	if len(problems) == 0 {
+		return
+	}
+
+	prefix := "Warning"
+	if strictConfig {
+		prefix = "Error"
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", prefix, problem)
+	}
+
+	if strictConfig {
+		fmt.Fprintf(os.Stderr, "Invalid configuration in %s (%d problems)\n", viper.ConfigFileUsed(), len(problems))
+		os.Exit(1)
+	}
+}
+
+func setupLogOutput() {
+	cfg := config.GetConfig()
+	if cfg.LogFile == "" {
+		return
 	}
+
+	writer, err := utils.NewRotatingWriter(cfg.LogFile, cfg.LogMaxSize, cfg.LogMaxAge, cfg.LogMaxBackups)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening log file, logging to stderr: %v\n", err)
+		return
+	}
+
+	log.SetOutput(writer)
 }
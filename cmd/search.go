@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"littlevsx/internal/database"
+	"littlevsx/internal/extensions"
+
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search QUERY",
+	Short: "Searches the extensions in the database",
+	Long: `Lists the extensions whose name, display name, description or publisher
+contains QUERY, most recently updated first. At most search.max_results
+extensions are shown; when more match, the total is reported so the query
+can be refined.
+
+With --ndjson all matches are written as one JSON object per line, without
+the search.max_results limit, reading them from the database a page at a
+time.
+
+Examples:
+  littlevsx search python
+  littlevsx search python --ndjson > python.jsonl`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runSearch(args[0])
+	},
+}
+
+func init() {
+	searchCmd.Flags().BoolVar(&listNDJSON, "ndjson", false, "Write all matches as JSON lines")
+	rootCmd.AddCommand(searchCmd)
+}
+
+func runSearch(query string) error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	if listNDJSON {
+		db := extManager.GetDB()
+		return writeNDJSON(os.Stdout, func(page, size int) ([]database.ExtensionDB, error) {
+			exts, _, err := db.SearchExtensions(query, page, size)
+			return exts, err
+		})
+	}
+
+	exts, total := extManager.Search(query)
+	if len(exts) == 0 {
+		fmt.Printf("ℹ️  No extensions matching %q found\n", query)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tVERSION\tPLATFORM\tNAME")
+	for _, ext := range exts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ext.ID, ext.Version, ext.TargetPlatform, ext.DisplayName)
+	}
+	w.Flush()
+
+	if total > int64(len(exts)) {
+		fmt.Printf("\nShowing %d of %d extensions; refine the query to see the rest\n", len(exts), total)
+	} else {
+		fmt.Printf("\nFound %d extensions\n", total)
+	}
+	return nil
+}
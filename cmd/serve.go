@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,12 +16,18 @@ import (
 	"littlevsx/internal/server"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Starts the HTTP server for the marketplace",
-	Long:  `Starts the HTTP server that provides the API to fetch VS Code extensions.`,
+	Long: `Starts the HTTP server that provides the API to fetch VS Code extensions.
+
+Send SIGHUP to re-read the config file. Request timeout, compression, query
+cache and upstream health check settings are applied immediately, and the
+TLS certificate is re-read from its files; other changes are logged and take
+effect after a restart.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
 		return runServe()
@@ -48,16 +56,27 @@ func runServe() error {
 
 	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
 
+	scheme := "http"
 	if config.UseHTTPS {
-		fmt.Printf("Server started. Marketplace is available at: %s://%s\n", "https", addr)
+		scheme = "https"
+	}
+	if config.UnixSocket != "" {
+		fmt.Printf("Server started. Marketplace is available on unix socket %s (%s), clients use %s\n", config.UnixSocket, scheme, config.BaseURL)
 	} else {
-		fmt.Printf("Server started. Marketplace is available at: %s://%s\n", "http", addr)
+		fmt.Printf("Server started. Marketplace is available at: %s://%s\n", scheme, addr)
+	}
+	if extManager.Count() == 0 {
+		fmt.Println("⚠️  No extensions present — run `littlevsx download ...` to populate the marketplace")
+		log.Printf("No extensions present — run `littlevsx download ...` to populate")
 	}
 	fmt.Println("Press Ctrl+C to stop the server")
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+
 	errChan := make(chan error, 1)
 	go func() {
 		if err := srv.ListenAndServe(addr); err != nil && err != http.ErrServerClosed {
@@ -65,11 +84,17 @@ func runServe() error {
 		}
 	}()
 
-	select {
-	case sig := <-sigChan:
-		fmt.Printf("\nSignal received: %v. Stopping server...\n", sig)
-	case err := <-errChan:
-		return fmt.Errorf("server start error: %w", err)
+wait:
+	for {
+		select {
+		case <-reloadChan:
+			reloadConfig(srv)
+		case sig := <-sigChan:
+			fmt.Printf("\nSignal received: %v. Stopping server...\n", sig)
+			break wait
+		case err := <-errChan:
+			return fmt.Errorf("server start error: %w", err)
+		}
 	}
 
 	fmt.Println("Performing graceful shutdown...")
@@ -85,3 +110,23 @@ func runServe() error {
 	fmt.Println("Server stopped successfully")
 	return nil
 }
+
+// reloadConfig re-reads the config file and hands it to the server. An
+// unreadable file keeps the running configuration.
+func reloadConfig(srv *server.Server) {
+	log.Printf("Config reload: re-reading %s", viper.ConfigFileUsed())
+
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			log.Printf("Config reload: failed, keeping current configuration: %v", err)
+			return
+		}
+	}
+
+	for _, problem := range config.Validate() {
+		log.Printf("Config reload: warning: %s", problem)
+	}
+
+	srv.Reload(config.GetConfig())
+}
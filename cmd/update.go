@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/marketplace"
+	"littlevsx/internal/models"
+	"littlevsx/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var updateAll bool
+
+var updateCmd = &cobra.Command{
+	Use:   "update (EXTENSION_ID | --all)",
+	Short: "Downloads newer versions of extensions from their marketplace",
+	Long: `Looks up the latest version of an extension in the marketplace it was
+downloaded from and, when it is newer than the stored one, downloads it for
+every target platform the stored version has a build for and adds it to the
+database. Older versions stay
+in the database; use clean to remove their files.
+
+Extensions added with import have no recorded marketplace, and pinned
+extensions (see pin) are frozen; both are skipped.
+
+Examples:
+  littlevsx update ms-python.python
+  littlevsx update --all
+  littlevsx update --all --no-assets`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if updateAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		if len(args) != 1 {
+			return fmt.Errorf("requires an extension ID or --all")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		extensionID := ""
+		if len(args) == 1 {
+			extensionID = args[0]
+		}
+		return runUpdate(extensionID)
+	},
+}
+
+func init() {
+	updateCmd.Flags().BoolVar(&updateAll, "all", false, "Update every extension with a recorded marketplace")
+	updateCmd.Flags().BoolVar(&prefetch, "prefetch", false, "Extract the package.json, vsixmanifest and icon into the assets directory after indexing")
+	updateCmd.Flags().BoolVar(&noAssets, "no-assets", false, "Keep README images at their remote URLs instead of downloading them (overrides assets.process)")
+	rootCmd.AddCommand(updateCmd)
+}
+
+func runUpdate(extensionID string) error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	var exts []*models.Extension
+	if updateAll {
+		exts = extManager.GetAll()
+	} else {
+		ext, exists := extManager.GetByID(extensionID)
+		if !exists {
+			return fmt.Errorf("extension %s not found", extensionID)
+		}
+		exts = []*models.Extension{ext}
+	}
+
+	factory := marketplace.NewFactory()
+	providers := make(map[string]marketplace.MarketplaceProvider)
+
+	var updated, skipped, failed int
+	for _, ext := range exts {
+		if ext.MetadataOnly {
+			continue
+		}
+		if !hasMarketplace(ext) {
+			fmt.Printf("⏭️  %s: skipped, no recorded marketplace\n", ext.ID)
+			skipped++
+			continue
+		}
+		if ext.Pinned {
+			fmt.Printf("📌 %s: skipped, pinned at %s\n", ext.ID, ext.Version)
+			skipped++
+			continue
+		}
+
+		provider, ok := providers[ext.Source]
+		if !ok {
+			if provider, err = factory.CreateByType(marketplace.MarketplaceType(ext.Source)); err != nil {
+				fmt.Printf("❌ %s: %v\n", ext.ID, err)
+				failed++
+				continue
+			}
+			providers[ext.Source] = provider
+		}
+
+		newVersion, err := updateExtension(extManager, provider, ext)
+		switch {
+		case err != nil:
+			fmt.Printf("❌ %s: %v\n", ext.ID, err)
+			failed++
+		case newVersion == "":
+			fmt.Printf("✅ %s: %s is up to date\n", ext.ID, ext.Version)
+			skipped++
+		default:
+			fmt.Printf("⬆️  %s: updated %s -> %s\n", ext.ID, ext.Version, newVersion)
+			updated++
+		}
+	}
+
+	fmt.Printf("\n%d updated, %d skipped, %d failed\n", updated, skipped, failed)
+	if prefetch {
+		fmt.Printf("✅ Prefetched %d files\n", prefetchedFiles.Load())
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d extensions could not be updated", failed)
+	}
+	return nil
+}
+
+// updateExtension downloads and stores the latest version of ext from
+// provider, for each target platform of the stored version, if it is newer
+// than the stored one. It returns the new version, or "" when ext is up to
+// date.
+func updateExtension(extManager *extensions.Manager, provider marketplace.MarketplaceProvider, ext *models.Extension) (string, error) {
+	if err := config.GetConfig().CheckPolicy(ext.ID); err != nil {
+		return "", fmt.Errorf("update rejected: %w", err)
+	}
+
+	builds := extManager.GetBuilds(ext.ID, ext.Version)
+	if len(builds) == 0 {
+		builds = []*models.Extension{ext}
+	}
+	newVersion := ""
+	for _, build := range builds {
+		version, err := updateBuild(extManager, provider, build)
+		if err != nil {
+			return newVersion, fmt.Errorf("%s build: %w", build.TargetPlatform, err)
+		}
+		if version != "" {
+			newVersion = version
+		}
+	}
+	return newVersion, nil
+}
+
+// updateBuild is updateExtension for the target platform of one build.
+func updateBuild(extManager *extensions.Manager, provider marketplace.MarketplaceProvider, ext *models.Extension) (string, error) {
+	config := config.GetConfig()
+
+	info, err := provider.GetExtensionInfoByID(ext.ID, ext.TargetPlatform)
+	if err != nil {
+		return "", fmt.Errorf("error getting extension information: %w", err)
+	}
+	if utils.CompareVersions(info.Version, ext.Version) <= 0 {
+		return "", nil
+	}
+
+	fmt.Printf("\nDownloading %s %s from %s...\n", ext.ID, info.Version, provider.GetName())
+	result, err := provider.DownloadExtension(info, config.ExtensionsDir)
+	if err != nil {
+		return "", fmt.Errorf("error downloading extension: %w", err)
+	}
+
+	err = addDownloadedExtension(extManager, result.FilePath, ext.Source, result.SHA256, info.Statistics)
+	if err != nil {
+		if result.WasDownloaded && discardDownload(err) {
+			os.Remove(result.FilePath)
+		}
+		return "", err
+	}
+	return info.Version, nil
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"littlevsx/internal/database"
+	"littlevsx/internal/extensions"
+
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Checks the catalog for missing packages and dependencies",
+	Long: `Checks every extension in the database: its .vsix package must be
+readable (metadata-only entries are expected to have their packages
+elsewhere and are skipped), and the extensions it declares in
+extensionDependencies and extensionPack must be in the catalog, since
+clients install them from the mirror too.
+
+With --checksums the package of every stored version is also hashed and
+compared with the SHA-256 recorded when it was added, which detects files
+that were truncated or replaced since. Versions added before checksums were
+recorded are reported as unchecked.
+
+Examples:
+  littlevsx verify
+  littlevsx verify --checksums`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runVerify()
+	},
+}
+
+var verifyChecksums bool
+
+func init() {
+	verifyCmd.Flags().BoolVar(&verifyChecksums, "checksums", false, "Also compare every stored package with its recorded SHA-256")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify() error {
+	extManager, err := extensions.New()
+	if err != nil {
+		return fmt.Errorf("error initializing extension manager: %w", err)
+	}
+	defer extManager.Close()
+
+	exts := extManager.GetAll()
+	problems := 0
+	for _, ext := range exts {
+		if !ext.MetadataOnly {
+			if _, err := extManager.Storage().Stat(ext.FilePath); err != nil {
+				fmt.Printf("❌ %s: package unavailable: %v\n", ext.ID, err)
+				problems++
+			}
+		}
+
+		missing, err := extManager.MissingDependencies(ext)
+		if err != nil {
+			return fmt.Errorf("error checking dependencies of %s: %w", ext.ID, err)
+		}
+		if len(missing) > 0 {
+			fmt.Printf("❌ %s: dependencies not in the catalog: %s\n", ext.ID, strings.Join(missing, ", "))
+			problems++
+		}
+	}
+
+	if verifyChecksums {
+		mismatched, err := verifyPackageChecksums(extManager)
+		if err != nil {
+			return err
+		}
+		problems += mismatched
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems found in %d extensions", problems, len(exts))
+	}
+	fmt.Printf("✅ Verified %d extensions\n", len(exts))
+	return nil
+}
+
+// verifyPackageChecksums hashes the package of every stored version and
+// returns how many do not match their recorded checksum.
+func verifyPackageChecksums(extManager *extensions.Manager) (int, error) {
+	mismatched, checked, unchecked := 0, 0, 0
+	for page := 1; ; page++ {
+		exts, err := extManager.GetDB().GetAllExtensionVersions(page, dumpPageSize)
+		if err != nil {
+			return 0, fmt.Errorf("error reading extensions: %w", err)
+		}
+
+		for _, ext := range database.ToExtensionSlice(exts) {
+			if ext.MetadataOnly {
+				continue
+			}
+			if ext.SHA256 == "" {
+				unchecked++
+				continue
+			}
+			checksum, err := extManager.PackageSHA256(ext)
+			if err != nil {
+				fmt.Printf("❌ %s %s: %v\n", ext.ID, ext.Version, err)
+				mismatched++
+				continue
+			}
+			checked++
+			if checksum != ext.SHA256 {
+				fmt.Printf("❌ %s %s: checksum mismatch: %s has %s, recorded %s\n", ext.ID, ext.Version, ext.FilePath, checksum, ext.SHA256)
+				mismatched++
+			}
+		}
+
+		if len(exts) < dumpPageSize {
+			break
+		}
+	}
+
+	fmt.Printf("Checked the checksums of %d packages", checked)
+	if unchecked > 0 {
+		fmt.Printf(", %d without a recorded checksum (reindex records them)", unchecked)
+	}
+	fmt.Println()
+	return mismatched, nil
+}
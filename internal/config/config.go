@@ -1,25 +1,251 @@
 package config
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/spf13/viper"
 )
 
+// Config holds the resolved settings. The JSON names are the config keys the
+// values were read from.
 type Config struct {
-	Port     int
-	Host     string
-	UseHTTPS bool
-	CertFile string
-	KeyFile  string
-	BaseURL  string
+	Port     int    `json:"server.port"`
+	Host     string `json:"server.host"`
+	UseHTTPS bool   `json:"server.https"`
+	CertFile string `json:"server.cert_file"`
+	KeyFile  string `json:"server.key_file"`
+	BaseURL  string `json:"server.base_url"`
+
+	UnixSocket     string `json:"server.unix_socket"`
+	UnixSocketMode string `json:"server.unix_socket_mode"`
+
+	RequestTimeout int    `json:"server.request_timeout"`
+	TrailingSlash  string `json:"server.trailing_slash"`
+
+	CompressionEnabled bool `json:"compression.enabled"`
+	BrotliEnabled      bool `json:"compression.brotli"`
+
+	DBPath      string `json:"database.path"`
+	AutoMigrate bool   `json:"database.auto_migrate"`
+	LogQueries  bool   `json:"database.log_queries"`
+
+	ExtensionsDir         string `json:"extensions.directory"`
+	DefaultTargetPlatform string `json:"extensions.default_target_platform"`
+
+	StorageBackend  string `json:"storage.backend"`
+	S3Endpoint      string `json:"storage.s3.endpoint"`
+	S3Region        string `json:"storage.s3.region"`
+	S3Bucket        string `json:"storage.s3.bucket"`
+	S3Prefix        string `json:"storage.s3.prefix"`
+	S3AccessKey     string `json:"storage.s3.access_key"`
+	S3SecretKey     string `json:"storage.s3.secret_key"`
+	S3SecretKeyFile string `json:"storage.s3.secret_key_file"`
+	S3PathStyle     bool   `json:"storage.s3.path_style"`
+
+	AssetsDir                 string `json:"assets.directory"`
+	AssetsProcess             bool   `json:"assets.process"`
+	AssetsURLPrefix           string `json:"assets.url_prefix"`
+	AssetsCacheTime           int    `json:"assets.cache_time"`
+	AssetsDownloadConcurrency int    `json:"assets.download_concurrency"`
+	AssetsDownloadTimeout     int    `json:"assets.download_timeout"`
+	AssetsMaxSizeMB           int    `json:"assets.max_size_mb"`
+
+	// AssetTypePaths holds "AssetType=path" entries; see AssetPaths.
+	AssetTypePaths []string `json:"assets.type_paths"`
+	// AssetStubTypes are answered with an empty 200 response.
+	AssetStubTypes []string `json:"assets.stub_types"`
+
+	LogFile       string `json:"logging.file"`
+	LogMaxSize    int    `json:"logging.max_size"`
+	LogMaxAge     int    `json:"logging.max_age"`
+	LogMaxBackups int    `json:"logging.max_backups"`
+
+	DebugEnabled bool `json:"debug.enabled"`
+
+	MarketplaceOrder       []string `json:"marketplace.order"`
+	MarketplacePingTimeout int      `json:"marketplace.ping_timeout"`
+
+	DownloadRetries    int `json:"download.retries"`
+	DownloadRetryDelay int `json:"download.retry_delay"`
+
+	HealthCheckUpstream bool `json:"health.check_upstream"`
+
+	PolicyAllow []string `json:"policy.allow"`
+	PolicyDeny  []string `json:"policy.deny"`
+
+	// EngineMin and EngineMax bound the VS Code versions the mirror serves,
+	// see CheckEngine.
+	EngineMin string `json:"engines.min"`
+	EngineMax string `json:"engines.max"`
+
+	FilterByEngine bool `json:"api.filter_by_engine"`
+
+	DefaultRating      float64 `json:"statistics.default_rating"`
+	DefaultRatingCount int     `json:"statistics.default_rating_count"`
+	DefaultDownloads   int     `json:"statistics.default_downloads"`
+	TrackUsers         bool    `json:"statistics.track_users"`
+
+	SignaturesEnabled bool `json:"signatures.enabled"`
+
+	SearchMaxResults int `json:"search.max_results"`
+
+	QueryCacheEnabled bool `json:"cache.query_enabled"`
+	QueryCacheTTL     int  `json:"cache.query_ttl"`
+	ExtractCacheMaxMB int  `json:"cache.extract_max_mb"`
+}
+
+type keyType int
+
+const (
+	stringKey keyType = iota
+	intKey
+	boolKey
+	listKey
+	floatKey
+)
+
+func (t keyType) String() string {
+	switch t {
+	case intKey:
+		return "integer"
+	case boolKey:
+		return "boolean"
+	case listKey:
+		return "list"
+	case floatKey:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// knownKeys lists every configuration key understood by LittleVSX.
+var knownKeys = map[string]keyType{
+	"server.port":      intKey,
+	"server.host":      stringKey,
+	"server.https":     boolKey,
+	"server.cert_file": stringKey,
+	"server.key_file":  stringKey,
+	"server.base_url":  stringKey,
+
+	"server.request_timeout":  intKey,
+	"server.unix_socket":      stringKey,
+	"server.unix_socket_mode": stringKey,
+	"server.trailing_slash":   stringKey,
+
+	"compression.enabled": boolKey,
+	"compression.brotli":  boolKey,
+
+	"database.path":         stringKey,
+	"database.auto_migrate": boolKey,
+	"database.log_queries":  boolKey,
+
+	"extensions.directory":               stringKey,
+	"extensions.default_target_platform": stringKey,
+
+	"storage.backend":            stringKey,
+	"storage.s3.endpoint":        stringKey,
+	"storage.s3.region":          stringKey,
+	"storage.s3.bucket":          stringKey,
+	"storage.s3.prefix":          stringKey,
+	"storage.s3.access_key":      stringKey,
+	"storage.s3.secret_key":      stringKey,
+	"storage.s3.secret_key_file": stringKey,
+	"storage.s3.path_style":      boolKey,
+
+	"assets.directory":            stringKey,
+	"assets.process":              boolKey,
+	"assets.url_prefix":           stringKey,
+	"assets.cache_time":           intKey,
+	"assets.download_concurrency": intKey,
+	"assets.download_timeout":     intKey,
+	"assets.max_size_mb":          intKey,
+	"assets.type_paths":           listKey,
+	"assets.stub_types":           listKey,
+
+	"logging.level":       stringKey,
+	"logging.format":      stringKey,
+	"logging.file":        stringKey,
+	"logging.max_size":    intKey,
+	"logging.max_age":     intKey,
+	"logging.max_backups": intKey,
+
+	"debug.enabled": boolKey,
+
+	"marketplace.order":        listKey,
+	"marketplace.ping_timeout": intKey,
+
+	"download.retries":     intKey,
+	"download.retry_delay": intKey,
+
+	"health.check_upstream": boolKey,
+
+	"policy.allow": listKey,
+	"policy.deny":  listKey,
 
-	DBPath      string
-	AutoMigrate bool
-	LogQueries  bool
+	"engines.min": stringKey,
+	"engines.max": stringKey,
 
-	ExtensionsDir string
+	"api.filter_by_engine": boolKey,
 
-	AssetsDir       string
-	AssetsCacheTime int
+	"statistics.default_rating":       floatKey,
+	"statistics.default_rating_count": intKey,
+	"statistics.default_downloads":    intKey,
+	"statistics.track_users":          boolKey,
+
+	"signatures.enabled": boolKey,
+
+	"search.max_results": intKey,
+
+	"cache.query_enabled":  boolKey,
+	"cache.query_ttl":      intKey,
+	"cache.extract_max_mb": intKey,
+}
+
+func init() {
+	viper.SetDefault("server.host", "0.0.0.0")
+	viper.SetDefault("server.port", 8080)
+	viper.SetDefault("server.base_url", "http://localhost:8080")
+	viper.SetDefault("server.unix_socket_mode", "0660")
+	viper.SetDefault("server.trailing_slash", "redirect")
+	viper.SetDefault("database.path", "./littlevsx.db")
+	viper.SetDefault("database.auto_migrate", true)
+	viper.SetDefault("extensions.directory", "./extensions")
+	viper.SetDefault("storage.backend", "local")
+	viper.SetDefault("storage.s3.region", "us-east-1")
+	viper.SetDefault("storage.s3.path_style", true)
+	viper.SetDefault("assets.directory", "./extensions/assets")
+	viper.SetDefault("assets.process", true)
+	viper.SetDefault("assets.url_prefix", DefaultAssetsURLPrefix)
+	viper.SetDefault("assets.cache_time", 3600)
+	viper.SetDefault("assets.download_concurrency", 4)
+	viper.SetDefault("assets.download_timeout", 30)
+	viper.SetDefault("assets.max_size_mb", 10)
+	viper.SetDefault("assets.type_paths", []string{
+		"Microsoft.VisualStudio.Services.Content.Changelog=extension/CHANGELOG.md",
+		"Microsoft.VisualStudio.Services.Content.Changelog=extension/changelog.md",
+	})
+	viper.SetDefault("assets.stub_types", []string{
+		"Microsoft.VisualStudio.Services.Content.Pricing",
+		"Microsoft.VisualStudio.Services.CustomerQnALink",
+		"Microsoft.VisualStudio.Services.EnableMarketplaceQnA",
+	})
+	viper.SetDefault("logging.level", "info")
+	viper.SetDefault("logging.format", "json")
+	viper.SetDefault("compression.enabled", true)
+	viper.SetDefault("compression.brotli", true)
+	viper.SetDefault("marketplace.order", []string{"open-vsx", "microsoft"})
+	viper.SetDefault("marketplace.ping_timeout", 5)
+	viper.SetDefault("download.retries", 3)
+	viper.SetDefault("download.retry_delay", 1)
+	viper.SetDefault("signatures.enabled", true)
+	viper.SetDefault("search.max_results", 1000)
+	viper.SetDefault("cache.query_enabled", true)
+	viper.SetDefault("cache.query_ttl", 30)
+	viper.SetDefault("cache.extract_max_mb", 64)
 }
 
 func GetConfig() Config {
@@ -31,13 +257,184 @@ func GetConfig() Config {
 		KeyFile:  viper.GetString("server.key_file"),
 		BaseURL:  viper.GetString("server.base_url"),
 
+		UnixSocket:     viper.GetString("server.unix_socket"),
+		UnixSocketMode: viper.GetString("server.unix_socket_mode"),
+
+		RequestTimeout: viper.GetInt("server.request_timeout"),
+		TrailingSlash:  viper.GetString("server.trailing_slash"),
+
+		CompressionEnabled: viper.GetBool("compression.enabled"),
+		BrotliEnabled:      viper.GetBool("compression.brotli"),
+
 		DBPath:      viper.GetString("database.path"),
 		AutoMigrate: viper.GetBool("database.auto_migrate"),
 		LogQueries:  viper.GetBool("database.log_queries"),
 
-		ExtensionsDir: viper.GetString("extensions.directory"),
+		ExtensionsDir:         viper.GetString("extensions.directory"),
+		DefaultTargetPlatform: viper.GetString("extensions.default_target_platform"),
+
+		StorageBackend:  viper.GetString("storage.backend"),
+		S3Endpoint:      viper.GetString("storage.s3.endpoint"),
+		S3Region:        viper.GetString("storage.s3.region"),
+		S3Bucket:        viper.GetString("storage.s3.bucket"),
+		S3Prefix:        viper.GetString("storage.s3.prefix"),
+		S3AccessKey:     viper.GetString("storage.s3.access_key"),
+		S3SecretKey:     viper.GetString("storage.s3.secret_key"),
+		S3SecretKeyFile: viper.GetString("storage.s3.secret_key_file"),
+		S3PathStyle:     viper.GetBool("storage.s3.path_style"),
+
+		AssetsDir:                 viper.GetString("assets.directory"),
+		AssetsProcess:             viper.GetBool("assets.process"),
+		AssetsURLPrefix:           viper.GetString("assets.url_prefix"),
+		AssetsCacheTime:           viper.GetInt("assets.cache_time"),
+		AssetsDownloadConcurrency: viper.GetInt("assets.download_concurrency"),
+		AssetsDownloadTimeout:     viper.GetInt("assets.download_timeout"),
+		AssetsMaxSizeMB:           viper.GetInt("assets.max_size_mb"),
+
+		AssetTypePaths: viper.GetStringSlice("assets.type_paths"),
+		AssetStubTypes: viper.GetStringSlice("assets.stub_types"),
+
+		LogFile:       viper.GetString("logging.file"),
+		LogMaxSize:    viper.GetInt("logging.max_size"),
+		LogMaxAge:     viper.GetInt("logging.max_age"),
+		LogMaxBackups: viper.GetInt("logging.max_backups"),
+
+		DebugEnabled: viper.GetBool("debug.enabled"),
+
+		MarketplaceOrder:       viper.GetStringSlice("marketplace.order"),
+		MarketplacePingTimeout: viper.GetInt("marketplace.ping_timeout"),
+
+		DownloadRetries:    viper.GetInt("download.retries"),
+		DownloadRetryDelay: viper.GetInt("download.retry_delay"),
+
+		HealthCheckUpstream: viper.GetBool("health.check_upstream"),
+
+		PolicyAllow: viper.GetStringSlice("policy.allow"),
+		PolicyDeny:  viper.GetStringSlice("policy.deny"),
+
+		EngineMin: viper.GetString("engines.min"),
+		EngineMax: viper.GetString("engines.max"),
+
+		FilterByEngine: viper.GetBool("api.filter_by_engine"),
+
+		DefaultRating:      viper.GetFloat64("statistics.default_rating"),
+		DefaultRatingCount: viper.GetInt("statistics.default_rating_count"),
+		DefaultDownloads:   viper.GetInt("statistics.default_downloads"),
+		TrackUsers:         viper.GetBool("statistics.track_users"),
+
+		SignaturesEnabled: viper.GetBool("signatures.enabled"),
+
+		SearchMaxResults: viper.GetInt("search.max_results"),
+
+		QueryCacheEnabled: viper.GetBool("cache.query_enabled"),
+		QueryCacheTTL:     viper.GetInt("cache.query_ttl"),
+		ExtractCacheMaxMB: viper.GetInt("cache.extract_max_mb"),
+	}
+}
+
+// DefaultAssetsURLPrefix is the path README assets are served under unless
+// assets.url_prefix says otherwise.
+const DefaultAssetsURLPrefix = "/_assets"
+
+// AssetsRoutePrefix returns assets.url_prefix with exactly one leading and
+// no trailing slash, e.g. "/vsx/_assets".
+func (c Config) AssetsRoutePrefix() string {
+	prefix := strings.Trim(c.AssetsURLPrefix, "/")
+	if prefix == "" {
+		return DefaultAssetsURLPrefix
+	}
+	return "/" + prefix
+}
+
+// AssetPaths parses assets.type_paths into the archive paths to try for each
+// asset type, in the order listed. Asset types are lower-cased, since clients
+// do not agree on their case. Entries without "=" are returned as invalid.
+func (c Config) AssetPaths() (map[string][]string, []string) {
+	paths := make(map[string][]string)
+	var invalid []string
+	for _, entry := range c.AssetTypePaths {
+		assetType, path, ok := strings.Cut(entry, "=")
+		assetType, path = strings.TrimSpace(assetType), strings.TrimSpace(path)
+		if !ok || assetType == "" || path == "" {
+			invalid = append(invalid, entry)
+			continue
+		}
+		key := strings.ToLower(assetType)
+		paths[key] = append(paths[key], path)
+	}
+	return paths, invalid
+}
+
+// Validate checks the loaded settings against the set of known keys and
+// returns a description of every unknown key and type mismatch found.
+func Validate() []string {
+	var problems []string
+
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		expected, ok := knownKeys[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown config key %q", key))
+			continue
+		}
+
+		value := viper.Get(key)
+		if !matchesType(value, expected) {
+			problems = append(problems, fmt.Sprintf("config key %q must be of type %s, got %T (%v)", key, expected, value, value))
+		}
+	}
+
+	return problems
+}
+
+func matchesType(value interface{}, expected keyType) bool {
+	if value == nil {
+		return true
+	}
 
-		AssetsDir:       viper.GetString("assets.directory"),
-		AssetsCacheTime: viper.GetInt("assets.cache_time"),
+	switch expected {
+	case intKey:
+		switch v := value.(type) {
+		case int, int32, int64, uint, uint32, uint64:
+			return true
+		case float64:
+			return v == float64(int64(v))
+		case string:
+			_, err := strconv.Atoi(v)
+			return err == nil
+		}
+		return false
+	case boolKey:
+		switch v := value.(type) {
+		case bool:
+			return true
+		case string:
+			_, err := strconv.ParseBool(v)
+			return err == nil
+		}
+		return false
+	case listKey:
+		switch value.(type) {
+		case []interface{}, []string, string:
+			return true
+		}
+		return false
+	case floatKey:
+		switch v := value.(type) {
+		case int, int32, int64, uint, uint32, uint64, float32, float64:
+			return true
+		case string:
+			_, err := strconv.ParseFloat(v, 64)
+			return err == nil
+		}
+		return false
+	default:
+		switch value.(type) {
+		case string, int, int64, float64, bool:
+			return true
+		}
+		return false
 	}
 }
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"littlevsx/internal/utils"
+)
+
+// CheckPolicy reports whether an extension may be added to the mirror under
+// policy.allow and policy.deny. Both hold glob patterns matched against the
+// extension ID without regard to case, e.g. "ms-python.*". A denied ID is
+// rejected even if it is also allowed; with a non-empty allow list, IDs that
+// match none of its patterns are rejected.
+func (c Config) CheckPolicy(extensionID string) error {
+	id := strings.ToLower(extensionID)
+
+	if pattern, ok := matchPattern(c.PolicyDeny, id); ok {
+		return fmt.Errorf("%s is blocked by policy.deny pattern %q", extensionID, pattern)
+	}
+	if len(c.PolicyAllow) == 0 {
+		return nil
+	}
+	if _, ok := matchPattern(c.PolicyAllow, id); !ok {
+		return fmt.Errorf("%s does not match any policy.allow pattern", extensionID)
+	}
+	return nil
+}
+
+func matchPattern(patterns []string, id string) (string, bool) {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(strings.ToLower(pattern), id); err == nil && matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// ErrEngineNotAllowed is wrapped by CheckEngine errors.
+var ErrEngineNotAllowed = errors.New("incompatible VS Code engine")
+
+// CheckEngine reports whether an extension whose package.json declares
+// engines.vscode as engine can be installed by some VS Code version between
+// engines.min and engines.max. Extensions requiring a newer VS Code than
+// engines.max, or only supporting versions older than engines.min, are
+// rejected.
+func (c Config) CheckEngine(extensionID, engine string) error {
+	r := utils.ParseEngineRange(engine)
+	if c.EngineMax != "" && r.Min != "" && utils.CompareVersions(r.Min, c.EngineMax) > 0 {
+		return fmt.Errorf("%w: %s requires VS Code %s, newer than engines.max %s", ErrEngineNotAllowed, extensionID, engine, c.EngineMax)
+	}
+	if c.EngineMin != "" && r.Max != "" && utils.CompareVersions(r.Max, c.EngineMin) <= 0 {
+		return fmt.Errorf("%w: %s requires VS Code %s, older than engines.min %s", ErrEngineNotAllowed, extensionID, engine, c.EngineMin)
+	}
+	return nil
+}
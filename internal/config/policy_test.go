@@ -0,0 +1,41 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   []string
+		deny    []string
+		id      string
+		wantErr string
+	}{
+		{"no policy", nil, nil, "acme.tool", ""},
+		{"allowed publisher", []string{"acme.*"}, nil, "acme.tool", ""},
+		{"not in allow list", []string{"acme.*"}, nil, "other.tool", "does not match any policy.allow pattern"},
+		{"denied publisher", nil, []string{"evil.*"}, "evil.tool", `blocked by policy.deny pattern "evil.*"`},
+		{"not denied", nil, []string{"evil.*"}, "acme.tool", ""},
+		{"deny wins over a broader allow", []string{"acme.*"}, []string{"acme.bad*"}, "acme.badtool", "blocked by policy.deny"},
+		{"deny wins over an exact allow", []string{"acme.tool"}, []string{"acme.*"}, "acme.tool", "blocked by policy.deny"},
+		{"allow still restricts what deny lets through", []string{"acme.*"}, []string{"evil.*"}, "other.tool", "does not match any policy.allow pattern"},
+		{"allowed beside a deny list", []string{"acme.*"}, []string{"acme.bad*"}, "acme.tool", ""},
+		{"case is ignored", []string{"MS-Python.*"}, []string{"Acme.Tool"}, "ms-python.PYTHON", ""},
+		{"case is ignored when denying", nil, []string{"Acme.Tool"}, "acme.tool", "blocked by policy.deny"},
+		{"invalid pattern matches nothing", []string{"acme.[", "other.*"}, nil, "acme.[", "does not match any policy.allow pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Config{PolicyAllow: tt.allow, PolicyDeny: tt.deny}.CheckPolicy(tt.id)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("CheckPolicy(%q) = %v, want allowed", tt.id, err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("CheckPolicy(%q) = %v, want an error containing %q", tt.id, err, tt.wantErr)
+			}
+		})
+	}
+}
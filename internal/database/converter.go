@@ -11,6 +11,8 @@ func ToDBExtension(ext *models.Extension) *ExtensionDB {
 	enginesJSON, _ := json.Marshal(ext.Engines)
 	categoriesJSON, _ := json.Marshal(ext.Categories)
 	tagsJSON, _ := json.Marshal(ext.Tags)
+	dependenciesJSON, _ := json.Marshal(ext.Dependencies)
+	extensionPackJSON, _ := json.Marshal(ext.ExtensionPack)
 
 	now := time.Now()
 	return &ExtensionDB{
@@ -46,6 +48,13 @@ func ToDBExtension(ext *models.Extension) *ExtensionDB {
 		Deprecated:       ext.Deprecated,
 		TargetPlatform:   ext.TargetPlatform,
 		ReadmeContent:    ext.ReadmeContent,
+		Featured:         ext.Featured,
+		Source:           ext.Source,
+		MetadataOnly:     ext.MetadataOnly,
+		Dependencies:     string(dependenciesJSON),
+		ExtensionPack:    string(extensionPackJSON),
+		Pinned:           ext.Pinned,
+		SHA256:           ext.SHA256,
 	}
 }
 
@@ -59,6 +68,12 @@ func ToExtension(dbExt *ExtensionDB) *models.Extension {
 	var tags []string
 	json.Unmarshal([]byte(dbExt.Tags), &tags)
 
+	var dependencies []string
+	json.Unmarshal([]byte(dbExt.Dependencies), &dependencies)
+
+	var extensionPack []string
+	json.Unmarshal([]byte(dbExt.ExtensionPack), &extensionPack)
+
 	return &models.Extension{
 		ID:               dbExt.ID,
 		Name:             dbExt.Name,
@@ -90,6 +105,23 @@ func ToExtension(dbExt *ExtensionDB) *models.Extension {
 		Deprecated:       dbExt.Deprecated,
 		TargetPlatform:   dbExt.TargetPlatform,
 		ReadmeContent:    dbExt.ReadmeContent,
+		Featured:         dbExt.Featured,
+		Source:           dbExt.Source,
+		MetadataOnly:     dbExt.MetadataOnly,
+		Dependencies:     dependencies,
+		ExtensionPack:    extensionPack,
+		Pinned:           dbExt.Pinned,
+		SHA256:           dbExt.SHA256,
+	}
+}
+
+func ToPublisher(dbPub *PublisherDB) *models.Publisher {
+	return &models.Publisher{
+		Name:        dbPub.Name,
+		DisplayName: dbPub.DisplayName,
+		Domain:      dbPub.Domain,
+		Verified:    dbPub.Verified,
+		UpdatedAt:   dbPub.UpdatedAt,
 	}
 }
 
@@ -2,13 +2,18 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"littlevsx/internal/config"
+	"littlevsx/internal/utils"
 
 	_ "modernc.org/sqlite"
 )
@@ -46,12 +51,55 @@ type ExtensionDB struct {
 	Deprecated       bool      `json:"deprecated"`
 	TargetPlatform   string    `json:"targetPlatform"`
 	ReadmeContent    string    `json:"readmeContent"`
+	Featured         bool      `json:"featured"`
+	Source           string    `json:"source"`
+	MetadataOnly     bool      `json:"metadataOnly"`
+	Dependencies     string    `json:"dependencies"`
+	ExtensionPack    string    `json:"extensionPack"`
+	Pinned           bool      `json:"pinned"`
+	SHA256           string    `json:"sha256"`
+
+	// IsLatest is set on the newest version of an extension. It is derived
+	// from the versions stored and left out of dumps.
+	IsLatest bool `json:"-"`
 }
 
+// Database is safe for concurrent use. database/sql pools connections, so
+// goroutines read in parallel; writes are serialized by SQLite itself. To
+// make that work across goroutines and processes (e.g. download while serve
+// is running), every connection is opened with:
+//
+//   - journal_mode(WAL), so readers never block the writer or each other;
+//   - busy_timeout, so a writer waits for the lock instead of failing with
+//     "database is locked";
+//   - _txlock=immediate, so transactions take the write lock when they
+//     begin. A deferred transaction that reads first and then writes cannot
+//     wait for the lock and fails at once when another writer got there.
 type Database struct {
 	db *sql.DB
 }
 
+// busyTimeout is how long a connection waits for another one's write lock.
+const busyTimeout = 5 * time.Second
+
+// universalPlatform is the target platform of builds that run anywhere.
+const universalPlatform = "universal"
+
+// buildOrderSQL orders the builds of a version: the universal build first,
+// then the platform builds by name.
+const buildOrderSQL = ` ORDER BY target_platform != 'universal', target_platform`
+
+// dataSourceName adds the connection settings described on Database to
+// the database path.
+func dataSourceName(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s_pragma=journal_mode(WAL)&_pragma=busy_timeout(%d)&_txlock=immediate",
+		path, separator, busyTimeout.Milliseconds())
+}
+
 func New() (*Database, error) {
 	cfg := config.GetConfig()
 
@@ -60,7 +108,7 @@ func New() (*Database, error) {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite", cfg.DBPath)
+	db, err := sql.Open("sqlite", dataSourceName(cfg.DBPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -80,10 +128,12 @@ func New() (*Database, error) {
 	return &Database{db: db}, nil
 }
 
-func createTables(db *sql.DB) error {
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS extensions (
-		id TEXT PRIMARY KEY,
+// extensionsTableSQL defines the columns of the extensions table. Every
+// build of an extension, a version for one target platform, is a row of its
+// own; is_latest marks one build of the newest version, which is what
+// listings and searches return.
+const extensionsTableSQL = `(
+		id TEXT NOT NULL,
 		name TEXT NOT NULL,
 		display_name TEXT,
 		description TEXT,
@@ -103,9 +153,9 @@ func createTables(db *sql.DB) error {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		verified BOOLEAN DEFAULT 1,
-		average_rating REAL DEFAULT 5.0,
-		review_count INTEGER DEFAULT 100,
-		download_count INTEGER DEFAULT 1000,
+		average_rating REAL DEFAULT 0,
+		review_count INTEGER DEFAULT 0,
+		download_count INTEGER DEFAULT 0,
 		namespace TEXT,
 		extension_id TEXT,
 		short_description TEXT,
@@ -113,60 +163,422 @@ func createTables(db *sql.DB) error {
 		release_date DATETIME,
 		pre_release BOOLEAN DEFAULT 0,
 		deprecated BOOLEAN DEFAULT 0,
-		target_platform TEXT DEFAULT 'universal',
-		readme_content TEXT
-	);
-	
+		target_platform TEXT NOT NULL DEFAULT 'universal',
+		readme_content TEXT,
+		featured BOOLEAN DEFAULT 0,
+		source TEXT DEFAULT '',
+		metadata_only BOOLEAN DEFAULT 0,
+		dependencies TEXT DEFAULT '',
+		extension_pack TEXT DEFAULT '',
+		pinned BOOLEAN DEFAULT 0,
+		is_latest BOOLEAN DEFAULT 1,
+		sha256 TEXT DEFAULT '',
+		PRIMARY KEY (id, version, target_platform)
+	)`
+
+const extensionsIndexesSQL = `
 	CREATE INDEX IF NOT EXISTS idx_extensions_name ON extensions(name);
 	CREATE INDEX IF NOT EXISTS idx_extensions_publisher ON extensions(publisher);
 	CREATE INDEX IF NOT EXISTS idx_extensions_file_path ON extensions(file_path);
 	CREATE INDEX IF NOT EXISTS idx_extensions_last_updated ON extensions(last_updated);
+	CREATE INDEX IF NOT EXISTS idx_extensions_extension_id ON extensions(extension_id COLLATE NOCASE);
+	CREATE INDEX IF NOT EXISTS idx_extensions_version ON extensions(version, target_platform);
+	CREATE INDEX IF NOT EXISTS idx_extensions_latest ON extensions(is_latest);
 	`
 
-	_, err := db.Exec(createTableSQL)
+func createTables(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS extensions ` + extensionsTableSQL); err != nil {
+		return err
+	}
+	if _, err := db.Exec(createPublishersTableSQL); err != nil {
+		return err
+	}
+	if _, err := db.Exec(createInstallsTableSQL); err != nil {
+		return err
+	}
+	if err := migrateColumns(db); err != nil {
+		return err
+	}
+	if err := migratePrimaryKey(db); err != nil {
+		return err
+	}
+	if err := migrateExtensionIDs(db); err != nil {
+		return err
+	}
+	_, err := db.Exec(extensionsIndexesSQL)
 	return err
 }
 
+// addedColumns lists columns introduced after the initial schema. Rows are
+// read with "SELECT *", so new columns must be appended both here and at the
+// end of the CREATE TABLE statement to keep the column order identical.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{"featured", "BOOLEAN DEFAULT 0"},
+	{"source", "TEXT DEFAULT ''"},
+	{"metadata_only", "BOOLEAN DEFAULT 0"},
+	{"dependencies", "TEXT DEFAULT ''"},
+	{"extension_pack", "TEXT DEFAULT ''"},
+	{"pinned", "BOOLEAN DEFAULT 0"},
+	{"is_latest", "BOOLEAN DEFAULT 1"},
+	{"sha256", "TEXT DEFAULT ''"},
+}
+
+// migrateColumns adds any missing columns to databases created by older versions.
+func migrateColumns(db *sql.DB) error {
+	rows, err := db.Query(`PRAGMA table_info(extensions)`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range addedColumns {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE extensions ADD COLUMN %s %s", column.name, column.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", column.name, err)
+		}
+		log.Printf("Database: added column %s", column.name)
+	}
+	return nil
+}
+
+// migratePrimaryKey rebuilds extensions tables created when the ID, or the
+// ID and version, was the primary key, so that only one build per extension
+// or version could be kept. SQLite cannot change the key of a table, so the
+// rows are copied into a new one. Rows without a target platform were
+// universal builds.
+func migratePrimaryKey(db *sql.DB) error {
+	var platformInKey bool
+	err := db.QueryRow(`SELECT pk > 0 FROM pragma_table_info('extensions') WHERE name = 'target_platform'`).Scan(&platformInKey)
+	if err != nil || platformInKey {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, statement := range []string{
+		`UPDATE extensions SET target_platform = 'universal' WHERE target_platform IS NULL OR target_platform = ''`,
+		`CREATE TABLE extensions_new ` + extensionsTableSQL,
+		`INSERT INTO extensions_new SELECT * FROM extensions`,
+		`DROP TABLE extensions`,
+		`ALTER TABLE extensions_new RENAME TO extensions`,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to change the primary key to (id, version, target_platform): %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Println("Database: extensions are now keyed by ID, version and target platform")
+	return nil
+}
+
+// migrateExtensionIDs replaces the "publisher.name" IDs that older
+// versions stored as extension_id with the gallery UUIDs served to clients,
+// so that ExtensionId filters find those extensions.
+func migrateExtensionIDs(db *sql.DB) error {
+	rows, err := db.Query(`SELECT DISTINCT id, COALESCE(extension_id, '') FROM extensions`)
+	if err != nil {
+		return err
+	}
+	stale := make(map[string]string)
+	for rows.Next() {
+		var id, extensionID string
+		if err := rows.Scan(&id, &extensionID); err != nil {
+			rows.Close()
+			return err
+		}
+		if !utils.IsUUID(extensionID) {
+			stale[id] = utils.ExtensionUUID(extensionID, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, extensionID := range stale {
+		if _, err := db.Exec(`UPDATE extensions SET extension_id = ? WHERE id = ?`, extensionID, id); err != nil {
+			return fmt.Errorf("failed to set the gallery UUID of %s: %w", id, err)
+		}
+	}
+	if len(stale) > 0 {
+		log.Printf("Database: derived gallery UUIDs for %d extensions", len(stale))
+	}
+	return nil
+}
+
 func (d *Database) Close() error {
 	return d.db.Close()
 }
 
-func (d *Database) UpsertExtension(ext *ExtensionDB) error {
-	query := `
-		INSERT OR REPLACE INTO extensions (
+// upsertExtensionSQL is the statement of UpsertExtension and UpsertExtensions.
+const upsertExtensionSQL = `
+		INSERT INTO extensions (
 			id, name, display_name, description, version, publisher, engines, categories, tags,
 			icon, repository, homepage, bugs, license, file_size, last_updated, file_path,
 			verified, average_rating, review_count, download_count, namespace, extension_id,
 			short_description, published_date, release_date, pre_release, deprecated,
-			target_platform, readme_content, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			target_platform, readme_content, created_at, updated_at, featured, source, metadata_only,
+			dependencies, extension_pack, pinned, sha256
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id, version, target_platform) DO UPDATE SET
+			name = excluded.name, display_name = excluded.display_name, description = excluded.description,
+			version = excluded.version, publisher = excluded.publisher, engines = excluded.engines,
+			categories = excluded.categories, tags = excluded.tags, icon = excluded.icon,
+			repository = excluded.repository, homepage = excluded.homepage, bugs = excluded.bugs,
+			license = excluded.license, file_size = excluded.file_size, last_updated = excluded.last_updated,
+			file_path = excluded.file_path, verified = excluded.verified,
+			average_rating = CASE WHEN review_count > 0 THEN average_rating ELSE excluded.average_rating END,
+			review_count = CASE WHEN review_count > 0 THEN review_count ELSE excluded.review_count END,
+			download_count = excluded.download_count,
+			namespace = excluded.namespace, extension_id = excluded.extension_id,
+			short_description = excluded.short_description, published_date = excluded.published_date,
+			release_date = excluded.release_date, pre_release = excluded.pre_release,
+			deprecated = excluded.deprecated, target_platform = excluded.target_platform,
+			readme_content = excluded.readme_content, created_at = excluded.created_at,
+			updated_at = excluded.updated_at, source = COALESCE(NULLIF(excluded.source, ''), source),
+			metadata_only = excluded.metadata_only, dependencies = excluded.dependencies,
+			extension_pack = excluded.extension_pack, sha256 = excluded.sha256
 	`
 
-	_, err := d.db.Exec(query,
+// upsertExtension stores ext in tx. A new build of a known extension
+// takes over its featured mark, pin, rating, download count and source,
+// which belong to the extension rather than to a build, and the latest
+// build is marked again.
+func upsertExtension(tx *sql.Tx, stored *ExtensionDB) error {
+	ext := *stored
+	if ext.TargetPlatform == "" {
+		ext.TargetPlatform = universalPlatform
+	}
+	var (
+		featured, pinned bool
+		average          float64
+		count, downloads int64
+		source           string
+	)
+	err := tx.QueryRow(`SELECT featured, pinned, average_rating, review_count, download_count, source FROM extensions
+		WHERE id = ? AND is_latest = 1`, ext.ID).Scan(&featured, &pinned, &average, &count, &downloads, &source)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	default:
+		ext.Featured, ext.Pinned = featured, pinned
+		if count > 0 {
+			ext.AverageRating, ext.ReviewCount = average, count
+		}
+		ext.DownloadCount = max(ext.DownloadCount, downloads)
+		if ext.Source == "" {
+			ext.Source = source
+		}
+	}
+
+	_, err = tx.Exec(upsertExtensionSQL,
 		ext.ID, ext.Name, ext.DisplayName, ext.Description, ext.Version, ext.Publisher,
 		ext.Engines, ext.Categories, ext.Tags, ext.Icon, ext.Repository, ext.Homepage,
 		ext.Bugs, ext.License, ext.FileSize, ext.LastUpdated, ext.FilePath, ext.Verified,
 		ext.AverageRating, ext.ReviewCount, ext.DownloadCount, ext.Namespace, ext.ExtensionID,
 		ext.ShortDescription, ext.PublishedDate, ext.ReleaseDate, ext.PreRelease, ext.Deprecated,
-		ext.TargetPlatform, ext.ReadmeContent, ext.CreatedAt, ext.UpdatedAt,
+		ext.TargetPlatform, ext.ReadmeContent, ext.CreatedAt, ext.UpdatedAt, ext.Featured, ext.Source,
+		ext.MetadataOnly, ext.Dependencies, ext.ExtensionPack, ext.Pinned, ext.SHA256,
 	)
+	if err != nil {
+		return err
+	}
+	return markLatest(tx, ext.ID)
+}
+
+// markLatest sets is_latest on one build of the newest version of an
+// extension, by semantic version, and clears it on the others. The
+// universal build is preferred, otherwise the first platform by name.
+func markLatest(tx *sql.Tx, id string) error {
+	rows, err := tx.Query(`SELECT version, target_platform FROM extensions WHERE id = ?`+buildOrderSQL, id)
+	if err != nil {
+		return err
+	}
+	latest, platform := "", ""
+	for rows.Next() {
+		var version, build string
+		if err := rows.Scan(&version, &build); err != nil {
+			rows.Close()
+			return err
+		}
+		if latest == "" || utils.CompareVersions(version, latest) > 0 {
+			latest, platform = version, build
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE extensions SET is_latest = (version = ? AND target_platform = ?) WHERE id = ?`, latest, platform, id)
+	return err
+}
+
+// UpsertExtension inserts or updates a version of an extension. The featured
+// and pinned flags and a rating are managed with SetFeatured, SetPinned and
+// SetRating and are kept when an existing extension is updated, as is the
+// source marketplace when the update does not name one.
+func (d *Database) UpsertExtension(ext *ExtensionDB) error {
+	return d.UpsertExtensions([]*ExtensionDB{ext})
+}
+
+// UpsertExtensions stores exts like UpsertExtension in a single transaction:
+// either all of them are stored or, on error, none is.
+func (d *Database) UpsertExtensions(exts []*ExtensionDB) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, ext := range exts {
+		if err := upsertExtension(tx, ext); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to store %s: %w", ext.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SetFeatured marks or unmarks an extension as featured. It returns false if
+// the extension does not exist.
+func (d *Database) SetFeatured(id string, featured bool) (bool, error) {
+	result, err := d.db.Exec(`UPDATE extensions SET featured = ?, updated_at = ? WHERE id = ?`, featured, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetPinned pins or unpins an extension. It returns false if the extension
+// does not exist.
+func (d *Database) SetPinned(id string, pinned bool) (bool, error) {
+	result, err := d.db.Exec(`UPDATE extensions SET pinned = ?, updated_at = ? WHERE id = ?`, pinned, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetRating stores the average rating and rating count of an extension; a
+// count of zero clears the rating. It returns false if the extension does not
+// exist.
+func (d *Database) SetRating(id string, average float64, count int64) (bool, error) {
+	result, err := d.db.Exec(`UPDATE extensions SET average_rating = ?, review_count = ?, updated_at = ? WHERE id = ?`,
+		average, count, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
 
+// SetStatistics stores the install and rating numbers a marketplace reports
+// for an extension. The download count only grows, so downloads counted by
+// this server are not lost when the marketplace reports fewer; the rating is
+// replaced unless count is 0.
+func (d *Database) SetStatistics(id string, installs int64, average float64, count int64) error {
+	_, err := d.db.Exec(`UPDATE extensions SET download_count = MAX(download_count, ?1),
+		average_rating = CASE WHEN ?3 > 0 THEN ?2 ELSE average_rating END,
+		review_count = CASE WHEN ?3 > 0 THEN ?3 ELSE review_count END,
+		updated_at = ?4 WHERE id = ?5`, installs, average, count, time.Now(), id)
 	return err
 }
 
+// IncrementDownloadCount counts a download of an extension on every stored
+// version, so the count stays with the extension whichever version is
+// downloaded. updated_at is left alone: a download does not change the
+// extension.
+func (d *Database) IncrementDownloadCount(id string) error {
+	_, err := d.db.Exec(`UPDATE extensions SET download_count = download_count + 1 WHERE id = ?`, id)
+	return err
+}
+
+// ClearRatings removes the rating of every extension and returns how many
+// had one, e.g. the 5.0 from 100 ratings older versions stored for all.
+func (d *Database) ClearRatings() (int64, error) {
+	result, err := d.db.Exec(`UPDATE extensions SET average_rating = 0, review_count = 0, updated_at = ? WHERE review_count > 0`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (d *Database) GetExtensionByID(id string) (*ExtensionDB, error) {
-	query := `SELECT * FROM extensions WHERE id = ?`
+	query := `SELECT * FROM extensions WHERE id = ? AND is_latest = 1`
 
-	var ext ExtensionDB
-	err := d.db.QueryRow(query, id).Scan(
-		&ext.ID, &ext.Name, &ext.DisplayName, &ext.Description, &ext.Version, &ext.Publisher,
-		&ext.Engines, &ext.Categories, &ext.Tags, &ext.Icon, &ext.Repository, &ext.Homepage,
-		&ext.Bugs, &ext.License, &ext.FileSize, &ext.LastUpdated, &ext.FilePath, &ext.CreatedAt,
-		&ext.UpdatedAt, &ext.Verified, &ext.AverageRating, &ext.ReviewCount, &ext.DownloadCount,
-		&ext.Namespace, &ext.ExtensionID, &ext.ShortDescription, &ext.PublishedDate, &ext.ReleaseDate,
-		&ext.PreRelease, &ext.Deprecated, &ext.TargetPlatform, &ext.ReadmeContent,
-	)
+	ext, err := scanExtension(d.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
 
+	return ext, nil
+}
+
+// GetExtensionVersions returns every stored build of an extension, newest
+// version first and the builds of a version in build order.
+func (d *Database) GetExtensionVersions(id string) ([]ExtensionDB, error) {
+	rows, err := d.db.Query(`SELECT * FROM extensions WHERE id = ?`+buildOrderSQL, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions, err := scanExtensions(rows)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return utils.CompareVersions(versions[i].Version, versions[j].Version) > 0
+	})
+	return versions, nil
+}
+
+// GetExtensionByIDAndVersion returns the first build of one version of an
+// extension in build order, or nil if that version is not stored.
+func (d *Database) GetExtensionByIDAndVersion(id, version string) (*ExtensionDB, error) {
+	query := `SELECT * FROM extensions WHERE id = ? AND version = ?` + buildOrderSQL + ` LIMIT 1`
+
+	ext, err := scanExtension(d.db.QueryRow(query, id, version))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -174,20 +586,70 @@ func (d *Database) GetExtensionByID(id string) (*ExtensionDB, error) {
 		return nil, err
 	}
 
-	return &ext, nil
+	return ext, nil
+}
+
+// GetExtensionBuilds returns the builds of one version of an extension in
+// build order.
+func (d *Database) GetExtensionBuilds(id, version string) ([]ExtensionDB, error) {
+	rows, err := d.db.Query(`SELECT * FROM extensions WHERE id = ? AND version = ?`+buildOrderSQL, id, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanExtensions(rows)
+}
+
+// GetExtensionBuild returns the build of a version for targetPlatform, or
+// nil if it is not stored.
+func (d *Database) GetExtensionBuild(id, version, targetPlatform string) (*ExtensionDB, error) {
+	query := `SELECT * FROM extensions WHERE id = ? AND version = ? AND target_platform = ?`
+
+	ext, err := scanExtension(d.db.QueryRow(query, id, version, targetPlatform))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return ext, nil
+}
+
+// ExtensionExists reports whether an extension with the given ID is in the
+// catalog. IDs are compared case-insensitively, as VS Code does.
+func (d *Database) ExtensionExists(id string) (bool, error) {
+	var exists bool
+	err := d.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM extensions WHERE id = ? COLLATE NOCASE)`, id).Scan(&exists)
+	return exists, err
+}
+
+// FindExtensionID returns the stored ID matching id case-insensitively, as
+// VS Code compares IDs, or "" if there is none.
+func (d *Database) FindExtensionID(id string) (string, error) {
+	var stored string
+	err := d.db.QueryRow(`SELECT id FROM extensions WHERE id = ? COLLATE NOCASE LIMIT 1`, id).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return stored, err
 }
 
 func (d *Database) GetAllExtensions(page, limit int) ([]ExtensionDB, int64, error) {
 	// Get total count
 	var total int64
-	err := d.db.QueryRow("SELECT COUNT(*) FROM extensions").Scan(&total)
+	err := d.db.QueryRow("SELECT COUNT(*) FROM extensions WHERE is_latest = 1").Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get extensions with pagination
-	offset := (page - 1) * limit
-	query := `SELECT * FROM extensions ORDER BY last_updated DESC LIMIT ? OFFSET ?`
+	offset, ok := pageOffset(page, limit)
+	if !ok {
+		return []ExtensionDB{}, total, nil
+	}
+	query := `SELECT * FROM extensions WHERE is_latest = 1 ORDER BY featured DESC, last_updated DESC LIMIT ? OFFSET ?`
 
 	rows, err := d.db.Query(query, limit, offset)
 	if err != nil {
@@ -195,66 +657,89 @@ func (d *Database) GetAllExtensions(page, limit int) ([]ExtensionDB, int64, erro
 	}
 	defer rows.Close()
 
-	var extensions []ExtensionDB
-	for rows.Next() {
-		var ext ExtensionDB
-		err := rows.Scan(
-			&ext.ID, &ext.Name, &ext.DisplayName, &ext.Description, &ext.Version, &ext.Publisher,
-			&ext.Engines, &ext.Categories, &ext.Tags, &ext.Icon, &ext.Repository, &ext.Homepage,
-			&ext.Bugs, &ext.License, &ext.FileSize, &ext.LastUpdated, &ext.FilePath, &ext.CreatedAt,
-			&ext.UpdatedAt, &ext.Verified, &ext.AverageRating, &ext.ReviewCount, &ext.DownloadCount,
-			&ext.Namespace, &ext.ExtensionID, &ext.ShortDescription, &ext.PublishedDate, &ext.ReleaseDate,
-			&ext.PreRelease, &ext.Deprecated, &ext.TargetPlatform, &ext.ReadmeContent,
-		)
-		if err != nil {
-			return nil, 0, err
-		}
-		extensions = append(extensions, ext)
+	extensions, err := scanExtensions(rows)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	return extensions, total, nil
 }
 
+// pageOffset returns the number of rows before page, counted from 1, of
+// limit rows each. ok is false for pages before the first, empty pages and
+// offsets too large for an int, which the callers answer with no rows.
+func pageOffset(page, limit int) (offset int, ok bool) {
+	if page < 1 || limit < 1 || page-1 > math.MaxInt/limit {
+		return 0, false
+	}
+	return (page - 1) * limit, true
+}
+
+// GetAllExtensionVersions returns one page of all stored versions of all
+// extensions, ordered by ID.
+func (d *Database) GetAllExtensionVersions(page, limit int) ([]ExtensionDB, error) {
+	offset, ok := pageOffset(page, limit)
+	if !ok {
+		return []ExtensionDB{}, nil
+	}
+	rows, err := d.db.Query(`SELECT * FROM extensions ORDER BY id, version, target_platform LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanExtensions(rows)
+}
+
+// searchScoreSQL ranks a search match: ?1 is the escaped query, ?2 the
+// prefix pattern and ?3 the substring pattern. Matches on the ID, name and
+// display name outweigh matches on the publisher and description.
+const searchScoreSQL = `
+	(CASE WHEN id LIKE ?1 ESCAPE '\' OR name LIKE ?1 ESCAPE '\' OR display_name LIKE ?1 ESCAPE '\' THEN 100 ELSE 0 END) +
+	(CASE WHEN name LIKE ?2 ESCAPE '\' OR display_name LIKE ?2 ESCAPE '\' THEN 40 ELSE 0 END) +
+	(CASE WHEN name LIKE ?3 ESCAPE '\' OR display_name LIKE ?3 ESCAPE '\' THEN 20 ELSE 0 END) +
+	(CASE WHEN publisher LIKE ?3 ESCAPE '\' THEN 5 ELSE 0 END) +
+	(CASE WHEN description LIKE ?3 ESCAPE '\' THEN 2 ELSE 0 END)`
+
+// SearchExtensions returns the extension whose ID is query and those whose
+// name, display name, description or publisher contain it, ignoring case.
+// Best matches come first: an exact ID or name beats a name prefix, which
+// beats a match anywhere in the name, the publisher and finally the
+// description. Equal matches are ordered by last update.
 func (d *Database) SearchExtensions(query string, page, limit int) ([]ExtensionDB, int64, error) {
-	searchPattern := "%" + query + "%"
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query)
+	exact, prefix, contains := escaped, escaped+"%", "%"+escaped+"%"
 
 	// Get total count
-	countQuery := `SELECT COUNT(*) FROM extensions 
-		WHERE name LIKE ? OR display_name LIKE ? OR description LIKE ? OR publisher LIKE ?`
+	countQuery := `SELECT COUNT(*) FROM extensions
+		WHERE is_latest = 1 AND (id LIKE ?1 ESCAPE '\' OR name LIKE ?3 ESCAPE '\' OR display_name LIKE ?3 ESCAPE '\'
+			OR description LIKE ?3 ESCAPE '\' OR publisher LIKE ?3 ESCAPE '\')`
 
 	var total int64
-	err := d.db.QueryRow(countQuery, searchPattern, searchPattern, searchPattern, searchPattern).Scan(&total)
+	err := d.db.QueryRow(countQuery, exact, prefix, contains).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get extensions with search and pagination
-	offset := (page - 1) * limit
-	searchQuery := `SELECT * FROM extensions 
-		WHERE name LIKE ? OR display_name LIKE ? OR description LIKE ? OR publisher LIKE ?
-		ORDER BY last_updated DESC LIMIT ? OFFSET ?`
+	offset, ok := pageOffset(page, limit)
+	if !ok {
+		return []ExtensionDB{}, total, nil
+	}
+	searchQuery := `SELECT * FROM extensions
+		WHERE is_latest = 1 AND (id LIKE ?1 ESCAPE '\' OR name LIKE ?3 ESCAPE '\' OR display_name LIKE ?3 ESCAPE '\'
+			OR description LIKE ?3 ESCAPE '\' OR publisher LIKE ?3 ESCAPE '\')
+		ORDER BY ` + searchScoreSQL + ` DESC, last_updated DESC LIMIT ?4 OFFSET ?5`
 
-	rows, err := d.db.Query(searchQuery, searchPattern, searchPattern, searchPattern, searchPattern, limit, offset)
+	rows, err := d.db.Query(searchQuery, exact, prefix, contains, limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var extensions []ExtensionDB
-	for rows.Next() {
-		var ext ExtensionDB
-		err := rows.Scan(
-			&ext.ID, &ext.Name, &ext.DisplayName, &ext.Description, &ext.Version, &ext.Publisher,
-			&ext.Engines, &ext.Categories, &ext.Tags, &ext.Icon, &ext.Repository, &ext.Homepage,
-			&ext.Bugs, &ext.License, &ext.FileSize, &ext.LastUpdated, &ext.FilePath, &ext.CreatedAt,
-			&ext.UpdatedAt, &ext.Verified, &ext.AverageRating, &ext.ReviewCount, &ext.DownloadCount,
-			&ext.Namespace, &ext.ExtensionID, &ext.ShortDescription, &ext.PublishedDate, &ext.ReleaseDate,
-			&ext.PreRelease, &ext.Deprecated, &ext.TargetPlatform, &ext.ReadmeContent,
-		)
-		if err != nil {
-			return nil, 0, err
-		}
-		extensions = append(extensions, ext)
+	extensions, err := scanExtensions(rows)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	return extensions, total, nil
@@ -266,21 +751,46 @@ func (d *Database) DeleteExtension(id string) error {
 	return err
 }
 
+// DeleteExtensionVersion removes the build of a version of an extension for
+// targetPlatform. When it was the latest, the latest remaining build takes
+// its place.
+func (d *Database) DeleteExtensionVersion(id, version, targetPlatform string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM extensions WHERE id = ? AND version = ? AND target_platform = ?`, id, version, targetPlatform); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := markLatest(tx, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
 func (d *Database) DeleteAllExtensions() error {
 	query := `DELETE FROM extensions`
 	_, err := d.db.Exec(query)
 	return err
 }
 
+func (d *Database) CountExtensions() (int64, error) {
+	var total int64
+	err := d.db.QueryRow("SELECT COUNT(*) FROM extensions WHERE is_latest = 1").Scan(&total)
+	return total, err
+}
+
 func (d *Database) GetStats() (map[string]interface{}, error) {
 	var total int64
-	err := d.db.QueryRow("SELECT COUNT(*) FROM extensions").Scan(&total)
+	err := d.db.QueryRow("SELECT COUNT(*) FROM extensions WHERE is_latest = 1").Scan(&total)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get publishers count
-	publishersQuery := `SELECT publisher, COUNT(*) as count FROM extensions GROUP BY publisher`
+	publishersQuery := `SELECT publisher, COUNT(*) as count FROM extensions WHERE is_latest = 1 GROUP BY publisher`
 	rows, err := d.db.Query(publishersQuery)
 	if err != nil {
 		return nil, err
@@ -298,33 +808,146 @@ func (d *Database) GetStats() (map[string]interface{}, error) {
 	}
 
 	// Get categories count (simplified - counting non-empty categories)
-	categoriesQuery := `SELECT COUNT(*) FROM extensions WHERE categories IS NOT NULL AND categories != ''`
+	categoriesQuery := `SELECT COUNT(*) FROM extensions WHERE is_latest = 1 AND categories IS NOT NULL AND categories != ''`
 	var categoriesCount int64
 	err = d.db.QueryRow(categoriesQuery).Scan(&categoriesCount)
 	if err != nil {
 		return nil, err
 	}
 
-	return map[string]interface{}{
+	tagsMap, err := d.getTagHistogram()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every stored version has its own .vsix file.
+	var totalFileSize int64
+	err = d.db.QueryRow(`SELECT COALESCE(SUM(file_size), 0) FROM extensions WHERE metadata_only = 0`).Scan(&totalFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]interface{}{
 		"total_extensions": total,
+		"total_file_size":  totalFileSize,
 		"publishers":       publishersMap,
 		"categories":       map[string]int64{"total": categoriesCount},
-	}, nil
+		"tags":             tagsMap,
+	}
+
+	var lastUpdated time.Time
+	err = d.db.QueryRow(`SELECT last_updated FROM extensions WHERE is_latest = 1 ORDER BY last_updated DESC LIMIT 1`).Scan(&lastUpdated)
+	switch {
+	case err == nil:
+		stats["last_updated"] = lastUpdated
+	case err != sql.ErrNoRows:
+		return nil, err
+	}
+	return stats, nil
+}
+
+// getTagHistogram counts the extensions carrying each tag. Tags are compared
+// case-insensitively and reported in lower case.
+func (d *Database) getTagHistogram() (map[string]int64, error) {
+	rows, err := d.db.Query(`SELECT tags FROM extensions WHERE is_latest = 1 AND tags IS NOT NULL AND tags != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histogram := make(map[string]int64)
+	for rows.Next() {
+		var tagsJSON string
+		if err := rows.Scan(&tagsJSON); err != nil {
+			return nil, err
+		}
+
+		var tags []string
+		if err := json.Unmarshal([]byte(tagsJSON), &tags); err != nil {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, tag := range tags {
+			tag = strings.ToLower(tag)
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				histogram[tag]++
+			}
+		}
+	}
+	return histogram, rows.Err()
+}
+
+// GetExtensionsByTag returns extensions whose tags contain tag, ignoring case.
+func (d *Database) GetExtensionsByTag(tag string, page, limit int) ([]ExtensionDB, int64, error) {
+	return d.getExtensionsByListItem("tags", tag, page, limit)
+}
+
+// GetExtensionsByCategory returns extensions whose categories contain
+// category, ignoring case.
+func (d *Database) GetExtensionsByCategory(category string, page, limit int) ([]ExtensionDB, int64, error) {
+	return d.getExtensionsByListItem("categories", category, page, limit)
+}
+
+// getExtensionsByListItem returns extensions whose JSON array column
+// contains item, ignoring case. column must be a trusted column name.
+func (d *Database) getExtensionsByListItem(column, item string, page, limit int) ([]ExtensionDB, int64, error) {
+	// Match the quoted JSON string of the item with LIKE wildcards escaped.
+	quoted, err := json.Marshal(item)
+	if err != nil {
+		return nil, 0, err
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	pattern := "%" + escaper.Replace(string(quoted)) + "%"
+
+	var total int64
+	err = d.db.QueryRow(`SELECT COUNT(*) FROM extensions WHERE is_latest = 1 AND `+column+` LIKE ? ESCAPE '\'`, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset, ok := pageOffset(page, limit)
+	if !ok {
+		return []ExtensionDB{}, total, nil
+	}
+	query := `SELECT * FROM extensions WHERE is_latest = 1 AND ` + column + ` LIKE ? ESCAPE '\' ORDER BY featured DESC, last_updated DESC LIMIT ? OFFSET ?`
+
+	rows, err := d.db.Query(query, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	extensions, err := scanExtensions(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return extensions, total, nil
+}
+
+// GetExtensionByExtensionID looks an extension up by its marketplace UUID,
+// which clients send for ExtensionId gallery filters. It returns nil if
+// there is no such extension.
+func (d *Database) GetExtensionByExtensionID(extensionID string) (*ExtensionDB, error) {
+	query := `SELECT * FROM extensions WHERE extension_id = ? COLLATE NOCASE ORDER BY is_latest DESC LIMIT 1`
+
+	ext, err := scanExtension(d.db.QueryRow(query, extensionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return ext, nil
 }
 
 func (d *Database) GetExtensionByFilePath(filePath string) (*ExtensionDB, error) {
 	query := `SELECT * FROM extensions WHERE file_path = ?`
 
-	var ext ExtensionDB
-	err := d.db.QueryRow(query, filePath).Scan(
-		&ext.ID, &ext.Name, &ext.DisplayName, &ext.Description, &ext.Version, &ext.Publisher,
-		&ext.Engines, &ext.Categories, &ext.Tags, &ext.Icon, &ext.Repository, &ext.Homepage,
-		&ext.Bugs, &ext.License, &ext.FileSize, &ext.LastUpdated, &ext.FilePath, &ext.CreatedAt,
-		&ext.UpdatedAt, &ext.Verified, &ext.AverageRating, &ext.ReviewCount, &ext.DownloadCount,
-		&ext.Namespace, &ext.ExtensionID, &ext.ShortDescription, &ext.PublishedDate, &ext.ReleaseDate,
-		&ext.PreRelease, &ext.Deprecated, &ext.TargetPlatform, &ext.ReadmeContent,
-	)
-
+	ext, err := scanExtension(d.db.QueryRow(query, filePath))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -332,20 +955,23 @@ func (d *Database) GetExtensionByFilePath(filePath string) (*ExtensionDB, error)
 		return nil, err
 	}
 
-	return &ext, nil
+	return ext, nil
 }
 
 func (d *Database) GetExtensionsByPublisher(publisher string, page, limit int) ([]ExtensionDB, int64, error) {
 	// Get total count
 	var total int64
-	err := d.db.QueryRow("SELECT COUNT(*) FROM extensions WHERE publisher = ?", publisher).Scan(&total)
+	err := d.db.QueryRow("SELECT COUNT(*) FROM extensions WHERE is_latest = 1 AND publisher = ?", publisher).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get extensions with pagination
-	offset := (page - 1) * limit
-	query := `SELECT * FROM extensions WHERE publisher = ? ORDER BY last_updated DESC LIMIT ? OFFSET ?`
+	offset, ok := pageOffset(page, limit)
+	if !ok {
+		return []ExtensionDB{}, total, nil
+	}
+	query := `SELECT * FROM extensions WHERE is_latest = 1 AND publisher = ? ORDER BY last_updated DESC LIMIT ? OFFSET ?`
 
 	rows, err := d.db.Query(query, publisher, limit, offset)
 	if err != nil {
@@ -353,24 +979,62 @@ func (d *Database) GetExtensionsByPublisher(publisher string, page, limit int) (
 	}
 	defer rows.Close()
 
+	extensions, err := scanExtensions(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return extensions, total, nil
+}
+
+// GetFingerprint returns a value that changes whenever extensions or
+// publishers are added, updated or removed, so callers can cheaply detect
+// catalog changes.
+func (d *Database) GetFingerprint() (string, error) {
+	var count, totalSize, publishers int64
+	var lastUpdate, lastPublisherUpdate string
+	query := `SELECT COUNT(*), COALESCE(SUM(file_size), 0), COALESCE(MAX(updated_at), ''),
+		(SELECT COUNT(*) FROM publishers), (SELECT COALESCE(MAX(updated_at), '') FROM publishers)
+		FROM extensions`
+	if err := d.db.QueryRow(query).Scan(&count, &totalSize, &lastUpdate, &publishers, &lastPublisherUpdate); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d-%s-%d-%s", count, totalSize, lastUpdate, publishers, lastPublisherUpdate), nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanExtension reads a row selected with "SELECT *" from the extensions table.
+func scanExtension(row rowScanner) (*ExtensionDB, error) {
+	var ext ExtensionDB
+	err := row.Scan(
+		&ext.ID, &ext.Name, &ext.DisplayName, &ext.Description, &ext.Version, &ext.Publisher,
+		&ext.Engines, &ext.Categories, &ext.Tags, &ext.Icon, &ext.Repository, &ext.Homepage,
+		&ext.Bugs, &ext.License, &ext.FileSize, &ext.LastUpdated, &ext.FilePath, &ext.CreatedAt,
+		&ext.UpdatedAt, &ext.Verified, &ext.AverageRating, &ext.ReviewCount, &ext.DownloadCount,
+		&ext.Namespace, &ext.ExtensionID, &ext.ShortDescription, &ext.PublishedDate, &ext.ReleaseDate,
+		&ext.PreRelease, &ext.Deprecated, &ext.TargetPlatform, &ext.ReadmeContent, &ext.Featured,
+		&ext.Source, &ext.MetadataOnly, &ext.Dependencies, &ext.ExtensionPack, &ext.Pinned,
+		&ext.IsLatest, &ext.SHA256,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ext, nil
+}
+
+func scanExtensions(rows *sql.Rows) ([]ExtensionDB, error) {
 	var extensions []ExtensionDB
 	for rows.Next() {
-		var ext ExtensionDB
-		err := rows.Scan(
-			&ext.ID, &ext.Name, &ext.DisplayName, &ext.Description, &ext.Version, &ext.Publisher,
-			&ext.Engines, &ext.Categories, &ext.Tags, &ext.Icon, &ext.Repository, &ext.Homepage,
-			&ext.Bugs, &ext.License, &ext.FileSize, &ext.LastUpdated, &ext.FilePath, &ext.CreatedAt,
-			&ext.UpdatedAt, &ext.Verified, &ext.AverageRating, &ext.ReviewCount, &ext.DownloadCount,
-			&ext.Namespace, &ext.ExtensionID, &ext.ShortDescription, &ext.PublishedDate, &ext.ReleaseDate,
-			&ext.PreRelease, &ext.Deprecated, &ext.TargetPlatform, &ext.ReadmeContent,
-		)
+		ext, err := scanExtension(rows)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
-		extensions = append(extensions, ext)
+		extensions = append(extensions, *ext)
 	}
-
-	return extensions, total, nil
+	return extensions, rows.Err()
 }
 
 func (d *Database) GetDB() *sql.DB {
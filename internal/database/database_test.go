@@ -0,0 +1,449 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"littlevsx/internal/testutil"
+	"littlevsx/internal/utils"
+)
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	testutil.TempConfig(t, nil)
+	db, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testBuild(version, platform string) *ExtensionDB {
+	return &ExtensionDB{
+		ID:             "acme.tool",
+		Name:           "tool",
+		Publisher:      "acme",
+		Version:        version,
+		TargetPlatform: platform,
+		FilePath:       filepath.Join("extensions", "tool-"+version+"@"+platform+".vsix"),
+		LastUpdated:    time.Now(),
+	}
+}
+
+func buildKeys(exts []ExtensionDB) string {
+	keys := make([]string, len(exts))
+	for i, ext := range exts {
+		keys[i] = ext.Version + "@" + ext.TargetPlatform
+	}
+	return strings.Join(keys, " ")
+}
+
+func TestUpsertKeepsPlatformBuilds(t *testing.T) {
+	tests := []struct {
+		name       string
+		builds     [][2]string
+		wantBuilds string
+		wantLatest string
+	}{
+		{
+			name:       "second platform is added",
+			builds:     [][2]string{{"1.0.0", "linux-x64"}, {"1.0.0", "win32-x64"}},
+			wantBuilds: "1.0.0@linux-x64 1.0.0@win32-x64",
+			wantLatest: "1.0.0@linux-x64",
+		},
+		{
+			name:       "universal build is the latest",
+			builds:     [][2]string{{"1.0.0", "linux-x64"}, {"1.0.0", "universal"}},
+			wantBuilds: "1.0.0@universal 1.0.0@linux-x64",
+			wantLatest: "1.0.0@universal",
+		},
+		{
+			name:       "same build is replaced",
+			builds:     [][2]string{{"1.0.0", "linux-x64"}, {"1.0.0", "linux-x64"}},
+			wantBuilds: "1.0.0@linux-x64",
+			wantLatest: "1.0.0@linux-x64",
+		},
+		{
+			name:       "empty platform is universal",
+			builds:     [][2]string{{"1.0.0", ""}},
+			wantBuilds: "1.0.0@universal",
+			wantLatest: "1.0.0@universal",
+		},
+		{
+			name:       "newer version wins over universal build",
+			builds:     [][2]string{{"1.0.0", "universal"}, {"1.1.0", "darwin-arm64"}, {"1.1.0", "alpine-x64"}},
+			wantBuilds: "1.1.0@alpine-x64 1.1.0@darwin-arm64 1.0.0@universal",
+			wantLatest: "1.1.0@alpine-x64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			for _, build := range tt.builds {
+				if err := db.UpsertExtension(testBuild(build[0], build[1])); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			builds, err := db.GetExtensionVersions("acme.tool")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buildKeys(builds); got != tt.wantBuilds {
+				t.Errorf("builds = %q, want %q", got, tt.wantBuilds)
+			}
+
+			latest, err := db.GetExtensionByID("acme.tool")
+			if err != nil || latest == nil {
+				t.Fatalf("GetExtensionByID = %v, %v", latest, err)
+			}
+			if got := latest.Version + "@" + latest.TargetPlatform; got != tt.wantLatest {
+				t.Errorf("latest = %q, want %q", got, tt.wantLatest)
+			}
+			if total, _ := db.CountExtensions(); total != 1 {
+				t.Errorf("CountExtensions = %d, want 1", total)
+			}
+		})
+	}
+}
+
+func TestGetExtensionBuild(t *testing.T) {
+	db := newTestDatabase(t)
+	for _, platform := range []string{"linux-x64", "win32-x64"} {
+		if err := db.UpsertExtension(testBuild("1.0.0", platform)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		platform string
+		found    bool
+	}{
+		{"linux-x64", true},
+		{"win32-x64", true},
+		{"universal", false},
+		{"darwin-arm64", false},
+	}
+	for _, tt := range tests {
+		ext, err := db.GetExtensionBuild("acme.tool", "1.0.0", tt.platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (ext != nil) != tt.found {
+			t.Errorf("GetExtensionBuild(%q) = %v, want found %v", tt.platform, ext, tt.found)
+		}
+		if ext != nil && ext.TargetPlatform != tt.platform {
+			t.Errorf("GetExtensionBuild(%q) returned %q", tt.platform, ext.TargetPlatform)
+		}
+	}
+}
+
+func TestDeleteExtensionVersionKeepsOtherBuilds(t *testing.T) {
+	db := newTestDatabase(t)
+	for _, build := range [][2]string{{"1.0.0", "linux-x64"}, {"1.0.0", "win32-x64"}, {"0.9.0", "universal"}} {
+		if err := db.UpsertExtension(testBuild(build[0], build[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		version, platform string
+		wantBuilds        string
+		wantLatest        string
+	}{
+		{"1.0.0", "linux-x64", "1.0.0@win32-x64 0.9.0@universal", "1.0.0@win32-x64"},
+		{"1.0.0", "win32-x64", "0.9.0@universal", "0.9.0@universal"},
+	}
+	for _, step := range steps {
+		if err := db.DeleteExtensionVersion("acme.tool", step.version, step.platform); err != nil {
+			t.Fatal(err)
+		}
+		builds, err := db.GetExtensionVersions("acme.tool")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buildKeys(builds); got != step.wantBuilds {
+			t.Errorf("after deleting %s@%s: builds = %q, want %q", step.version, step.platform, got, step.wantBuilds)
+		}
+		latest, err := db.GetExtensionByID("acme.tool")
+		if err != nil || latest == nil {
+			t.Fatalf("GetExtensionByID = %v, %v", latest, err)
+		}
+		if got := latest.Version + "@" + latest.TargetPlatform; got != step.wantLatest {
+			t.Errorf("after deleting %s@%s: latest = %q, want %q", step.version, step.platform, got, step.wantLatest)
+		}
+	}
+}
+
+// TestMigratePrimaryKey opens databases created by older versions, keyed by
+// ID only and by ID and version, and checks that their rows survive and a
+// second platform build can then be added.
+func TestMigratePrimaryKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"keyed by id", "PRIMARY KEY (id)"},
+		{"keyed by id and version", "PRIMARY KEY (id, version)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testutil.TempConfig(t, nil)
+
+			old := strings.Replace(extensionsTableSQL, "PRIMARY KEY (id, version, target_platform)", tt.key, 1)
+			old = strings.Replace(old, "target_platform TEXT NOT NULL DEFAULT 'universal'", "target_platform TEXT DEFAULT 'universal'", 1)
+			raw, err := sql.Open("sqlite", filepath.Join(dir, "littlevsx.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = raw.Exec(`CREATE TABLE extensions ` + old)
+			if err == nil {
+				_, err = raw.Exec(`INSERT INTO extensions (id, name, version, publisher, file_size, last_updated, file_path, target_platform)
+					VALUES ('acme.tool', 'tool', '1.0.0', 'acme', 1, ?, 'tool-1.0.0.vsix', '')`, time.Now())
+			}
+			if err == nil {
+				_, err = raw.Exec(`UPDATE extensions SET display_name = '', description = '', engines = '', categories = '',
+					tags = '', icon = '', repository = '', homepage = '', bugs = '', license = '', namespace = '',
+					extension_id = '', short_description = '', readme_content = '', published_date = last_updated,
+					release_date = last_updated`)
+			}
+			raw.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			if err := db.UpsertExtension(testBuild("1.0.0", "linux-x64")); err != nil {
+				t.Fatal(err)
+			}
+			builds, err := db.GetExtensionVersions("acme.tool")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := buildKeys(builds), "1.0.0@universal 1.0.0@linux-x64"; got != want {
+				t.Errorf("builds = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPageOffset(t *testing.T) {
+	tests := []struct {
+		page, limit int
+		want        int
+		wantOK      bool
+	}{
+		{1, 50, 0, true},
+		{3, 50, 100, true},
+		{0, 50, 0, false},
+		{-2, 50, 0, false},
+		{2, 0, 0, false},
+		{math.MaxInt, 50, 0, false},
+		{math.MaxInt/50 + 1, 50, math.MaxInt / 50 * 50, true},
+	}
+	for _, tt := range tests {
+		got, ok := pageOffset(tt.page, tt.limit)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("pageOffset(%d, %d) = %d, %v, want %d, %v", tt.page, tt.limit, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestGetAllExtensionsPages checks that paging walks the catalog without
+// repeating extensions and answers pages past the end, or whose offset
+// overflows, with no rows.
+func TestGetAllExtensionsPages(t *testing.T) {
+	db := newTestDatabase(t)
+	for i := range 5 {
+		ext := testBuild("1.0.0", "universal")
+		ext.ID = fmt.Sprintf("acme.tool%d", i)
+		if err := db.UpsertExtension(ext); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, tt := range []struct {
+		page, want int
+	}{{1, 2}, {2, 2}, {3, 1}, {4, 0}, {math.MaxInt, 0}, {-1, 0}} {
+		exts, total, err := db.GetAllExtensions(tt.page, 2)
+		if err != nil {
+			t.Fatalf("page %d: %v", tt.page, err)
+		}
+		if len(exts) != tt.want || total != 5 {
+			t.Errorf("page %d: got %d of %d, want %d of 5", tt.page, len(exts), total, tt.want)
+		}
+		for _, ext := range exts {
+			if seen[ext.ID] {
+				t.Errorf("page %d repeats %s", tt.page, ext.ID)
+			}
+			seen[ext.ID] = true
+		}
+	}
+}
+
+// TestMigrateExtensionIDs opens a database in which older versions stored
+// the "publisher.name" ID as extension ID and checks that the builds get
+// the gallery UUID served to clients, while real UUIDs are kept.
+func TestMigrateExtensionIDs(t *testing.T) {
+	testutil.TempConfig(t, nil)
+	db, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const galleryUUID = "f1f59ae4-9318-4f3c-a9b5-81b2eaa5f8a5"
+	for _, build := range []struct{ id, platform, extensionID string }{
+		{"acme.tool", "linux-x64", "acme.tool"},
+		{"acme.tool", "win32-x64", ""},
+		{"acme.other", "universal", galleryUUID},
+	} {
+		ext := testBuild("1.0.0", build.platform)
+		ext.ID = build.id
+		ext.ExtensionID = build.extensionID
+		if err := db.UpsertExtension(ext); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	db, err = New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for extensionID, want := range map[string]string{
+		utils.ExtensionUUID("", "acme.tool"): "acme.tool",
+		galleryUUID:                          "acme.other",
+		"acme.tool":                          "",
+	} {
+		ext, err := db.GetExtensionByExtensionID(extensionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case want == "" && ext != nil:
+			t.Errorf("GetExtensionByExtensionID(%q) = %s, want none", extensionID, ext.ID)
+		case want != "" && (ext == nil || ext.ID != want):
+			t.Errorf("GetExtensionByExtensionID(%q) = %v, want %s", extensionID, ext, want)
+		}
+	}
+	builds, err := db.GetExtensionVersions("acme.tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, build := range builds {
+		if build.ExtensionID != utils.ExtensionUUID("", "acme.tool") {
+			t.Errorf("%s build has extension ID %q", build.TargetPlatform, build.ExtensionID)
+		}
+	}
+}
+
+// TestQueriesUseIndexes checks with EXPLAIN QUERY PLAN that the lookups by
+// extension ID, version and build are answered from an index rather than
+// a scan of the table.
+func TestQueriesUseIndexes(t *testing.T) {
+	db := newTestDatabase(t)
+	for _, build := range [][2]string{{"1.0.0", "universal"}, {"1.0.0", "linux-x64"}, {"0.9.0", "universal"}} {
+		if err := db.UpsertExtension(testBuild(build[0], build[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		args  []interface{}
+		index string
+	}{
+		{
+			name:  "by extension ID",
+			query: `SELECT * FROM extensions WHERE extension_id = ? COLLATE NOCASE ORDER BY is_latest DESC LIMIT 1`,
+			args:  []interface{}{"9dd848a3-2f07-56a1-9dc3-90c56d36789b"},
+			index: "idx_extensions_extension_id",
+		},
+		{
+			name:  "build",
+			query: `SELECT * FROM extensions WHERE id = ? AND version = ? AND target_platform = ?`,
+			args:  []interface{}{"acme.tool", "1.0.0", "linux-x64"},
+			index: "sqlite_autoindex_extensions_1",
+		},
+		{
+			name:  "builds of a version",
+			query: `SELECT * FROM extensions WHERE id = ? AND version = ?` + buildOrderSQL,
+			args:  []interface{}{"acme.tool", "1.0.0"},
+			index: "sqlite_autoindex_extensions_1",
+		},
+		{
+			name:  "version across extensions",
+			query: `SELECT * FROM extensions WHERE version = ? AND target_platform = ?`,
+			args:  []interface{}{"1.0.0", "universal"},
+			index: "idx_extensions_version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := db.db.Query(`EXPLAIN QUERY PLAN `+tt.query, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			var plan []string
+			for rows.Next() {
+				var id, parent, unused int
+				var detail string
+				if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+					t.Fatal(err)
+				}
+				plan = append(plan, detail)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			details := strings.Join(plan, "; ")
+			if !strings.Contains(details, "INDEX "+tt.index) {
+				t.Errorf("plan %q does not use %s", details, tt.index)
+			}
+			if strings.Contains(details, "SCAN extensions") {
+				t.Errorf("plan %q scans the table", details)
+			}
+		})
+	}
+}
+
+func TestFindExtensionID(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.UpsertExtension(testBuild("1.0.0", "universal")); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]string{
+		"acme.tool":  "acme.tool",
+		"Acme.Tool":  "acme.tool",
+		"ACME.TOOL":  "acme.tool",
+		"acme.other": "",
+	} {
+		got, err := db.FindExtensionID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("FindExtensionID(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
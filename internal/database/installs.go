@@ -0,0 +1,44 @@
+package database
+
+import "time"
+
+// The installs table records which users downloaded an extension, by a hash
+// of the X-Market-User-Id clients send. It is only written to when
+// statistics.track_users is enabled.
+const createInstallsTableSQL = `
+	CREATE TABLE IF NOT EXISTS installs (
+		extension_id TEXT NOT NULL COLLATE NOCASE,
+		user_hash TEXT NOT NULL,
+		first_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (extension_id, user_hash)
+	);
+`
+
+// RecordInstall notes that the user with the given hash downloaded an
+// extension. Repeated downloads by the same user are counted once.
+func (d *Database) RecordInstall(extensionID, userHash string) error {
+	_, err := d.db.Exec(`INSERT OR IGNORE INTO installs (extension_id, user_hash, first_seen) VALUES (?, ?, ?)`,
+		extensionID, userHash, time.Now())
+	return err
+}
+
+// GetUniqueInstalls returns the number of distinct users that downloaded
+// each extension.
+func (d *Database) GetUniqueInstalls() (map[string]int64, error) {
+	rows, err := d.db.Query(`SELECT extension_id, COUNT(*) FROM installs GROUP BY extension_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	installs := make(map[string]int64)
+	for rows.Next() {
+		var extensionID string
+		var count int64
+		if err := rows.Scan(&extensionID, &count); err != nil {
+			return nil, err
+		}
+		installs[extensionID] = count
+	}
+	return installs, rows.Err()
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PublisherDB holds the settings of a publisher. Publishers without a record
+// are shown with their name only and without a verified domain.
+type PublisherDB struct {
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+	Domain      string    `json:"domain"`
+	Verified    bool      `json:"verified"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+const createPublishersTableSQL = `
+	CREATE TABLE IF NOT EXISTS publishers (
+		name TEXT PRIMARY KEY COLLATE NOCASE,
+		display_name TEXT DEFAULT '',
+		domain TEXT DEFAULT '',
+		verified BOOLEAN DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// UpsertPublisher inserts or replaces the record of a publisher.
+func (d *Database) UpsertPublisher(pub *PublisherDB) error {
+	query := `
+		INSERT INTO publishers (name, display_name, domain, verified, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			display_name = excluded.display_name, domain = excluded.domain,
+			verified = excluded.verified, updated_at = excluded.updated_at
+	`
+	_, err := d.db.Exec(query, pub.Name, pub.DisplayName, pub.Domain, pub.Verified, pub.UpdatedAt)
+	return err
+}
+
+// GetPublisher returns the record of a publisher, matched case-insensitively,
+// or nil if there is none.
+func (d *Database) GetPublisher(name string) (*PublisherDB, error) {
+	query := `SELECT name, display_name, domain, verified, updated_at FROM publishers WHERE name = ?`
+
+	pub, err := scanPublisher(d.db.QueryRow(query, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return pub, nil
+}
+
+func (d *Database) GetAllPublishers() ([]PublisherDB, error) {
+	rows, err := d.db.Query(`SELECT name, display_name, domain, verified, updated_at FROM publishers ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var publishers []PublisherDB
+	for rows.Next() {
+		pub, err := scanPublisher(rows)
+		if err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, *pub)
+	}
+	return publishers, rows.Err()
+}
+
+// DeletePublisher removes the record of a publisher. It returns false if
+// there was none.
+func (d *Database) DeletePublisher(name string) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM publishers WHERE name = ?`, name)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func scanPublisher(row rowScanner) (*PublisherDB, error) {
+	var pub PublisherDB
+	if err := row.Scan(&pub.Name, &pub.DisplayName, &pub.Domain, &pub.Verified, &pub.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &pub, nil
+}
@@ -0,0 +1,107 @@
+package extensions
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"littlevsx/internal/database"
+	"littlevsx/internal/storage"
+)
+
+// zipEntry is an archive entry with an explicit file mode, for building
+// packages testutil.VSIX cannot describe.
+type zipEntry struct {
+	name    string
+	mode    fs.FileMode
+	content string
+}
+
+func writeZip(t *testing.T, path string, entries []zipEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	zw := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		header.SetMode(entry.mode)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestArchiveSkipsNonRegularEntries reads a package whose README is a
+// symlink and whose LICENSE is a directory entry and checks that neither
+// is served as content.
+func TestArchiveSkipsNonRegularEntries(t *testing.T) {
+	m := newTestManager(t, nil)
+	path := filepath.Join(m.directory, "tool-1.0.0.vsix")
+	writeZip(t, path, []zipEntry{
+		{"extension/", fs.ModeDir | 0755, ""},
+		{"extension/package.json", 0644, `{"publisher":"acme","name":"tool","version":"1.0.0","engines":{"vscode":"^1.70.0"}}`},
+		{"extension/README.md", fs.ModeSymlink | 0777, "/etc/passwd"},
+		{"extension/LICENSE.md", fs.ModeDir | 0755, ""},
+		{"extension/LICENSE", fs.ModeSymlink | 0777, "../../secret"},
+		{"extension/CHANGELOG.md", 0644, "## 1.0.0"},
+		{"extension/images/", fs.ModeDir | 0755, ""},
+	})
+
+	ext, err := m.ReadExtensionInfo(path)
+	if err != nil {
+		t.Fatalf("ReadExtensionInfo: %v", err)
+	}
+	if ext.ReadmeContent != "" {
+		t.Errorf("ReadmeContent = %q, want the symlink skipped", ext.ReadmeContent)
+	}
+	if err := m.db.UpsertExtension(database.ToDBExtension(ext)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		read    func() ([]byte, error)
+		want    string
+		wantErr bool
+	}{
+		{"symlinked README", func() ([]byte, error) { return m.extractFirst(path, readmePaths) }, "", true},
+		{"directory and symlink LICENSE", func() ([]byte, error) { return m.ReadLicense(ext) }, "", true},
+		{"regular CHANGELOG", func() ([]byte, error) { return m.ReadChangelog(ext) }, "## 1.0.0", false},
+		{"directory entry by name", func() ([]byte, error) { return storage.ReadEntry(m.storage, path, "extension/images/") }, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.read()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("read %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || string(got) != tt.want {
+				t.Errorf("read %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := storage.OpenEntry(m.storage, path, "extension/README.md"); err == nil {
+		t.Error("OpenEntry opened the symlinked README")
+	}
+	if err := m.ValidateVSIX(path); err == nil || !strings.Contains(err.Error(), "symlink") {
+		t.Errorf("ValidateVSIX = %v, want a symlink error", err)
+	}
+}
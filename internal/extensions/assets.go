@@ -1,31 +1,93 @@
 package extensions
 
 import (
+	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
+	"html"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
+
+	"littlevsx/internal/config"
+)
+
+const (
+	defaultDownloadConcurrency = 4
+	defaultDownloadTimeout     = 30 * time.Second
+)
+
+// assetTouchInterval limits how often serving an asset refreshes its
+// modification time, which prune-assets uses as the time of last access.
+const assetTouchInterval = time.Hour
+
+// errAssetTooLarge marks assets skipped because they exceed the configured
+// maximum size. Their README references keep pointing at the remote URL.
+var errAssetTooLarge = errors.New("asset exceeds the maximum size")
+
+var (
+	imagePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\)`),
+		regexp.MustCompile(`<img[^>]+src=["']([^"']+)["'][^>]*>`),
+		regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\s*"([^"]*)"\)`),
+	}
+	otherAssetPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`<link[^>]+href=["']([^"']+)["'][^>]*>`),
+		regexp.MustCompile(`<script[^>]+src=["']([^"']+)["'][^>]*>`),
+		regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`),
+	}
 )
 
 type AssetProcessor struct {
-	assetsDir string
-	baseURL   string
+	assetsDir   string
+	baseURL     string
+	urlPrefix   string
+	concurrency int
+	client      *http.Client
+
+	// maxSize is the largest asset in bytes that is downloaded, 0 for no limit.
+	maxSize int64
 }
 
 func NewAssetProcessor(assetsDir, baseURL string) *AssetProcessor {
+	cfg := config.GetConfig()
+	concurrency := cfg.AssetsDownloadConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultDownloadConcurrency
+	}
+
+	timeout := time.Duration(cfg.AssetsDownloadTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultDownloadTimeout
+	}
+
+	maxSize := int64(cfg.AssetsMaxSizeMB) * 1024 * 1024
+	if maxSize < 0 {
+		maxSize = 0
+	}
+
 	return &AssetProcessor{
-		assetsDir: assetsDir,
-		baseURL:   baseURL,
+		assetsDir:   assetsDir,
+		baseURL:     baseURL,
+		urlPrefix:   cfg.AssetsRoutePrefix(),
+		concurrency: concurrency,
+		client:      &http.Client{Timeout: timeout},
+		maxSize:     maxSize,
 	}
 }
 
-func (ap *AssetProcessor) ProcessReadme(readmeContent, extensionID string) (string, error) {
+// ProcessReadme downloads the assets referenced by the README and rewrites
+// their URLs to the local copies. Cancelling ctx stops pending downloads;
+// assets that were not downloaded keep their remote URL.
+func (ap *AssetProcessor) ProcessReadme(ctx context.Context, readmeContent, extensionID string) (string, error) {
 	if readmeContent == "" {
 		return "", nil
 	}
@@ -35,52 +97,159 @@ func (ap *AssetProcessor) ProcessReadme(readmeContent, extensionID string) (stri
 		return "", fmt.Errorf("failed to create asset directory: %w", err)
 	}
 
-	processedContent := ap.processImages(readmeContent, extensionAssetsDir, extensionID)
-	processedContent = ap.processOtherAssets(processedContent, extensionAssetsDir, extensionID)
+	assetURLs := ap.collectAssetURLs(readmeContent)
+	downloaded := ap.downloadAssets(ctx, assetURLs, extensionAssetsDir)
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	processedContent := ap.processImages(readmeContent, downloaded, extensionID)
+	processedContent = ap.processOtherAssets(processedContent, downloaded, extensionID)
 
 	return processedContent, nil
 }
 
-func (ap *AssetProcessor) processImages(content, assetsDir, extensionID string) string {
-	patterns := []*regexp.Regexp{
-		regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\)`),
-		regexp.MustCompile(`<img[^>]+src=["']([^"']+)["'][^>]*>`),
-		regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\s*"([^"]*)"\)`),
+// collectAssetURLs returns every distinct asset referenced by the README, as
+// assetKey values in order of first appearance, so downloads can run before
+// any rewriting.
+func (ap *AssetProcessor) collectAssetURLs(content string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+
+	collect := func(patterns []*regexp.Regexp, skip func(string) bool) {
+		for _, pattern := range patterns {
+			for _, matches := range pattern.FindAllStringSubmatch(content, -1) {
+				assetURL := matchedURL(matches)
+				if assetURL == "" || skip(assetURL) {
+					continue
+				}
+				key := assetKey(assetURL)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				urls = append(urls, key)
+			}
+		}
+	}
+
+	collect(imagePatterns, skipImageURL)
+	collect(otherAssetPatterns, skipOtherAssetURL)
+
+	return urls
+}
+
+// downloadAssets fetches the given URLs through a bounded worker pool and
+// returns a map of successfully downloaded URLs to their local file names.
+func (ap *AssetProcessor) downloadAssets(ctx context.Context, urls []string, assetsDir string) map[string]string {
+	collisions := conflictingFileNames(urls)
+	downloaded := make(map[string]string, len(urls))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, ap.concurrency)
+
+	for _, assetURL := range urls {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return downloaded
+		}
+		wg.Add(1)
+
+		go func(assetURL string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fileName, err := ap.downloadAsset(ctx, assetURL, assetsDir, collisions)
+			if errors.Is(err, errAssetTooLarge) {
+				fmt.Printf("Skipped asset %s: %v\n", assetURL, err)
+				return
+			}
+			if err != nil {
+				fmt.Printf("Failed to download asset %s: %v\n", assetURL, err)
+				return
+			}
+
+			mu.Lock()
+			downloaded[assetURL] = fileName
+			mu.Unlock()
+		}(assetURL)
 	}
 
-	for _, pattern := range patterns {
+	wg.Wait()
+	return downloaded
+}
+
+// assetKey identifies the resource an asset URL refers to. The fragment is
+// dropped, while the query is kept as it may select the content, e.g.
+// GitHub's "?raw=true".
+func assetKey(assetURL string) string {
+	if i := strings.IndexByte(assetURL, '#'); i >= 0 {
+		return assetURL[:i]
+	}
+	return assetURL
+}
+
+// localAssetURL returns the URL the asset is served from by LittleVSX under
+// assets.url_prefix, keeping the fragment of the original reference.
+func (ap *AssetProcessor) localAssetURL(extensionID, fileName, assetURL string) string {
+	localURL := fmt.Sprintf("%s%s/%s/%s", ap.baseURL, ap.urlPrefix, extensionID, url.PathEscape(fileName))
+	if i := strings.IndexByte(assetURL, '#'); i >= 0 {
+		localURL += assetURL[i:]
+	}
+	return localURL
+}
+
+func matchedURL(matches []string) string {
+	if len(matches) < 2 {
+		return ""
+	}
+	if len(matches) >= 3 {
+		return matches[2]
+	}
+	return matches[1]
+}
+
+func skipImageURL(imageURL string) bool {
+	return strings.HasPrefix(imageURL, "data:") || strings.HasPrefix(imageURL, "#")
+}
+
+func skipOtherAssetURL(assetURL string) bool {
+	return strings.HasPrefix(assetURL, "data:") ||
+		strings.HasPrefix(assetURL, "#") ||
+		strings.HasPrefix(assetURL, "http")
+}
+
+func (ap *AssetProcessor) processImages(content string, downloaded map[string]string, extensionID string) string {
+	for _, pattern := range imagePatterns {
 		content = pattern.ReplaceAllStringFunc(content, func(match string) string {
-			return ap.processImageMatch(match, pattern, assetsDir, extensionID)
+			return ap.processImageMatch(match, pattern, downloaded, extensionID)
 		})
 	}
 
 	return content
 }
 
-func (ap *AssetProcessor) processImageMatch(match string, pattern *regexp.Regexp, assetsDir, extensionID string) string {
+func (ap *AssetProcessor) processImageMatch(match string, pattern *regexp.Regexp, downloaded map[string]string, extensionID string) string {
 	matches := pattern.FindStringSubmatch(match)
 	if len(matches) < 2 {
 		return match
 	}
 
-	var imageURL string
-	if len(matches) >= 3 {
-		imageURL = matches[2]
-	} else {
-		imageURL = matches[1]
-	}
+	imageURL := matchedURL(matches)
 
-	if strings.HasPrefix(imageURL, "data:") || strings.HasPrefix(imageURL, "#") {
+	if skipImageURL(imageURL) {
 		return match
 	}
 
-	localPath, err := ap.downloadAsset(imageURL, assetsDir)
-	if err != nil {
-		fmt.Printf("Failed to download image %s: %v\n", imageURL, err)
+	fileName, ok := downloaded[assetKey(imageURL)]
+	if !ok {
 		return match
 	}
 
-	localURL := fmt.Sprintf("%s/_assets/%s/%s", ap.baseURL, extensionID, filepath.Base(localPath))
+	localURL := ap.localAssetURL(extensionID, fileName, imageURL)
 
 	if strings.Contains(match, "![") {
 		if len(matches) >= 3 {
@@ -92,48 +261,34 @@ func (ap *AssetProcessor) processImageMatch(match string, pattern *regexp.Regexp
 	}
 }
 
-func (ap *AssetProcessor) processOtherAssets(content, assetsDir, extensionID string) string {
-	patterns := []*regexp.Regexp{
-		regexp.MustCompile(`<link[^>]+href=["']([^"']+)["'][^>]*>`),
-		regexp.MustCompile(`<script[^>]+src=["']([^"']+)["'][^>]*>`),
-		regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`),
-	}
-
-	for _, pattern := range patterns {
+func (ap *AssetProcessor) processOtherAssets(content string, downloaded map[string]string, extensionID string) string {
+	for _, pattern := range otherAssetPatterns {
 		content = pattern.ReplaceAllStringFunc(content, func(match string) string {
-			return ap.processAssetMatch(match, pattern, assetsDir, extensionID)
+			return ap.processAssetMatch(match, pattern, downloaded, extensionID)
 		})
 	}
 
 	return content
 }
 
-func (ap *AssetProcessor) processAssetMatch(match string, pattern *regexp.Regexp, assetsDir, extensionID string) string {
+func (ap *AssetProcessor) processAssetMatch(match string, pattern *regexp.Regexp, downloaded map[string]string, extensionID string) string {
 	matches := pattern.FindStringSubmatch(match)
 	if len(matches) < 2 {
 		return match
 	}
 
-	var assetURL string
-	if len(matches) >= 3 {
-		assetURL = matches[2]
-	} else {
-		assetURL = matches[1]
-	}
+	assetURL := matchedURL(matches)
 
-	if strings.HasPrefix(assetURL, "data:") ||
-		strings.HasPrefix(assetURL, "#") ||
-		strings.HasPrefix(assetURL, "http") {
+	if skipOtherAssetURL(assetURL) {
 		return match
 	}
 
-	localPath, err := ap.downloadAsset(assetURL, assetsDir)
-	if err != nil {
-		fmt.Printf("Failed to download asset %s: %v\n", assetURL, err)
+	fileName, ok := downloaded[assetKey(assetURL)]
+	if !ok {
 		return match
 	}
 
-	localURL := fmt.Sprintf("%s/_assets/%s/%s", ap.baseURL, extensionID, filepath.Base(localPath))
+	localURL := ap.localAssetURL(extensionID, fileName, assetURL)
 
 	if strings.Contains(match, "<link") || strings.Contains(match, "<script") {
 		return strings.Replace(match, assetURL, localURL, 1)
@@ -142,12 +297,14 @@ func (ap *AssetProcessor) processAssetMatch(match string, pattern *regexp.Regexp
 	}
 }
 
-func (ap *AssetProcessor) downloadAsset(assetURL, assetsDir string) (string, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+func (ap *AssetProcessor) downloadAsset(ctx context.Context, assetURL, assetsDir string, collisions map[string]bool) (string, error) {
+	// URLs taken from HTML attributes may contain entities such as "&amp;".
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, html.UnescapeString(assetURL), nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid asset URL: %w", err)
 	}
 
-	resp, err := client.Get(assetURL)
+	resp, err := ap.client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("http request error: %w", err)
 	}
@@ -157,31 +314,95 @@ func (ap *AssetProcessor) downloadAsset(assetURL, assetsDir string) (string, err
 		return "", fmt.Errorf("invalid status code: %d", resp.StatusCode)
 	}
 
+	if ap.maxSize > 0 && resp.ContentLength > ap.maxSize {
+		return "", fmt.Errorf("%w of %d bytes (Content-Length %d)", errAssetTooLarge, ap.maxSize, resp.ContentLength)
+	}
+
 	fileName := ap.generateFileName(assetURL, resp.Header.Get("Content-Type"))
+	if collisions[fileName] {
+		hash := fmt.Sprintf("%x", md5.Sum([]byte(assetURL)))
+		fileName = hash[:8] + "-" + fileName
+	}
 	filePath := filepath.Join(assetsDir, fileName)
 
 	file, err := os.Create(filePath)
 	if err != nil {
 		return "", fmt.Errorf("file creation error: %w", err)
 	}
-	defer file.Close()
 
-	_, err = io.Copy(file, resp.Body)
+	// The body is read up to one byte past the limit to detect servers that
+	// send no or a wrong Content-Length.
+	body := io.Reader(resp.Body)
+	if ap.maxSize > 0 {
+		body = io.LimitReader(resp.Body, ap.maxSize+1)
+	}
+
+	written, err := io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && ap.maxSize > 0 && written > ap.maxSize {
+		err = fmt.Errorf("%w of %d bytes", errAssetTooLarge, ap.maxSize)
+	}
 	if err != nil {
+		os.Remove(filePath)
+		if errors.Is(err, errAssetTooLarge) {
+			return "", err
+		}
 		return "", fmt.Errorf("file copy error: %w", err)
 	}
 
 	return fileName, nil
 }
 
-func (ap *AssetProcessor) generateFileName(assetURL, contentType string) string {
-	parsedURL, err := url.Parse(assetURL)
+// TouchAsset records that a cached asset was served by setting its
+// modification time to now, at most once per assetTouchInterval.
+func TouchAsset(path string, info os.FileInfo) {
+	now := time.Now()
+	if now.Sub(info.ModTime()) < assetTouchInterval {
+		return
+	}
+	if err := os.Chtimes(path, now, now); err != nil {
+		log.Printf("Failed to update access time of asset %s: %v", path, err)
+	}
+}
+
+// conflictingFileNames returns the file names derived from more than one of
+// the given URLs, which must be disambiguated to keep downloads independent.
+func conflictingFileNames(urls []string) map[string]bool {
+	counts := make(map[string]int)
+	for _, assetURL := range urls {
+		if name := urlFileName(assetURL); name != "" {
+			counts[name]++
+		}
+	}
+
+	collisions := make(map[string]bool)
+	for name, count := range counts {
+		if count > 1 {
+			collisions[name] = true
+		}
+	}
+	return collisions
+}
+
+// urlFileName returns the last path segment of the URL, ignoring its query
+// and fragment.
+func urlFileName(assetURL string) string {
+	parsedURL, err := url.Parse(html.UnescapeString(assetURL))
 	if err == nil && parsedURL.Path != "" {
 		fileName := filepath.Base(parsedURL.Path)
-		if fileName != "" && fileName != "." {
+		if fileName != "" && fileName != "." && fileName != "/" {
 			return fileName
 		}
 	}
+	return ""
+}
+
+func (ap *AssetProcessor) generateFileName(assetURL, contentType string) string {
+	if fileName := urlFileName(assetURL); fileName != "" {
+		return fileName
+	}
 
 	hash := fmt.Sprintf("%x", md5.Sum([]byte(assetURL)))
 
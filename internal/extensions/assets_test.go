@@ -0,0 +1,259 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"littlevsx/internal/testutil"
+)
+
+// imageServer serves testutil.PNG for every path after delay and records
+// the most requests it handled at once.
+type imageServer struct {
+	*httptest.Server
+	delay time.Duration
+
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+	requests    atomic.Int32
+}
+
+func newImageServer(tb testing.TB, delay time.Duration) *imageServer {
+	tb.Helper()
+	s := &imageServer{delay: delay}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		n := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		for {
+			max := s.maxInFlight.Load()
+			if n <= max || s.maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(s.delay)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(testutil.PNG)
+	}))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// newTestAssetProcessor returns an AssetProcessor downloading into a
+// temporary directory with at most concurrency parallel downloads.
+func newTestAssetProcessor(tb testing.TB, concurrency int) *AssetProcessor {
+	return &AssetProcessor{
+		assetsDir:   tb.TempDir(),
+		baseURL:     "http://gallery.test",
+		urlPrefix:   "/_assets",
+		concurrency: concurrency,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// imageReadme returns a README referencing n distinct images on serverURL,
+// each twice and in both Markdown and HTML syntax.
+func imageReadme(serverURL string, n int) string {
+	var readme strings.Builder
+	readme.WriteString("# Tool\n\n")
+	for i := range n {
+		fmt.Fprintf(&readme, "![shot %d](%s/images/shot%d.png)\n", i, serverURL, i)
+	}
+	for i := range n {
+		fmt.Fprintf(&readme, "<img src=\"%s/images/shot%d.png\" width=\"200\">\n", serverURL, i)
+	}
+	return readme.String()
+}
+
+// TestProcessReadmeConcurrency checks that README images are downloaded
+// once each, never with more than the configured number of parallel
+// downloads, and that the result does not depend on the concurrency.
+func TestProcessReadmeConcurrency(t *testing.T) {
+	const images = 20
+
+	var want string
+	for _, concurrency := range []int{1, 4, 32} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			srv := newImageServer(t, 5*time.Millisecond)
+			ap := newTestAssetProcessor(t, concurrency)
+			readme := imageReadme(srv.URL, images)
+
+			got, err := ap.ProcessReadme(context.Background(), readme, "acme.tool")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := srv.requests.Load(); n != images {
+				t.Errorf("%d requests, want %d", n, images)
+			}
+			if n := srv.maxInFlight.Load(); n > int32(concurrency) {
+				t.Errorf("%d downloads at once, want at most %d", n, concurrency)
+			}
+			if strings.Contains(got, srv.URL) {
+				t.Errorf("remote URLs left in README:\n%s", got)
+			}
+			for i := range images {
+				if _, err := os.Stat(filepath.Join(ap.assetsDir, "acme.tool", fmt.Sprintf("shot%d.png", i))); err != nil {
+					t.Errorf("shot%d.png was not stored: %v", i, err)
+				}
+			}
+
+			again, err := ap.ProcessReadme(context.Background(), readme, "acme.tool")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != got {
+				t.Errorf("second run differs:\n%s\nfirst run:\n%s", again, got)
+			}
+			if want == "" {
+				want = got
+			} else if got != want {
+				t.Errorf("README differs from the one processed with concurrency 1:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestProcessReadmeCancel checks that cancelling the context stops the
+// pending downloads and leaves the README alone.
+func TestProcessReadmeCancel(t *testing.T) {
+	srv := newImageServer(t, 50*time.Millisecond)
+	ap := newTestAssetProcessor(t, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := ap.ProcessReadme(ctx, imageReadme(srv.URL, 20), "acme.tool"); err == nil {
+		t.Error("ProcessReadme succeeded after its context was cancelled")
+	}
+	if n := srv.requests.Load(); n > 4 {
+		t.Errorf("%d downloads started after cancelling, want at most 4", n)
+	}
+}
+
+// BenchmarkProcessReadme processes a README with 20 images served with a
+// few milliseconds of latency each.
+func BenchmarkProcessReadme(b *testing.B) {
+	srv := newImageServer(b, 2*time.Millisecond)
+	readme := imageReadme(srv.URL, 20)
+
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			ap := newTestAssetProcessor(b, concurrency)
+			for range b.N {
+				if _, err := ap.ProcessReadme(context.Background(), readme, "acme.tool"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestProcessReadmeURLs covers README image references with query strings,
+// fragments and HTML entities, as GitHub "?raw=true" links have.
+func TestProcessReadmeURLs(t *testing.T) {
+	// paths records the path and query of every download.
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.RequestURI())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(testutil.PNG)
+	}))
+	defer srv.Close()
+
+	const local = "http://gallery.test/_assets/acme.tool/"
+	tests := []struct {
+		name      string
+		readme    string
+		want      string
+		wantFetch []string
+	}{
+		{
+			name:      "GitHub raw link",
+			readme:    "![demo](URL/acme/tool/blob/main/images/demo.png?raw=true)",
+			want:      "![demo](" + local + "demo.png)",
+			wantFetch: []string{"/acme/tool/blob/main/images/demo.png?raw=true"},
+		},
+		{
+			name:      "fragment is kept on the local URL",
+			readme:    "![logo](URL/images/logo.png#gh-dark-mode-only)",
+			want:      "![logo](" + local + "logo.png#gh-dark-mode-only)",
+			wantFetch: []string{"/images/logo.png"},
+		},
+		{
+			name:      "HTML entities in the query",
+			readme:    `<img src="URL/badge.png?style=flat&amp;logo=go" width="80">`,
+			want:      `<img src="` + local + `badge.png" width="80">`,
+			wantFetch: []string{"/badge.png?style=flat&logo=go"},
+		},
+		{
+			name:      "same file with and without fragment is downloaded once",
+			readme:    "![a](URL/img/shot.png?raw=true#light) ![b](URL/img/shot.png?raw=true#dark)",
+			want:      "![a](" + local + "shot.png#light) ![b](" + local + "shot.png#dark)",
+			wantFetch: []string{"/img/shot.png?raw=true"},
+		},
+		{
+			name:      "query only in the file name",
+			readme:    "![icon](URL/?file=icon.png)",
+			wantFetch: []string{"/?file=icon.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths = nil
+			ap := newTestAssetProcessor(t, 2)
+			got, err := ap.ProcessReadme(context.Background(), strings.ReplaceAll(tt.readme, "URL", srv.URL), "acme.tool")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("README = %q, want %q", got, tt.want)
+			}
+			if strings.Contains(got, srv.URL) {
+				t.Errorf("README still points at the remote URL: %q", got)
+			}
+			if strings.Join(paths, " ") != strings.Join(tt.wantFetch, " ") {
+				t.Errorf("downloaded %q, want %q", paths, tt.wantFetch)
+			}
+		})
+	}
+}
+
+// TestProcessReadmeQueryCollisions checks that images differing only in
+// their query get separate local files.
+func TestProcessReadmeQueryCollisions(t *testing.T) {
+	srv := newImageServer(t, 0)
+	ap := newTestAssetProcessor(t, 2)
+	readme := fmt.Sprintf("![light](%[1]s/logo.png?theme=light) ![dark](%[1]s/logo.png?theme=dark)", srv.URL)
+
+	got, err := ap.ProcessReadme(context.Background(), readme, "acme.tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(ap.assetsDir, "acme.tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("stored %d files, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), "-logo.png") {
+			t.Errorf("file %s is not a disambiguated logo.png", entry.Name())
+		}
+		if strings.Count(got, "/acme.tool/"+entry.Name()+")") != 1 {
+			t.Errorf("README does not reference %s once: %s", entry.Name(), got)
+		}
+	}
+}
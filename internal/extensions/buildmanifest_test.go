@@ -0,0 +1,60 @@
+package extensions
+
+import (
+	"encoding/json"
+	"testing"
+
+	"littlevsx/internal/models"
+	"littlevsx/internal/testutil"
+	"littlevsx/internal/utils"
+)
+
+// buildMetadata returns the "__metadata" object of the manifest BuildManifest
+// generates for ext.
+func buildMetadata(t *testing.T, m *Manager, ext *models.Extension) map[string]interface{} {
+	t.Helper()
+	content, err := m.BuildManifest(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Metadata map[string]interface{} `json:"__metadata"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		t.Fatalf("failed to decode %s: %v", content, err)
+	}
+	return manifest.Metadata
+}
+
+func TestBuildManifestPublisherDisplayName(t *testing.T) {
+	tests := []struct {
+		name   string
+		record *models.Publisher
+		want   string
+	}{
+		{"no record", nil, "acme"},
+		{"record with display name", &models.Publisher{Name: "Acme", DisplayName: "Acme Corp"}, "Acme Corp"},
+		{"record without display name", &models.Publisher{Name: "acme", Domain: "acme.example"}, "acme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, nil)
+			ext := storeVSIX(t, m, testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"})
+			if tt.record != nil {
+				if err := m.SetPublisher(tt.record); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			metadata := buildMetadata(t, m, ext)
+			if got := metadata["publisherDisplayName"]; got != tt.want {
+				t.Errorf("publisherDisplayName = %v, want %s", got, tt.want)
+			}
+			// The same ID gallery responses report for the publisher.
+			if got, want := metadata["publisherId"], utils.PublisherUUID("acme"); got != want {
+				t.Errorf("publisherId = %v, want %s", got, want)
+			}
+		})
+	}
+}
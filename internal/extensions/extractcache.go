@@ -0,0 +1,113 @@
+package extensions
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ExtractCacheStats reports the state of the extraction cache since the
+// manager was created.
+type ExtractCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+}
+
+// extractCache keeps files extracted from .vsix packages, bounded by their
+// total size. Lookups only take the read lock; entries are evicted in
+// insertion order, except that an entry read since it was last considered
+// gets a second chance (the CLOCK approximation of LRU).
+type extractCache struct {
+	maxBytes int64
+
+	mu      sync.RWMutex
+	entries map[string]*extractEntry
+	order   []string
+	size    int64
+
+	hits, misses, evictions atomic.Int64
+}
+
+type extractEntry struct {
+	content    []byte
+	referenced atomic.Bool
+}
+
+// newExtractCache returns a cache holding at most maxBytes of content. With
+// maxBytes <= 0 nothing is cached, but misses are still counted.
+func newExtractCache(maxBytes int64) *extractCache {
+	return &extractCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*extractEntry),
+	}
+}
+
+func (c *extractCache) get(key string) ([]byte, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	entry.referenced.Store(true)
+	c.hits.Add(1)
+	return entry.content, true
+}
+
+// put stores content under key unless it is larger than the whole cache.
+// When concurrent misses extract the same file, the first result is kept.
+func (c *extractCache) put(key string, content []byte) {
+	size := int64(len(content))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	// Second chances are limited to one pass over the entries so that
+	// readers marking entries concurrently cannot keep this loop going.
+	spared := 0
+	for c.size+size > c.maxBytes && len(c.order) > 0 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+
+		entry := c.entries[oldest]
+		if entry.referenced.Swap(false) && spared < len(c.order) {
+			c.order = append(c.order, oldest)
+			spared++
+			continue
+		}
+		delete(c.entries, oldest)
+		c.size -= int64(len(entry.content))
+		c.evictions.Add(1)
+	}
+
+	c.entries[key] = &extractEntry{content: content}
+	c.order = append(c.order, key)
+	c.size += size
+}
+
+func (c *extractCache) stats() ExtractCacheStats {
+	c.mu.RLock()
+	entries, size := len(c.entries), c.size
+	c.mu.RUnlock()
+
+	return ExtractCacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   entries,
+		Bytes:     size,
+		MaxBytes:  c.maxBytes,
+	}
+}
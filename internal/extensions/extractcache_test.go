@@ -0,0 +1,108 @@
+package extensions
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+// TestReadPackageJSONConcurrent reads the same entry from many goroutines,
+// as concurrent asset requests do; run with -race.
+func TestReadPackageJSONConcurrent(t *testing.T) {
+	m := newTestManager(t, map[string]interface{}{"cache.extract_max_mb": 1})
+	ext := storeVSIX(t, m, testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"})
+
+	want, err := m.ReadPackageJSON(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, reads = 16, 50
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range reads {
+				got, err := m.ReadPackageJSON(ext)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("ReadPackageJSON = %q, want %q", got, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := m.ExtractCacheStats()
+	if stats.Misses != 1 || stats.Hits != workers*reads {
+		t.Errorf("hits = %d, misses = %d, want %d and 1", stats.Hits, stats.Misses, workers*reads)
+	}
+	if stats.Entries != 1 || stats.Bytes != int64(len(want)) {
+		t.Errorf("cache holds %d entries of %d bytes, want 1 of %d", stats.Entries, stats.Bytes, len(want))
+	}
+}
+
+// TestExtractCacheConcurrentEviction fills a small cache from many
+// goroutines and checks that it stays within its bound and that its size
+// matches its entries.
+func TestExtractCacheConcurrentEviction(t *testing.T) {
+	const maxBytes = 1000
+	c := newExtractCache(maxBytes)
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				key := fmt.Sprintf("entry-%d", (worker*7+i)%40)
+				if content, ok := c.get(key); ok {
+					if len(content) != 100 {
+						t.Errorf("%s has %d bytes, want 100", key, len(content))
+						return
+					}
+					continue
+				}
+				c.put(key, make([]byte, 100))
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := c.stats()
+	if stats.Bytes > maxBytes {
+		t.Errorf("cache holds %d bytes, want at most %d", stats.Bytes, maxBytes)
+	}
+	if stats.Bytes != int64(stats.Entries)*100 || len(c.order) != stats.Entries {
+		t.Errorf("size %d, %d entries and %d ordered keys disagree", stats.Bytes, stats.Entries, len(c.order))
+	}
+	if stats.Hits+stats.Misses != 8*200 {
+		t.Errorf("hits + misses = %d, want %d", stats.Hits+stats.Misses, 8*200)
+	}
+	if stats.Evictions == 0 {
+		t.Error("no evictions counted, want some")
+	}
+}
+
+func BenchmarkReadPackageJSONParallel(b *testing.B) {
+	m := newTestManager(b, nil)
+	ext := storeVSIX(b, m, testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"})
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := m.ReadPackageJSON(ext); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package extensions
+
+import (
+	"littlevsx/internal/database"
+	"littlevsx/internal/models"
+)
+
+// indexQueueSize is how many prepared extensions may wait for the indexer
+// before Add blocks, and indexBatchSize how many are stored per transaction.
+const (
+	indexQueueSize = 64
+	indexBatchSize = 32
+)
+
+// Indexer stores extensions in the database from a single goroutine, so
+// downloads and asset processing running in other goroutines never wait for
+// each other on database locks. Extensions queued while a batch is written
+// are stored together in the next transaction.
+type Indexer struct {
+	db    *database.Database
+	queue chan indexJob
+	done  chan struct{}
+}
+
+type indexJob struct {
+	ext    *models.Extension
+	stored func(error)
+}
+
+// StartIndexer starts the indexer of the manager. Close it to wait until
+// every queued extension is stored.
+func (m *Manager) StartIndexer() *Indexer {
+	ix := &Indexer{
+		db:    m.db,
+		queue: make(chan indexJob, indexQueueSize),
+		done:  make(chan struct{}),
+	}
+	go ix.run()
+	return ix
+}
+
+// Add queues ext to be stored and blocks while the queue is full. stored is
+// called from the indexer goroutine with the result once ext is stored or
+// failed to be.
+func (ix *Indexer) Add(ext *models.Extension, stored func(error)) {
+	ix.queue <- indexJob{ext: ext, stored: stored}
+}
+
+// Close stops accepting extensions and waits until the queued ones are
+// stored.
+func (ix *Indexer) Close() {
+	close(ix.queue)
+	<-ix.done
+}
+
+func (ix *Indexer) run() {
+	defer close(ix.done)
+
+	for job := range ix.queue {
+		batch := []indexJob{job}
+	fill:
+		for len(batch) < indexBatchSize {
+			select {
+			case next, ok := <-ix.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+		ix.store(batch)
+	}
+}
+
+// store writes batch in one transaction. If that fails, the extensions are
+// stored one by one, so a single bad extension does not fail the others.
+func (ix *Indexer) store(batch []indexJob) {
+	exts := make([]*database.ExtensionDB, len(batch))
+	for i, job := range batch {
+		exts[i] = database.ToDBExtension(job.ext)
+	}
+
+	if err := ix.db.UpsertExtensions(exts); err == nil {
+		for _, job := range batch {
+			job.stored(nil)
+		}
+		return
+	}
+
+	for i, job := range batch {
+		err := ix.db.UpsertExtension(exts[i])
+		job.stored(err)
+	}
+}
@@ -0,0 +1,67 @@
+package extensions
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"littlevsx/internal/models"
+)
+
+// TestIndexerStoresConcurrentAdds queues extensions from several goroutines,
+// as download --from-file does, and checks that each is stored and
+// reported exactly once.
+func TestIndexerStoresConcurrentAdds(t *testing.T) {
+	tests := []struct {
+		name      string
+		producers int
+		perWorker int
+	}{
+		{"single producer", 1, 5},
+		{"more than a batch", 4, indexBatchSize},
+		{"more than the queue holds", 8, indexQueueSize/4 + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, nil)
+			ix := m.StartIndexer()
+
+			// calls is only written by the indexer goroutine and read after
+			// Close.
+			calls := make(map[string]int)
+			var wg sync.WaitGroup
+			for p := range tt.producers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range tt.perWorker {
+						id := fmt.Sprintf("acme.tool%d-%d", p, i)
+						ext := &models.Extension{ID: id, Name: id, Publisher: "acme", Version: "1.0.0", TargetPlatform: "universal"}
+						ix.Add(ext, func(err error) {
+							if err != nil {
+								t.Errorf("storing %s: %v", id, err)
+							}
+							calls[id]++
+						})
+					}
+				}()
+			}
+			wg.Wait()
+			ix.Close()
+
+			want := tt.producers * tt.perWorker
+			if len(calls) != want {
+				t.Errorf("%d extensions reported, want %d", len(calls), want)
+			}
+			for id, n := range calls {
+				if n != 1 {
+					t.Errorf("%s reported %d times", id, n)
+				}
+			}
+			if total, err := m.db.CountExtensions(); err != nil || total != int64(want) {
+				t.Errorf("CountExtensions = %d, %v, want %d", total, err, want)
+			}
+		})
+	}
+}
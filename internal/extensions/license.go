@@ -0,0 +1,55 @@
+package extensions
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+)
+
+// licenseTexts holds the texts of common licenses, named by SPDX identifier.
+// The permissive ones contain a {holder} placeholder for the copyright line.
+//
+//go:embed licenses/*.txt
+var licenseTexts embed.FS
+
+// spdxAliases maps the SPDX identifiers accepted in package.json, in lower
+// case, to the file in licenseTexts.
+var spdxAliases = map[string]string{
+	"mit":               "MIT",
+	"isc":               "ISC",
+	"0bsd":              "0BSD",
+	"bsd-2-clause":      "BSD-2-Clause",
+	"bsd-3-clause":      "BSD-3-Clause",
+	"unlicense":         "Unlicense",
+	"apache-2.0":        "Apache-2.0",
+	"mpl-2.0":           "MPL-2.0",
+	"gpl-2.0":           "GPL-2.0",
+	"gpl-2.0-only":      "GPL-2.0",
+	"gpl-2.0-or-later":  "GPL-2.0",
+	"gpl-3.0":           "GPL-3.0",
+	"gpl-3.0-only":      "GPL-3.0",
+	"gpl-3.0-or-later":  "GPL-3.0",
+	"lgpl-3.0":          "LGPL-3.0",
+	"lgpl-3.0-only":     "LGPL-3.0",
+	"lgpl-3.0-or-later": "LGPL-3.0",
+}
+
+// generateLicense builds a license document for extensions that declare an
+// SPDX identifier in package.json but do not package a LICENSE file.
+func generateLicense(spdxID, holder string) ([]byte, error) {
+	id := strings.TrimSpace(spdxID)
+	name, ok := spdxAliases[strings.ToLower(id)]
+	if !ok {
+		return nil, fmt.Errorf("no bundled license text for %q", spdxID)
+	}
+
+	text, err := licenseTexts.ReadFile("licenses/" + name + ".txt")
+	if err != nil {
+		return nil, err
+	}
+
+	var doc strings.Builder
+	fmt.Fprintf(&doc, "<!-- Generated from the SPDX identifier %q declared in package.json. -->\n\n", id)
+	doc.WriteString(strings.ReplaceAll(string(text), "{holder}", holder))
+	return []byte(doc.String()), nil
+}
@@ -0,0 +1,63 @@
+package extensions
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+// TestLicense checks that the license command and the license asset serve
+// the same document: the packaged LICENSE, or one generated from the SPDX
+// identifier in package.json.
+func TestLicense(t *testing.T) {
+	tests := []struct {
+		name     string
+		license  string
+		files    map[string][]byte
+		want     string
+		wantNone bool
+	}{
+		{"packaged LICENSE.md", "MIT", map[string][]byte{"extension/LICENSE.md": []byte("Custom terms")}, "Custom terms", false},
+		{"packaged LICENSE.txt", "", map[string][]byte{"extension/LICENSE.txt": []byte("Text terms")}, "Text terms", false},
+		{"generated from SPDX identifier", "mit", nil, "Copyright (c) acme", false},
+		{"unknown identifier", "SEE LICENSE IN terms.txt", nil, "", true},
+		{"no license", "", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, nil)
+			ext := storeVSIX(t, m, testutil.VSIX{
+				Publisher: "acme", Name: "tool", Version: "1.0.0",
+				PackageJSON: map[string]interface{}{"license": tt.license},
+				Files:       tt.files,
+			})
+
+			read, readErr := m.ReadLicense(ext)
+			rc, size, openErr := m.OpenLicense(ext)
+			if tt.wantNone {
+				if readErr == nil || openErr == nil {
+					t.Errorf("ReadLicense = %q, %v and OpenLicense error %v, want errors", read, readErr, openErr)
+				}
+				return
+			}
+			if readErr != nil || openErr != nil {
+				t.Fatalf("ReadLicense: %v, OpenLicense: %v", readErr, openErr)
+			}
+			opened, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(string(read), tt.want) {
+				t.Errorf("ReadLicense = %q, want it to contain %q", read, tt.want)
+			}
+			if string(opened) != string(read) || size != int64(len(read)) {
+				t.Errorf("OpenLicense = %q (%d bytes), ReadLicense = %q", opened, size, read)
+			}
+		})
+	}
+}
@@ -2,26 +2,64 @@ package extensions
 
 import (
 	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"littlevsx/internal/config"
 	"littlevsx/internal/database"
 	"littlevsx/internal/models"
+	"littlevsx/internal/storage"
+	"littlevsx/internal/utils"
 )
 
 const (
 	packageJSONPath    = "extension/package.json"
 	packageNLSPath     = "extension/package.nls.json"
+	vsixManifestPath   = "extension.vsixmanifest"
 	maxExtensionsLimit = 10000
 	maxSearchLimit     = 1000
-	maxQueryLimit      = 100
+	universalPlatform  = "universal"
+	preReleaseProperty = "Microsoft.VisualStudio.Code.PreRelease"
 )
 
+// ErrInvalidVSIX is wrapped by the errors of ReadExtensionInfo and
+// ValidateVSIX for files that are not a readable .vsix package, e.g.
+// truncated downloads or archives without extension/package.json.
+var ErrInvalidVSIX = errors.New("not a valid .vsix package")
+
+// invalidVSIX wraps err in ErrInvalidVSIX.
+func invalidVSIX(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalidVSIX, err)
+}
+
+var licensePaths = []string{
+	"extension/LICENSE.md",
+	"extension/LICENSE",
+	"extension/LICENSE.txt",
+	"extension/license.md",
+	"extension/license",
+	"extension/license.txt",
+}
+
+var changelogPaths = []string{
+	"extension/CHANGELOG.md",
+	"extension/CHANGELOG",
+	"extension/changelog.md",
+	"extension/changelog",
+}
+
 var readmePaths = []string{
 	"extension/README.md",
 	"extension/readme.md",
@@ -35,51 +73,164 @@ var readmePaths = []string{
 
 type Manager struct {
 	directory string
+	assetsDir string
 	db        *database.Database
+	storage   storage.Storage
+
+	// defaultRating, defaultRatingCount and defaultDownloads are the
+	// statistics.* values new extensions start with.
+	defaultRating      float64
+	defaultRatingCount int64
+	defaultDownloads   int64
+
+	// searchLimit caps the results of Search (search.max_results).
+	searchLimit int
+
+	// defaultPlatform is the target platform assumed for clients that send
+	// none (extensions.default_target_platform).
+	defaultPlatform string
+
+	// extractCache keeps files read from packages (cache.extract_max_mb).
+	extractCache *extractCache
+
+	hashCacheMu sync.Mutex
+	hashCache   map[string]string
 }
 
 func New() (*Manager, error) {
 	config := config.GetConfig()
+	store, err := storage.New(config)
+	if err != nil {
+		return nil, err
+	}
 	db, err := database.New()
 	if err != nil {
 		return nil, err
 	}
+	searchLimit := config.SearchMaxResults
+	if searchLimit <= 0 {
+		searchLimit = maxSearchLimit
+	}
 	return &Manager{
-		directory: config.ExtensionsDir,
-		db:        db,
+		directory:    config.ExtensionsDir,
+		assetsDir:    config.AssetsDir,
+		db:           db,
+		storage:      store,
+		extractCache: newExtractCache(int64(config.ExtractCacheMaxMB) << 20),
+		hashCache:    make(map[string]string),
+
+		defaultRating:      config.DefaultRating,
+		defaultRatingCount: int64(config.DefaultRatingCount),
+		defaultDownloads:   int64(config.DefaultDownloads),
+
+		searchLimit:     searchLimit,
+		defaultPlatform: config.DefaultTargetPlatform,
 	}, nil
 }
 
 func (m *Manager) ReadExtensionInfo(filePath string) (*models.Extension, error) {
 	reader, err := zip.OpenReader(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open .vsix file: %w", err)
+		return nil, invalidVSIX(err)
 	}
 	defer reader.Close()
 
 	packageJSON, err := m.readPackageJSON(reader)
 	if err != nil {
-		return nil, err
+		return nil, invalidVSIX(err)
 	}
 
 	pkg, err := m.parsePackageJSON(packageJSON)
 	if err != nil {
-		return nil, err
+		return nil, invalidVSIX(err)
 	}
 
 	m.processLocalization(reader, pkg)
 
+	manifest := m.readVSIXManifest(reader)
+
+	if pkg.Publisher == "" {
+		if manifest != nil {
+			pkg.Publisher = strings.TrimSpace(manifest.Metadata.Identity.Publisher)
+		}
+		if pkg.Publisher == "" {
+			return nil, fmt.Errorf("publisher is missing in both package.json and %s of %s", vsixManifestPath, filePath)
+		}
+	}
+
+	targetPlatform := universalPlatform
+	if manifest != nil && manifest.Metadata.Identity.TargetPlatform != "" {
+		targetPlatform = manifest.Metadata.Identity.TargetPlatform
+	}
+
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	return m.createExtension(pkg, filePath, fileInfo), nil
+	ext := m.createExtension(pkg, filePath, fileInfo)
+	ext.TargetPlatform = targetPlatform
+	ext.PreRelease = utils.IsPreReleaseVersion(pkg.Version) ||
+		(manifest != nil && manifest.property(preReleaseProperty) == "true")
+	return ext, nil
+}
+
+// ValidateVSIX checks that a .vsix has what the gallery needs to serve it:
+// every file in the archive can be read and none is a symlink, package.json
+// names a version, and the extension.vsixmanifest, when present, agrees
+// with it. Unreadable archives and a missing or unparsable package.json
+// wrap ErrInvalidVSIX.
+func (m *Manager) ValidateVSIX(filePath string) error {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return invalidVSIX(err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("archive entry %s is a symlink", file.Name)
+		}
+		if !storage.IsRegular(file) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return invalidVSIX(fmt.Errorf("failed to open %s: %w", file.Name, err))
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return invalidVSIX(fmt.Errorf("corrupt archive entry %s: %w", file.Name, err))
+		}
+	}
+
+	packageJSON, err := m.readPackageJSON(reader)
+	if err != nil {
+		return invalidVSIX(err)
+	}
+	pkg, err := m.parsePackageJSON(packageJSON)
+	if err != nil {
+		return invalidVSIX(err)
+	}
+	if pkg.Name == "" {
+		return fmt.Errorf("name is missing in package.json")
+	}
+	if pkg.Version == "" {
+		return fmt.Errorf("version is missing in package.json")
+	}
+
+	if manifest := m.readVSIXManifest(reader); manifest != nil {
+		if version := manifest.Metadata.Identity.Version; version != "" && version != pkg.Version {
+			return fmt.Errorf("%s version %s does not match package.json version %s", vsixManifestPath, version, pkg.Version)
+		}
+	}
+	return nil
 }
 
 func (m *Manager) readPackageJSON(reader *zip.ReadCloser) ([]byte, error) {
 	for _, file := range reader.File {
-		if file.Name == packageJSONPath {
+		if file.Name == packageJSONPath && storage.IsRegular(file) {
 			rc, err := file.Open()
 			if err != nil {
 				return nil, fmt.Errorf("failed to open package.json: %w", err)
@@ -113,6 +264,55 @@ type packageInfo struct {
 	Homepage    string         `json:"homepage"`
 	Bugs        interface{}    `json:"bugs"`
 	License     string         `json:"license"`
+
+	ExtensionDependencies []string `json:"extensionDependencies"`
+	ExtensionPack         []string `json:"extensionPack"`
+}
+
+type vsixManifest struct {
+	Metadata struct {
+		Identity struct {
+			ID             string `xml:"Id,attr"`
+			Version        string `xml:"Version,attr"`
+			Publisher      string `xml:"Publisher,attr"`
+			TargetPlatform string `xml:"TargetPlatform,attr"`
+		} `xml:"Identity"`
+		Properties struct {
+			Property []struct {
+				ID    string `xml:"Id,attr"`
+				Value string `xml:"Value,attr"`
+			} `xml:"Property"`
+		} `xml:"Properties"`
+	} `xml:"Metadata"`
+}
+
+func (vm *vsixManifest) property(id string) string {
+	for _, property := range vm.Metadata.Properties.Property {
+		if property.ID == id {
+			return property.Value
+		}
+	}
+	return ""
+}
+
+// readVSIXManifest parses the archive's vsixmanifest (see findVSIXManifest),
+// returning nil when it is missing or malformed.
+func (m *Manager) readVSIXManifest(reader *zip.ReadCloser) *vsixManifest {
+	file := findVSIXManifest(reader.File)
+	if file == nil {
+		return nil
+	}
+
+	content := m.readFileContent(file)
+	if content == "" {
+		return nil
+	}
+
+	var manifest vsixManifest
+	if err := xml.Unmarshal([]byte(content), &manifest); err != nil {
+		return nil
+	}
+	return &manifest
 }
 
 func (m *Manager) processLocalization(reader *zip.ReadCloser, pkg *packageInfo) {
@@ -130,7 +330,7 @@ func (m *Manager) processLocalization(reader *zip.ReadCloser, pkg *packageInfo)
 
 func (m *Manager) readNLSData(reader *zip.ReadCloser) map[string]string {
 	for _, file := range reader.File {
-		if file.Name == packageNLSPath {
+		if file.Name == packageNLSPath && storage.IsRegular(file) {
 			rc, err := file.Open()
 			if err != nil {
 				continue
@@ -193,19 +393,21 @@ func (m *Manager) createExtension(pkg *packageInfo, filePath string, fileInfo os
 		FileSize:         fileInfo.Size(),
 		LastUpdated:      fileInfo.ModTime(),
 		FilePath:         filePath,
-		Verified:         true,
-		AverageRating:    5.0,
-		ReviewCount:      100,
-		DownloadCount:    1000,
+		Verified:         false,
+		AverageRating:    m.defaultRating,
+		ReviewCount:      m.defaultRatingCount,
+		DownloadCount:    m.defaultDownloads,
 		Namespace:        pkg.Publisher,
-		ExtensionID:      extID,
+		ExtensionID:      utils.ExtensionUUID("", extID),
 		ShortDescription: pkg.Description,
 		PublishedDate:    fileInfo.ModTime(),
 		ReleaseDate:      fileInfo.ModTime(),
 		PreRelease:       false,
 		Deprecated:       false,
-		TargetPlatform:   "universal",
+		TargetPlatform:   universalPlatform,
 		ReadmeContent:    m.readReadmeFromVSIX(filePath),
+		Dependencies:     pkg.ExtensionDependencies,
+		ExtensionPack:    pkg.ExtensionPack,
 	}
 }
 
@@ -241,25 +443,123 @@ func (m *Manager) GetAll() []*models.Extension {
 	return database.ToExtensionSlice(extensions)
 }
 
+// GetPage returns one page of extensions in browse order together with the
+// total number of extensions.
+func (m *Manager) GetPage(page, size int) ([]*models.Extension, int64) {
+	extensions, total, err := m.db.GetAllExtensions(page, size)
+	if err != nil {
+		return []*models.Extension{}, 0
+	}
+	return database.ToExtensionSlice(extensions), total
+}
+
+// GetByID returns the latest version of an extension.
 func (m *Manager) GetByID(id string) (*models.Extension, bool) {
 	dbExt, err := m.db.GetExtensionByID(id)
-	if err != nil {
+	if err != nil || dbExt == nil {
 		return nil, false
 	}
 	return database.ToExtension(dbExt), true
 }
 
-func (m *Manager) Search(query string) []*models.Extension {
-	extensions, _, err := m.db.SearchExtensions(query, 1, maxSearchLimit)
+// GetByIDFold is GetByID with id matched case-insensitively, as VS Code
+// compares extension IDs. An exact match wins.
+func (m *Manager) GetByIDFold(id string) (*models.Extension, bool) {
+	if ext, ok := m.GetByID(id); ok {
+		return ext, true
+	}
+	stored, err := m.db.FindExtensionID(id)
+	if err != nil || stored == "" {
+		return nil, false
+	}
+	return m.GetByID(stored)
+}
+
+// GetVersion returns a specific version of an extension, its universal
+// build if there is one.
+func (m *Manager) GetVersion(id, version string) (*models.Extension, bool) {
+	dbExt, err := m.db.GetExtensionByIDAndVersion(id, version)
+	if err != nil || dbExt == nil {
+		return nil, false
+	}
+	return database.ToExtension(dbExt), true
+}
+
+// GetBuild returns the build of a version of an extension for
+// targetPlatform, without falling back to other builds.
+func (m *Manager) GetBuild(id, version, targetPlatform string) (*models.Extension, bool) {
+	dbExt, err := m.db.GetExtensionBuild(id, version, targetPlatform)
+	if err != nil || dbExt == nil {
+		return nil, false
+	}
+	return database.ToExtension(dbExt), true
+}
+
+// GetBuilds returns the builds of a version of an extension, the universal
+// build first.
+func (m *Manager) GetBuilds(id, version string) []*models.Extension {
+	builds, err := m.db.GetExtensionBuilds(id, version)
 	if err != nil {
 		return []*models.Extension{}
 	}
-	return database.ToExtensionSlice(extensions)
+	return database.ToExtensionSlice(builds)
+}
+
+// GetVersions returns every stored build of an extension, newest version
+// first.
+func (m *Manager) GetVersions(id string) []*models.Extension {
+	versions, err := m.db.GetExtensionVersions(id)
+	if err != nil {
+		return []*models.Extension{}
+	}
+	return database.ToExtensionSlice(versions)
+}
+
+// GetByExtensionID returns the extension with the given marketplace UUID.
+func (m *Manager) GetByExtensionID(extensionID string) (*models.Extension, bool) {
+	if extensionID == "" {
+		return nil, false
+	}
+	dbExt, err := m.db.GetExtensionByExtensionID(extensionID)
+	if err != nil || dbExt == nil {
+		return nil, false
+	}
+	return database.ToExtension(dbExt), true
+}
+
+// MissingDependencies returns the declared dependencies and extension pack
+// members of ext that are not in the catalog, so clients installing ext from
+// the mirror would fail to resolve them.
+func (m *Manager) MissingDependencies(ext *models.Extension) ([]string, error) {
+	var missing []string
+	for _, list := range [][]string{ext.Dependencies, ext.ExtensionPack} {
+		for _, id := range list {
+			exists, err := m.db.ExtensionExists(id)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				missing = append(missing, id)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// Search returns at most search.max_results extensions matching query,
+// together with the number of matches in the catalog. A total larger than
+// the number of results means the results were truncated.
+func (m *Manager) Search(query string) ([]*models.Extension, int64) {
+	extensions, total, err := m.db.SearchExtensions(query, 1, m.searchLimit)
+	if err != nil {
+		return []*models.Extension{}, 0
+	}
+	return database.ToExtensionSlice(extensions), total
 }
 
 func (m *Manager) GetFile(id string) (string, bool) {
 	dbExt, err := m.db.GetExtensionByID(id)
-	if err != nil {
+	if err != nil || dbExt == nil {
 		return "", false
 	}
 	return dbExt.FilePath, true
@@ -272,11 +572,72 @@ func (m *Manager) GetStats() map[string]interface{} {
 			"total_extensions": 0,
 			"publishers":       map[string]int64{},
 			"categories":       map[string]int64{},
+			"tags":             map[string]int64{},
 		}
 	}
 	return stats
 }
 
+// RecordInstall counts a download of ext by the user sending userID in
+// X-Market-User-Id. Only a hash of the ID is stored.
+func (m *Manager) RecordInstall(ext *models.Extension, userID string) error {
+	sum := sha256.Sum256([]byte("littlevsx-user:" + userID))
+	return m.db.RecordInstall(ext.ID, hex.EncodeToString(sum[:]))
+}
+
+// IncrementDownloadCount counts a download of ext.
+func (m *Manager) IncrementDownloadCount(ext *models.Extension) error {
+	return m.db.IncrementDownloadCount(ext.ID)
+}
+
+// GetUniqueInstalls returns the number of distinct users that downloaded
+// each extension, as recorded by RecordInstall.
+func (m *Manager) GetUniqueInstalls() (map[string]int64, error) {
+	return m.db.GetUniqueInstalls()
+}
+
+// Count returns the number of extensions in the database.
+func (m *Manager) Count() int64 {
+	count, err := m.db.CountExtensions()
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// GetByTag returns the extensions tagged with tag, ignoring case.
+func (m *Manager) GetByTag(tag string) []*models.Extension {
+	extensions, _ := m.GetPageByTag(tag, 1, maxSearchLimit)
+	return extensions
+}
+
+// GetByCategory returns the extensions in category, ignoring case.
+func (m *Manager) GetByCategory(category string) []*models.Extension {
+	extensions, _, err := m.db.GetExtensionsByCategory(category, 1, maxSearchLimit)
+	if err != nil {
+		return []*models.Extension{}
+	}
+	return database.ToExtensionSlice(extensions)
+}
+
+// GetPageByTag returns one page of the extensions tagged with tag together
+// with the total number of them.
+func (m *Manager) GetPageByTag(tag string, page, size int) ([]*models.Extension, int64) {
+	extensions, total, err := m.db.GetExtensionsByTag(tag, page, size)
+	if err != nil {
+		return []*models.Extension{}, 0
+	}
+	return database.ToExtensionSlice(extensions), total
+}
+
+func (m *Manager) GetFingerprint() string {
+	fingerprint, err := m.db.GetFingerprint()
+	if err != nil {
+		return ""
+	}
+	return fingerprint
+}
+
 func (m *Manager) GetByNamespace(namespace string) []*models.Extension {
 	extensions, _, err := m.db.GetExtensionsByPublisher(namespace, 1, maxSearchLimit)
 	if err != nil {
@@ -312,74 +673,73 @@ func (m *Manager) GetVersionReferences(namespace, name string) []models.VersionR
 	}
 }
 
-func (m *Manager) QueryExtensions(params map[string]string) models.QueryResult {
-	all := m.GetAll()
-	filtered := m.filterExtensions(all, params)
-	offset, size := m.getPaginationParams(params)
-	paged := m.applyPagination(filtered, offset, size)
-	return models.QueryResult{
-		Offset:     offset,
-		TotalSize:  len(filtered),
-		Extensions: m.toExtensionSlice(paged),
-	}
-}
-
-func (m *Manager) filterExtensions(exts []*models.Extension, params map[string]string) []*models.Extension {
-	var filtered []*models.Extension
-	for _, ext := range exts {
-		if m.matchesQuery(ext, params) {
-			filtered = append(filtered, ext)
-		}
+// GetForPlatform returns the build of the latest version of an extension
+// to serve to a client running on targetPlatform, see selectForPlatform.
+// Clients that send no platform are treated as running on
+// extensions.default_target_platform.
+func (m *Manager) GetForPlatform(id, targetPlatform string) (*models.Extension, bool) {
+	latest, ok := m.GetByID(id)
+	if !ok {
+		return nil, false
 	}
-	return filtered
+	return m.GetVersionForPlatform(id, latest.Version, targetPlatform)
 }
 
-func (m *Manager) getPaginationParams(params map[string]string) (int, int) {
-	offset, size := 0, maxQueryLimit
-	fmt.Sscanf(params["offset"], "%d", &offset)
-	fmt.Sscanf(params["size"], "%d", &size)
-	if size > maxQueryLimit {
-		size = maxQueryLimit
+// GetVersionForPlatform is GetForPlatform for a specific version.
+func (m *Manager) GetVersionForPlatform(id, version, targetPlatform string) (*models.Extension, bool) {
+	if targetPlatform == "" {
+		targetPlatform = m.defaultPlatform
 	}
-	return offset, size
+	selected := selectForPlatform(m.GetBuilds(id, version), targetPlatform)
+	return selected, selected != nil
 }
 
-func (m *Manager) applyPagination(exts []*models.Extension, offset, size int) []*models.Extension {
-	if offset >= len(exts) {
-		return []*models.Extension{}
+// selectForPlatform picks the build for a client on targetPlatform among the
+// builds of one extension version: the platform-specific build if there is
+// one, otherwise the universal build. Without a target platform the universal
+// build is preferred, falling back to the first candidate.
+func selectForPlatform(candidates []*models.Extension, targetPlatform string) *models.Extension {
+	var universal *models.Extension
+	for _, ext := range candidates {
+		if targetPlatform != "" && ext.TargetPlatform == targetPlatform {
+			return ext
+		}
+		if universal == nil && (ext.TargetPlatform == universalPlatform || ext.TargetPlatform == "") {
+			universal = ext
+		}
 	}
-	end := offset + size
-	if end > len(exts) {
-		end = len(exts)
+	if universal == nil && targetPlatform == "" && len(candidates) > 0 {
+		return candidates[0]
 	}
-	return exts[offset:end]
+	return universal
 }
 
-func (m *Manager) matchesQuery(ext *models.Extension, params map[string]string) bool {
-	if val := params["namespaceName"]; val != "" && ext.Namespace != val {
-		return false
-	}
-	if val := params["extensionName"]; val != "" && ext.Name != val {
-		return false
-	}
-	if val := params["extensionVersion"]; val != "" && ext.Version != val {
-		return false
-	}
-	if val := params["extensionId"]; val != "" && ext.ExtensionID != val {
-		return false
-	}
-	if val := params["targetPlatform"]; val != "" && ext.TargetPlatform != val && ext.TargetPlatform != "universal" {
-		return false
-	}
-	return true
-}
+// ListVSIXFiles returns the paths of all .vsix files in dir, descending into
+// subdirectories when recursive is set.
+func (m *Manager) ListVSIXFiles(dir string, recursive bool) ([]string, error) {
+	fileUtils := utils.NewFileUtils()
+	var files []string
 
-func (m *Manager) toExtensionSlice(extensions []*models.Extension) []models.Extension {
-	result := make([]models.Extension, len(extensions))
-	for i, ext := range extensions {
-		result[i] = *ext
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fileUtils.IsVSIXFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return result
+
+	return files, nil
 }
 
 func (m *Manager) GetExtensionsDir() string {
@@ -398,6 +758,9 @@ func (m *Manager) readReadmeFromVSIX(filePath string) string {
 	defer reader.Close()
 
 	for _, file := range reader.File {
+		if !storage.IsRegular(file) {
+			continue
+		}
 		for _, path := range readmePaths {
 			if file.Name == path || m.isReadmeFile(file.Name) {
 				return m.readFileContent(file)
@@ -429,6 +792,235 @@ func (m *Manager) readFileContent(file *zip.File) string {
 	return string(content)
 }
 
+// ReadReadme returns the stored (asset-processed) README of the extension,
+// falling back to the one packaged in the .vsix file.
+func (m *Manager) ReadReadme(ext *models.Extension) ([]byte, error) {
+	if ext.ReadmeContent != "" {
+		return []byte(ext.ReadmeContent), nil
+	}
+	return m.extractFirst(ext.FilePath, readmePaths)
+}
+
+// ReadLicense returns the license OpenLicense serves, for the license
+// command.
+func (m *Manager) ReadLicense(ext *models.Extension) ([]byte, error) {
+	rc, _, err := m.OpenLicense(ext)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// OpenLicense opens the LICENSE packaged in the extension's .vsix file for
+// streaming and returns its size. Without one, a license generated from a
+// recognized SPDX identifier in package.json is returned instead.
+func (m *Manager) OpenLicense(ext *models.Extension) (io.ReadCloser, int64, error) {
+	for _, path := range licensePaths {
+		if rc, size, err := storage.OpenEntry(m.storage, ext.FilePath, path); err == nil {
+			return rc, size, nil
+		}
+	}
+	if generated, err := generateLicense(ext.License, ext.Publisher); err == nil {
+		return io.NopCloser(bytes.NewReader(generated)), int64(len(generated)), nil
+	}
+	return nil, 0, fmt.Errorf("none of %s found in .vsix archive", strings.Join(licensePaths, ", "))
+}
+
+func (m *Manager) ReadChangelog(ext *models.Extension) ([]byte, error) {
+	return m.extractFirst(ext.FilePath, changelogPaths)
+}
+
+// ReadPackageJSON returns the pristine package.json of the extension.
+func (m *Manager) ReadPackageJSON(ext *models.Extension) ([]byte, error) {
+	return m.readCached(ext, packageJSONPath)
+}
+
+// readCached extracts a file from the extension's .vsix package. Results are
+// cached per .vsix file and modification time since packages are immutable.
+func (m *Manager) readCached(ext *models.Extension, entry string) ([]byte, error) {
+	key, err := m.vsixCacheKey(ext.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := key + "#" + entry
+
+	if cached, ok := m.extractCache.get(cacheKey); ok {
+		return cached, nil
+	}
+
+	rc, _, err := m.OpenVSIXEntry(ext, entry)
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", entry, err)
+	}
+
+	m.extractCache.put(cacheKey, content)
+
+	return content, nil
+}
+
+// ExtractCacheStats returns the counters of the cache of files extracted
+// from packages.
+func (m *Manager) ExtractCacheStats() ExtractCacheStats {
+	return m.extractCache.stats()
+}
+
+// AssetETag returns a strong ETag for a file inside the extension's .vsix
+// package, derived from the SHA-256 of the package and the file's path. The
+// package hash is cached like extracted files, so answering a conditional
+// request never opens the archive.
+func (m *Manager) AssetETag(ext *models.Extension, entry string) (string, error) {
+	key, err := m.vsixCacheKey(ext.FilePath)
+	if err != nil {
+		return "", err
+	}
+
+	m.hashCacheMu.Lock()
+	fileHash, ok := m.hashCache[key]
+	m.hashCacheMu.Unlock()
+
+	if !ok {
+		if fileHash, err = m.hashFile(ext.FilePath); err != nil {
+			return "", err
+		}
+		m.hashCacheMu.Lock()
+		m.hashCache[key] = fileHash
+		m.hashCacheMu.Unlock()
+	}
+
+	return fmt.Sprintf("\"%x\"", sha256.Sum256([]byte(fileHash+":"+entry))), nil
+}
+
+// PackageSHA256 returns the SHA-256 checksum of the extension's .vsix
+// package as currently stored.
+func (m *Manager) PackageSHA256(ext *models.Extension) (string, error) {
+	return m.hashFile(ext.FilePath)
+}
+
+func (m *Manager) vsixCacheKey(filePath string) (string, error) {
+	fileInfo, err := m.storage.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	return fmt.Sprintf("%s@%d", filePath, fileInfo.ModTime().UnixNano()), nil
+}
+
+func (m *Manager) hashFile(filePath string) (string, error) {
+	file, err := m.storage.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// BuildManifest merges the extension's package.json with the gallery metadata
+// stored for it under the "__metadata" key, the same layout VS Code uses for
+// installed extensions. Without a readable package.json the manifest is built
+// from the stored fields alone.
+func (m *Manager) BuildManifest(ext *models.Extension) ([]byte, error) {
+	manifest := make(map[string]interface{})
+
+	if packageJSON, err := m.ReadPackageJSON(ext); err == nil {
+		if err := json.Unmarshal(packageJSON, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse package.json: %w", err)
+		}
+	} else {
+		manifest = map[string]interface{}{
+			"name":        ext.Name,
+			"displayName": ext.DisplayName,
+			"description": ext.Description,
+			"version":     ext.Version,
+			"publisher":   ext.Publisher,
+			"engines":     ext.Engines,
+			"categories":  ext.Categories,
+			"keywords":    ext.Tags,
+			"icon":        ext.Icon,
+			"repository":  ext.Repository,
+			"homepage":    ext.Homepage,
+			"bugs":        ext.Bugs,
+			"license":     ext.License,
+
+			"extensionDependencies": ext.Dependencies,
+			"extensionPack":         ext.ExtensionPack,
+		}
+	}
+
+	// The display name set with "littlevsx publisher set" wins, as in
+	// gallery responses.
+	publisherDisplayName := ext.Publisher
+	if pub, ok := m.GetPublisher(ext.Publisher); ok && pub.DisplayName != "" {
+		publisherDisplayName = pub.DisplayName
+	}
+
+	manifest["__metadata"] = map[string]interface{}{
+		"id":                   utils.ExtensionUUID(ext.ExtensionID, ext.ID),
+		"publisherId":          utils.PublisherUUID(ext.Publisher),
+		"publisherDisplayName": publisherDisplayName,
+		"targetPlatform":       ext.TargetPlatform,
+		"isPreReleaseVersion":  ext.PreRelease,
+		"installCount":         ext.DownloadCount,
+		"averageRating":        ext.AverageRating,
+		"ratingCount":          ext.ReviewCount,
+		"lastUpdated":          ext.LastUpdated,
+	}
+
+	return json.Marshal(manifest)
+}
+
+// ListVSIXEntries returns the files contained in the extension's .vsix package.
+func (m *Manager) ListVSIXEntries(ext *models.Extension) ([]models.ArchiveEntry, error) {
+	reader, err := storage.OpenArchive(m.storage, ext.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	entries := make([]models.ArchiveEntry, 0, len(reader.File))
+	for _, file := range reader.File {
+		entries = append(entries, models.ArchiveEntry{
+			Name:           file.Name,
+			Size:           file.UncompressedSize64,
+			CompressedSize: file.CompressedSize64,
+		})
+	}
+	return entries, nil
+}
+
+// OpenVSIXEntry opens a file inside the extension's .vsix package for
+// streaming and returns its uncompressed size. Files extracted by Prefetch
+// are read from disk instead.
+func (m *Manager) OpenVSIXEntry(ext *models.Extension, entry string) (io.ReadCloser, int64, error) {
+	if rc, size, ok := m.openPrefetched(ext, entry); ok {
+		return rc, size, nil
+	}
+	return storage.OpenEntry(m.storage, ext.FilePath, entry)
+}
+
+// Storage returns the storage the .vsix packages are served from.
+func (m *Manager) Storage() storage.Storage {
+	return m.storage
+}
+
+func (m *Manager) extractFirst(vsixPath string, paths []string) ([]byte, error) {
+	for _, path := range paths {
+		if content, err := storage.ReadEntry(m.storage, vsixPath, path); err == nil {
+			return content, nil
+		}
+	}
+	return nil, fmt.Errorf("none of %s found in .vsix archive", strings.Join(paths, ", "))
+}
+
 func (m *Manager) Close() error {
 	if m.db != nil {
 		return m.db.Close()
@@ -436,17 +1028,21 @@ func (m *Manager) Close() error {
 	return nil
 }
 
+// DeleteExtension removes every version of an extension together with its
+// .vsix files and assets.
 func (m *Manager) DeleteExtension(id string) error {
-	ext, ok := m.GetByID(id)
-	if !ok {
+	versions := m.GetVersions(id)
+	if len(versions) == 0 {
 		return fmt.Errorf("extension with ID %s not found", id)
 	}
 
-	if err := m.deleteVSIXFile(ext.FilePath); err != nil {
-		return fmt.Errorf("failed to delete .vsix file: %w", err)
+	for _, ext := range versions {
+		if err := m.deleteVSIXFile(ext.FilePath); err != nil {
+			return fmt.Errorf("failed to delete .vsix file: %w", err)
+		}
 	}
 
-	if err := m.deleteAssetsFolder(ext.ID); err != nil {
+	if err := m.deleteAssetsFolder(id); err != nil {
 		return fmt.Errorf("failed to delete asset folder: %w", err)
 	}
 
@@ -457,6 +1053,100 @@ func (m *Manager) DeleteExtension(id string) error {
 	return nil
 }
 
+// SetFeatured marks or unmarks an extension as featured.
+func (m *Manager) SetFeatured(id string, featured bool) error {
+	found, err := m.db.SetFeatured(id, featured)
+	if err != nil {
+		return fmt.Errorf("failed to update extension: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("extension with ID %s not found", id)
+	}
+	return nil
+}
+
+// SetPinned pins or unpins an extension. update, clean and reindex leave
+// pinned extensions alone.
+func (m *Manager) SetPinned(id string, pinned bool) error {
+	found, err := m.db.SetPinned(id, pinned)
+	if err != nil {
+		return fmt.Errorf("failed to update extension: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("extension with ID %s not found", id)
+	}
+	return nil
+}
+
+// SetRating sets the rating shown for an extension; a count of zero clears it.
+func (m *Manager) SetRating(id string, average float64, count int64) error {
+	found, err := m.db.SetRating(id, average, count)
+	if err != nil {
+		return fmt.Errorf("failed to update extension: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("extension with ID %s not found", id)
+	}
+	return nil
+}
+
+// ClearRatings removes the rating of every extension and returns how many
+// had one.
+func (m *Manager) ClearRatings() (int64, error) {
+	count, err := m.db.ClearRatings()
+	if err != nil {
+		return 0, fmt.Errorf("failed to update extensions: %w", err)
+	}
+	return count, nil
+}
+
+// SetPublisher creates or replaces the record of a publisher.
+func (m *Manager) SetPublisher(pub *models.Publisher) error {
+	if err := m.db.UpsertPublisher(&database.PublisherDB{
+		Name:        pub.Name,
+		DisplayName: pub.DisplayName,
+		Domain:      pub.Domain,
+		Verified:    pub.Verified,
+		UpdatedAt:   time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to save publisher: %w", err)
+	}
+	return nil
+}
+
+// GetPublisher returns the record of a publisher, if one has been set.
+func (m *Manager) GetPublisher(name string) (*models.Publisher, bool) {
+	dbPub, err := m.db.GetPublisher(name)
+	if err != nil || dbPub == nil {
+		return nil, false
+	}
+	return database.ToPublisher(dbPub), true
+}
+
+// GetPublishers returns all publisher records keyed by lower-case name.
+func (m *Manager) GetPublishers() map[string]*models.Publisher {
+	publishers := make(map[string]*models.Publisher)
+	dbPubs, err := m.db.GetAllPublishers()
+	if err != nil {
+		return publishers
+	}
+	for i := range dbPubs {
+		publishers[strings.ToLower(dbPubs[i].Name)] = database.ToPublisher(&dbPubs[i])
+	}
+	return publishers
+}
+
+func (m *Manager) DeletePublisher(name string) error {
+	found, err := m.db.DeletePublisher(name)
+	if err != nil {
+		return fmt.Errorf("failed to delete publisher: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("publisher %s not found", name)
+	}
+	return nil
+}
+
 func (m *Manager) deleteVSIXFile(path string) error {
 	if path == "" {
 		return nil
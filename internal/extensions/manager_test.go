@@ -0,0 +1,126 @@
+package extensions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"littlevsx/internal/database"
+	"littlevsx/internal/models"
+	"littlevsx/internal/testutil"
+)
+
+// newTestManager returns a Manager on a temporary database and extensions
+// directory. overrides sets further configuration keys.
+func newTestManager(t testing.TB, overrides map[string]interface{}) *Manager {
+	t.Helper()
+	testutil.TempConfig(t, overrides)
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	if err := os.MkdirAll(m.directory, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// storeVSIX writes v into the extensions directory and adds it to the
+// database, as download does.
+func storeVSIX(t testing.TB, m *Manager, v testutil.VSIX) *models.Extension {
+	t.Helper()
+	path := filepath.Join(m.directory, v.FileName())
+	if err := v.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	ext, err := m.ReadExtensionInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.db.UpsertExtension(database.ToDBExtension(ext)); err != nil {
+		t.Fatal(err)
+	}
+	return ext
+}
+
+// TestReadExtensionInfoPublisher covers package.json files without a
+// publisher, as in locally built packages, which fall back to the
+// vsixmanifest Identity.
+func TestReadExtensionInfoPublisher(t *testing.T) {
+	tests := []struct {
+		name    string
+		vsix    testutil.VSIX
+		wantID  string
+		wantErr bool
+	}{
+		{
+			name:   "package.json publisher",
+			vsix:   testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"},
+			wantID: "acme.tool",
+		},
+		{
+			name: "package.json publisher wins over the manifest",
+			vsix: testutil.VSIX{
+				Publisher: "manifest", Name: "tool", Version: "1.0.0",
+				PackageJSON: map[string]interface{}{"publisher": "acme"},
+			},
+			wantID: "acme.tool",
+		},
+		{
+			name: "manifest publisher",
+			vsix: testutil.VSIX{
+				Publisher: "acme", Name: "tool", Version: "1.0.0",
+				PackageJSON: map[string]interface{}{"publisher": ""},
+			},
+			wantID: "acme.tool",
+		},
+		{
+			name: "manifest publisher is trimmed",
+			vsix: testutil.VSIX{
+				Publisher: " acme ", Name: "tool", Version: "1.0.0",
+				PackageJSON: map[string]interface{}{"publisher": ""},
+			},
+			wantID: "acme.tool",
+		},
+		{
+			name:    "no publisher anywhere",
+			vsix:    testutil.VSIX{Name: "tool", Version: "1.0.0"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, nil)
+			ext, err := m.ReadExtensionInfo(tt.vsix.WriteTemp(t))
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "publisher is missing") {
+					t.Errorf("ReadExtensionInfo = %v, %v, want a missing publisher error", ext, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ext.ID != tt.wantID || ext.Publisher+"."+ext.Name != tt.wantID {
+				t.Errorf("ID = %q (publisher %q), want %q", ext.ID, ext.Publisher, tt.wantID)
+			}
+		})
+	}
+}
+
+// TestReadExtensionInfoNotVerified checks that imported packages are not
+// marked verified; verification comes from the publisher records.
+func TestReadExtensionInfoNotVerified(t *testing.T) {
+	m := newTestManager(t, nil)
+	vsix := testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"}
+	ext, err := m.ReadExtensionInfo(vsix.WriteTemp(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.Verified {
+		t.Error("Verified = true, want false")
+	}
+}
@@ -0,0 +1,94 @@
+package extensions
+
+import (
+	"testing"
+
+	"littlevsx/internal/models"
+	"littlevsx/internal/testutil"
+)
+
+func TestSelectForPlatform(t *testing.T) {
+	// want is the index of the selected build in builds, -1 for none.
+	tests := []struct {
+		name     string
+		builds   []string
+		platform string
+		want     int
+	}{
+		{"exact build over universal", []string{"universal", "linux-x64"}, "linux-x64", 1},
+		{"exact build listed first", []string{"linux-x64", "universal"}, "linux-x64", 0},
+		{"universal fallback", []string{"win32-x64", "universal"}, "linux-x64", 1},
+		{"no matching build", []string{"win32-x64", "darwin-arm64"}, "linux-x64", -1},
+		{"no platform prefers universal", []string{"linux-x64", "universal"}, "", 1},
+		{"no platform without universal", []string{"linux-x64", "win32-x64"}, "", 0},
+		{"universal only", []string{"linux-x64"}, "universal", -1},
+		{"empty platform is universal", []string{""}, "linux-x64", 0},
+		{"no builds", nil, "linux-x64", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := make([]*models.Extension, len(tt.builds))
+			for i, platform := range tt.builds {
+				candidates[i] = &models.Extension{ID: "acme.tool", Version: "1.0.0", TargetPlatform: platform}
+			}
+
+			got := selectForPlatform(candidates, tt.platform)
+			var want *models.Extension
+			if tt.want >= 0 {
+				want = candidates[tt.want]
+			}
+			if got != want {
+				t.Errorf("selectForPlatform(%q, %q) = %v, want %v", tt.builds, tt.platform, got, want)
+			}
+		})
+	}
+}
+
+// TestGetVersionForPlatform stores several builds of one version and checks
+// which one each client gets, with and without extensions.default_target_platform.
+func TestGetVersionForPlatform(t *testing.T) {
+	tests := []struct {
+		name            string
+		builds          []string
+		defaultPlatform string
+		platform        string
+		want            string
+	}{
+		{"platform build preferred", []string{"universal", "linux-x64", "win32-x64"}, "", "linux-x64", "linux-x64"},
+		{"universal fallback", []string{"universal", "win32-x64"}, "", "linux-x64", "universal"},
+		{"missing platform", []string{"win32-x64"}, "", "linux-x64", ""},
+		{"no platform sent", []string{"linux-x64", "universal"}, "", "", "universal"},
+		{"default platform", []string{"universal", "linux-x64"}, "linux-x64", "", "linux-x64"},
+		{"client platform beats default", []string{"universal", "linux-x64", "win32-x64"}, "linux-x64", "win32-x64", "win32-x64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, map[string]interface{}{"extensions.default_target_platform": tt.defaultPlatform})
+			base := testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"}
+			for _, v := range base.ForPlatforms(tt.builds...) {
+				storeVSIX(t, m, v)
+			}
+
+			for name, get := range map[string]func() (*models.Extension, bool){
+				"GetVersionForPlatform": func() (*models.Extension, bool) {
+					return m.GetVersionForPlatform("acme.tool", "1.0.0", tt.platform)
+				},
+				"GetForPlatform": func() (*models.Extension, bool) {
+					return m.GetForPlatform("acme.tool", tt.platform)
+				},
+			} {
+				ext, ok := get()
+				switch {
+				case tt.want == "" && ok:
+					t.Errorf("%s(%q) = %s, want none", name, tt.platform, ext.TargetPlatform)
+				case tt.want != "" && !ok:
+					t.Errorf("%s(%q) found nothing, want %s", name, tt.platform, tt.want)
+				case ok && ext.TargetPlatform != tt.want:
+					t.Errorf("%s(%q) = %s, want %s", name, tt.platform, ext.TargetPlatform, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package extensions
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"littlevsx/internal/models"
+	"littlevsx/internal/storage"
+)
+
+// prefetchDirName is the subdirectory of an extension's assets directory
+// that Prefetch extracts into. prune-assets only looks at the files directly
+// in the assets directory, so it leaves these alone.
+const prefetchDirName = "_extracted"
+
+// prefetchDir returns where files of the extension's package are extracted.
+// It includes version and platform, so a new build never sees stale files.
+func (m *Manager) prefetchDir(ext *models.Extension) string {
+	return filepath.Join(m.assetsDir, ext.ID, prefetchDirName, ext.Version+"@"+ext.TargetPlatform)
+}
+
+// Prefetch extracts the files clients request first, the package.json,
+// vsixmanifest and icon, next to the extension's README assets, so serving
+// them does not open the archive. It returns the number of files written.
+func (m *Manager) Prefetch(ext *models.Extension) (int, error) {
+	archive, err := storage.OpenArchive(m.storage, ext.FilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+
+	wanted := map[string]bool{packageJSONPath: true}
+	if manifest := findVSIXManifest(archive.File); manifest != nil {
+		wanted[manifest.Name] = true
+	}
+	if ext.Icon != "" {
+		wanted["extension/"+ext.Icon] = true
+	}
+
+	dir := m.prefetchDir(ext)
+	written := 0
+	for _, file := range archive.File {
+		if !wanted[file.Name] || !storage.IsRegular(file) || !filepath.IsLocal(file.Name) {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(file.Name))
+		if err := extractFile(file.Open, target); err != nil {
+			return written, fmt.Errorf("failed to extract %s: %w", file.Name, err)
+		}
+		written++
+	}
+	return written, nil
+}
+
+// extractFile writes the content returned by open to target through a
+// temporary file, so the server never reads a partial file.
+func extractFile(open func() (io.ReadCloser, error), target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".prefetch-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// openPrefetched opens a file extracted by Prefetch. Files older than the
+// package are ignored, since the package was replaced after prefetching.
+func (m *Manager) openPrefetched(ext *models.Extension, entry string) (io.ReadCloser, int64, bool) {
+	if !filepath.IsLocal(entry) {
+		return nil, 0, false
+	}
+
+	file, err := os.Open(filepath.Join(m.prefetchDir(ext), filepath.FromSlash(entry)))
+	if err != nil {
+		return nil, 0, false
+	}
+
+	info, err := file.Stat()
+	if err == nil && info.Mode().IsRegular() {
+		if pkg, err := m.storage.Stat(ext.FilePath); err == nil && !info.ModTime().Before(pkg.ModTime()) {
+			return file, info.Size(), true
+		}
+	}
+	file.Close()
+	return nil, 0, false
+}
@@ -0,0 +1,191 @@
+package extensions
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"littlevsx/internal/models"
+	"littlevsx/internal/storage"
+)
+
+// findVSIXManifest returns the package's vsixmanifest. vsce writes it to
+// extension.vsixmanifest at the archive root, but some older or hand-made
+// packages use another case, another name or a subdirectory, so any
+// *.vsixmanifest is accepted, preferring the shallowest one.
+func findVSIXManifest(files []*zip.File) *zip.File {
+	var found *zip.File
+	for _, file := range files {
+		if !storage.IsRegular(file) || !strings.HasSuffix(strings.ToLower(file.Name), ".vsixmanifest") {
+			continue
+		}
+		if file.Name == vsixManifestPath {
+			return file
+		}
+		if found == nil || strings.Count(file.Name, "/") < strings.Count(found.Name, "/") {
+			found = file
+		}
+	}
+	return found
+}
+
+// OpenVSIXManifest opens the vsixmanifest packaged in the extension's .vsix
+// file for streaming and returns its size, see findVSIXManifest.
+func (m *Manager) OpenVSIXManifest(ext *models.Extension) (io.ReadCloser, int64, error) {
+	archive, err := storage.OpenArchive(m.storage, ext.FilePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	file := findVSIXManifest(archive.File)
+	archive.Close()
+
+	if file == nil {
+		return nil, 0, fmt.Errorf("no .vsixmanifest found in .vsix archive")
+	}
+	return storage.OpenEntry(m.storage, ext.FilePath, file.Name)
+}
+
+type manifestXML struct {
+	XMLName      xml.Name            `xml:"PackageManifest"`
+	Version      string              `xml:"Version,attr"`
+	Namespace    string              `xml:"xmlns,attr"`
+	Metadata     manifestMetadataXML `xml:"Metadata"`
+	Installation struct {
+		InstallationTarget struct {
+			ID string `xml:"Id,attr"`
+		} `xml:"InstallationTarget"`
+	} `xml:"Installation"`
+	Dependencies struct{} `xml:"Dependencies"`
+	Assets       struct {
+		Asset []manifestAssetXML `xml:"Asset"`
+	} `xml:"Assets"`
+}
+
+type manifestMetadataXML struct {
+	Identity struct {
+		Language       string `xml:"Language,attr"`
+		ID             string `xml:"Id,attr"`
+		Version        string `xml:"Version,attr"`
+		Publisher      string `xml:"Publisher,attr"`
+		TargetPlatform string `xml:"TargetPlatform,attr,omitempty"`
+	} `xml:"Identity"`
+	DisplayName  string `xml:"DisplayName"`
+	Description  string `xml:"Description"`
+	Tags         string `xml:"Tags"`
+	Categories   string `xml:"Categories"`
+	GalleryFlags string `xml:"GalleryFlags"`
+	Properties   struct {
+		Property []manifestPropertyXML `xml:"Property"`
+	} `xml:"Properties"`
+	License string `xml:"License,omitempty"`
+	Icon    string `xml:"Icon,omitempty"`
+}
+
+type manifestPropertyXML struct {
+	ID    string `xml:"Id,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+type manifestAssetXML struct {
+	Type        string `xml:"Type,attr"`
+	Path        string `xml:"Path,attr"`
+	Addressable bool   `xml:"Addressable,attr"`
+}
+
+// BuildVSIXManifest generates a vsixmanifest in the layout vsce writes, for
+// packages without one. Assets list the README, CHANGELOG, LICENSE and icon
+// found in the package; when the package cannot be read, only package.json
+// and the stored icon are listed.
+func (m *Manager) BuildVSIXManifest(ext *models.Extension) ([]byte, error) {
+	var manifest manifestXML
+	manifest.Version = "2.0.0"
+	manifest.Namespace = "http://schemas.microsoft.com/developer/vsx-schema/2011"
+	manifest.Installation.InstallationTarget.ID = "Microsoft.VisualStudio.Code"
+
+	metadata := &manifest.Metadata
+	metadata.Identity.Language = "en-US"
+	metadata.Identity.ID = ext.Name
+	metadata.Identity.Version = ext.Version
+	metadata.Identity.Publisher = ext.Publisher
+	if ext.TargetPlatform != universalPlatform {
+		metadata.Identity.TargetPlatform = ext.TargetPlatform
+	}
+	metadata.DisplayName = ext.DisplayName
+	metadata.Description = ext.Description
+	metadata.Tags = strings.Join(ext.Tags, ",")
+	metadata.Categories = strings.Join(ext.Categories, ",")
+	metadata.GalleryFlags = "Public"
+
+	properties := []manifestPropertyXML{
+		{"Microsoft.VisualStudio.Code.Engine", ext.Engines.VSCode},
+		{"Microsoft.VisualStudio.Code.ExtensionDependencies", strings.Join(ext.Dependencies, ",")},
+		{"Microsoft.VisualStudio.Code.ExtensionPack", strings.Join(ext.ExtensionPack, ",")},
+		{"Microsoft.VisualStudio.Code.LocalizedLanguages", ""},
+	}
+	if ext.PreRelease {
+		properties = append(properties, manifestPropertyXML{preReleaseProperty, "true"})
+	}
+	for _, link := range []struct{ id, value string }{
+		{"Microsoft.VisualStudio.Services.Links.Source", ext.Repository},
+		{"Microsoft.VisualStudio.Services.Links.Support", ext.Bugs},
+		{"Microsoft.VisualStudio.Services.Links.Learn", ext.Homepage},
+	} {
+		if link.value != "" {
+			properties = append(properties, manifestPropertyXML{link.id, link.value})
+		}
+	}
+	properties = append(properties, manifestPropertyXML{"Microsoft.VisualStudio.Services.GitHubFlavoredMarkdown", "true"})
+	metadata.Properties.Property = properties
+
+	assets := []manifestAssetXML{{"Microsoft.VisualStudio.Code.Manifest", packageJSONPath, true}}
+	icon := ""
+	if ext.Icon != "" {
+		icon = "extension/" + ext.Icon
+	}
+
+	if archive, err := storage.OpenArchive(m.storage, ext.FilePath); err == nil {
+		packaged := make(map[string]bool, len(archive.File))
+		for _, file := range archive.File {
+			if storage.IsRegular(file) {
+				packaged[file.Name] = true
+			}
+		}
+		archive.Close()
+
+		for _, asset := range []struct {
+			assetType string
+			paths     []string
+		}{
+			{"Microsoft.VisualStudio.Services.Content.Details", readmePaths},
+			{"Microsoft.VisualStudio.Services.Content.Changelog", changelogPaths},
+			{"Microsoft.VisualStudio.Services.Content.License", licensePaths},
+		} {
+			for _, path := range asset.paths {
+				if packaged[path] {
+					assets = append(assets, manifestAssetXML{asset.assetType, path, true})
+					if asset.assetType == "Microsoft.VisualStudio.Services.Content.License" {
+						metadata.License = path
+					}
+					break
+				}
+			}
+		}
+		if !packaged[icon] {
+			icon = ""
+		}
+	}
+
+	if icon != "" {
+		metadata.Icon = icon
+		assets = append(assets, manifestAssetXML{"Microsoft.VisualStudio.Services.Icons.Default", icon, true})
+	}
+	manifest.Assets.Asset = assets
+
+	body, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build vsixmanifest: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
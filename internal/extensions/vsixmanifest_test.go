@@ -0,0 +1,183 @@
+package extensions
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+// manifestShape lists the element paths of an XML document in document
+// order and the attributes seen on each, ignoring namespace declarations.
+type manifestShape struct {
+	paths      []string
+	attributes map[string][]string
+}
+
+func parseManifestShape(t *testing.T, content []byte) manifestShape {
+	t.Helper()
+	shape := manifestShape{attributes: make(map[string][]string)}
+	var stack []string
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return shape
+		}
+		if err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, content)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, token.Name.Local)
+			path := strings.Join(stack, "/")
+			if !slices.Contains(shape.paths, path) {
+				shape.paths = append(shape.paths, path)
+			}
+			for _, attr := range token.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" || attr.Name.Space == "xml" {
+					continue
+				}
+				if !slices.Contains(shape.attributes[path], attr.Name.Local) {
+					shape.attributes[path] = append(shape.attributes[path], attr.Name.Local)
+				}
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// TestBuildVSIXManifestSchema compares a generated manifest with one written
+// by vsce (testdata/vsce.vsixmanifest): every element and attribute must
+// exist there, in the same order since the schema uses sequences, and the
+// elements the schema requires must be present.
+func TestBuildVSIXManifestSchema(t *testing.T) {
+	reference, err := os.ReadFile("testdata/vsce.vsixmanifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parseManifestShape(t, reference)
+
+	m := newTestManager(t, nil)
+	ext := storeVSIX(t, m, testutil.VSIX{
+		Publisher: "acme", Name: "tool", Version: "1.0.0", TargetPlatform: "linux-x64", PreRelease: true,
+		DisplayName: "Tool", Description: "Lints <b> & formats",
+		Readme: "# Tool", Icon: testutil.PNG,
+		PackageJSON: map[string]interface{}{
+			"categories": []string{"Linters", "Formatters"},
+			"keywords":   []string{"lint", "format"},
+			"repository": "https://github.com/acme/tool.git",
+		},
+		Files: map[string][]byte{
+			"extension/CHANGELOG.md": []byte("## 1.0.0"),
+			"extension/LICENSE.txt":  []byte("MIT"),
+		},
+	})
+
+	content, err := m.BuildVSIXManifest(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := parseManifestShape(t, content)
+
+	next := 0
+	for _, path := range got.paths {
+		i := slices.Index(want.paths[next:], path)
+		if i < 0 {
+			t.Errorf("element %s is not in the vsce layout or out of order", path)
+			continue
+		}
+		next += i + 1
+		for _, attr := range got.attributes[path] {
+			if !slices.Contains(want.attributes[path], attr) {
+				t.Errorf("attribute %s on %s is not in the vsce layout", attr, path)
+			}
+		}
+	}
+
+	required := map[string][]string{
+		"PackageManifest":                                 {"Version"},
+		"PackageManifest/Metadata/Identity":               {"Language", "Id", "Version", "Publisher", "TargetPlatform"},
+		"PackageManifest/Metadata/DisplayName":            nil,
+		"PackageManifest/Metadata/Description":            nil,
+		"PackageManifest/Metadata/Properties/Property":    {"Id", "Value"},
+		"PackageManifest/Metadata/License":                nil,
+		"PackageManifest/Metadata/Icon":                   nil,
+		"PackageManifest/Installation/InstallationTarget": {"Id"},
+		"PackageManifest/Dependencies":                    nil,
+		"PackageManifest/Assets/Asset":                    {"Type", "Path", "Addressable"},
+	}
+	for path, attrs := range required {
+		if !slices.Contains(got.paths, path) {
+			t.Errorf("required element %s is missing", path)
+		}
+		for _, attr := range attrs {
+			if !slices.Contains(got.attributes[path], attr) {
+				t.Errorf("required attribute %s on %s is missing", attr, path)
+			}
+		}
+	}
+
+	var manifest manifestXML
+	if err := xml.Unmarshal(content, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Metadata.Description != "Lints <b> & formats" {
+		t.Errorf("Description = %q, want the escaped original", manifest.Metadata.Description)
+	}
+	wantAssets := []manifestAssetXML{
+		{"Microsoft.VisualStudio.Code.Manifest", "extension/package.json", true},
+		{"Microsoft.VisualStudio.Services.Content.Details", "extension/README.md", true},
+		{"Microsoft.VisualStudio.Services.Content.Changelog", "extension/CHANGELOG.md", true},
+		{"Microsoft.VisualStudio.Services.Content.License", "extension/LICENSE.txt", true},
+		{"Microsoft.VisualStudio.Services.Icons.Default", "extension/icon.png", true},
+	}
+	if !slices.Equal(manifest.Assets.Asset, wantAssets) {
+		t.Errorf("Assets = %v, want %v", manifest.Assets.Asset, wantAssets)
+	}
+}
+
+func TestFindVSIXManifest(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"archive root", []string{"extension/package.json", "extension.vsixmanifest"}, "extension.vsixmanifest"},
+		{"exact name preferred", []string{"Extension.VsixManifest", "extension.vsixmanifest"}, "extension.vsixmanifest"},
+		{"other case", []string{"Extension.VsixManifest"}, "Extension.VsixManifest"},
+		{"shallowest", []string{"a/b/tool.vsixmanifest", "a/tool.vsixmanifest"}, "a/tool.vsixmanifest"},
+		{"none", []string{"extension/package.json"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tool.vsix")
+			entries := make([]zipEntry, 0, len(tt.files))
+			for _, name := range tt.files {
+				entries = append(entries, zipEntry{name: name, mode: 0644, content: "<PackageManifest/>"})
+			}
+			writeZip(t, path, entries)
+
+			archive, err := zip.OpenReader(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer archive.Close()
+			got := ""
+			if file := findVSIXManifest(archive.File); file != nil {
+				got = file.Name
+			}
+			if got != tt.want {
+				t.Errorf("findVSIXManifest(%q) = %q, want %q", tt.files, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package marketplace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// downloads deduplicates concurrent downloads of the same package within the
+// process, so simultaneous requests for one extension share a single fetch.
+var downloads downloadGroup
+
+type downloadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*downloadCall
+}
+
+type downloadCall struct {
+	done   chan struct{}
+	result *DownloadResult
+	err    error
+}
+
+// do runs fn once for all callers passing the same key while it is running.
+func (g *downloadGroup) do(key string, fn func() (*DownloadResult, error)) (*DownloadResult, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*downloadCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.result, call.err
+	}
+
+	call := &downloadCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.result, call.err = fn()
+	close(call.done)
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return call.result, call.err
+}
+
+// downloadExtension stores the package in targetDir unless it is already
+// there. Concurrent calls for the same package share one download.
+func downloadExtension(client *http.Client, info *ExtensionInfo, targetDir string) (*DownloadResult, error) {
+	filePath := filepath.Join(targetDir, vsixFileName(info))
+
+	return downloads.do(filePath, func() (*DownloadResult, error) {
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		if _, err := os.Stat(filePath); err == nil {
+			return &DownloadResult{FilePath: filePath, WasDownloaded: false}, nil
+		}
+
+		checksum, err := downloadFile(client, info.DownloadURL, info.SHA256URL, filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return &DownloadResult{FilePath: filePath, WasDownloaded: true, SHA256: checksum}, nil
+	})
+}
+
+// downloadFile writes the package to filePath+".part", which is renamed into
+// place once complete, so other processes never see a partially written
+// package. A ".part" file left by an interrupted download is resumed with a
+// Range request; when the server answers with the whole package instead,
+// the download starts over. It returns the SHA-256 of the package; when
+// sha256URL is set, a package not matching the checksum published there is
+// discarded.
+func downloadFile(client *http.Client, downloadURL, sha256URL, filePath string) (string, error) {
+	expected := ""
+	if sha256URL != "" {
+		var err error
+		if expected, err = fetchChecksum(client, sha256URL); err != nil {
+			return "", err
+		}
+	}
+
+	partPath := filePath + ".part"
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			file.Close()
+		}
+	}()
+
+	// Hashing the partial file also moves the offset to its end.
+	hash := sha256.New()
+	offset, err := io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read partial download: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("request error: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		fmt.Printf("Resuming download at %d bytes\n", offset)
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			if err := restartPart(file); err != nil {
+				return "", err
+			}
+			hash.Reset()
+			offset = 0
+		}
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is at least as long as the package, so it is not
+		// a prefix of it. Start over without a range.
+		file.Close()
+		closed = true
+		if err := os.Remove(partPath); err != nil {
+			return "", fmt.Errorf("failed to remove partial download: %w", err)
+		}
+		resp.Body.Close()
+		return downloadFile(client, downloadURL, sha256URL, filePath)
+	case resp.StatusCode == http.StatusPartialContent:
+		// A range other than the one asked for; the next attempt starts
+		// over rather than failing the same way.
+		file.Close()
+		closed = true
+		os.Remove(partPath)
+		return "", fmt.Errorf("unexpected Content-Range %q when resuming at %d bytes", resp.Header.Get("Content-Range"), offset)
+	default:
+		return "", fmt.Errorf("invalid status code: %d", resp.StatusCode)
+	}
+
+	written, err := io.Copy(io.MultiWriter(file, hash), resp.Body)
+	closeErr := file.Close()
+	closed = true
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write file (%d bytes kept in %s to resume from): %w", offset+written, partPath, err)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if expected != "" && checksum != expected {
+		os.Remove(partPath)
+		return "", fmt.Errorf("%w: got %s, marketplace published %s", ErrChecksumMismatch, checksum, expected)
+	}
+
+	if err := os.Chmod(partPath, 0644); err != nil {
+		return "", fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	if err := os.Rename(partPath, filePath); err != nil {
+		return "", fmt.Errorf("failed to move file into place: %w", err)
+	}
+
+	fmt.Printf("Downloaded: %s (%d bytes)\n", filePath, offset+written)
+	return checksum, nil
+}
+
+// restartPart empties a partial download the server would not resume.
+func restartPart(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to restart download: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to restart download: %w", err)
+	}
+	return nil
+}
+
+// fetchChecksum reads a published SHA-256 checksum. The file holds the hex
+// digest, optionally followed by the file name as sha256sum writes it.
+func fetchChecksum(client *http.Client, sha256URL string) (string, error) {
+	resp, err := client.Get(sha256URL)
+	if err != nil {
+		return "", fmt.Errorf("checksum request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("invalid status code for checksum: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksum: %w", err)
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum at %s", sha256URL)
+	}
+	checksum := strings.ToLower(fields[0])
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid checksum at %s: %q", sha256URL, fields[0])
+	}
+	return checksum, nil
+}
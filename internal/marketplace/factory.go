@@ -2,6 +2,7 @@ package marketplace
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Factory creates marketplace providers based on type
@@ -23,3 +24,36 @@ func (f *Factory) CreateByType(marketplaceType MarketplaceType) (MarketplaceProv
 		return nil, fmt.Errorf("unknown marketplace type: %s", marketplaceType)
 	}
 }
+
+// Resolved is a marketplace that knows a requested extension.
+type Resolved struct {
+	Type     MarketplaceType
+	Provider MarketplaceProvider
+	Info     *ExtensionInfo
+}
+
+// Resolve looks the extension up in each marketplace of order and returns
+// the first one that has it.
+func (f *Factory) Resolve(order []MarketplaceType, extensionID, targetPlatform string) (*Resolved, error) {
+	if len(order) == 0 {
+		return nil, fmt.Errorf("no marketplaces configured to try")
+	}
+
+	var failures []string
+	for _, marketplaceType := range order {
+		provider, err := f.CreateByType(marketplaceType)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := provider.GetExtensionInfoByID(extensionID, targetPlatform)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", marketplaceType, err))
+			continue
+		}
+
+		return &Resolved{Type: marketplaceType, Provider: provider, Info: info}, nil
+	}
+
+	return nil, fmt.Errorf("extension %s not found in any marketplace (%s)", extensionID, strings.Join(failures, "; "))
+}
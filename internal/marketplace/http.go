@@ -0,0 +1,144 @@
+package marketplace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"littlevsx/internal/utils"
+)
+
+// Errors for marketplace entries that cannot be downloaded. They are wrapped
+// with the extension and platform concerned; test for them with errors.Is.
+var (
+	// ErrNoVersion means the marketplace lists the extension without any
+	// published version.
+	ErrNoVersion = errors.New("no version published")
+
+	// ErrNoDownloadURL means the selected version has no package to download.
+	ErrNoDownloadURL = errors.New("download URL not found")
+
+	// ErrChecksumMismatch means the downloaded package does not match the
+	// checksum the marketplace published for it.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// checkJSONResponse returns a descriptive error when the marketplace answered
+// with something other than JSON, typically an HTML page served when the
+// client is rate-limited or blocked.
+func checkJSONResponse(resp *http.Response, body []byte) error {
+	contentType := resp.Header.Get("Content-Type")
+	trimmed := bytes.TrimSpace(body)
+
+	looksLikeHTML := strings.Contains(contentType, "html") ||
+		(len(trimmed) > 0 && trimmed[0] == '<')
+
+	if !looksLikeHTML {
+		if contentType == "" || strings.Contains(contentType, "json") {
+			return nil
+		}
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("upstream returned non-JSON response (likely rate-limited/blocked): status %d, content-type %q",
+		resp.StatusCode, contentType)
+}
+
+// selectPlatformIndex picks the entry matching the requested target platform
+// from a newest-first list, treating an empty request as universal. It
+// returns -1 when the platform is not available, so that a platform-specific
+// extension is never downloaded for the wrong platform.
+func selectPlatformIndex(platforms []string, targetPlatform string) int {
+	wanted := normalizePlatform(targetPlatform)
+	for i, platform := range platforms {
+		if normalizePlatform(platform) == wanted {
+			return i
+		}
+	}
+	return -1
+}
+
+// platformNotFoundError reports that the extension has no build for the
+// requested platform and lists the platforms it does have.
+func platformNotFoundError(extensionID, targetPlatform string, available []string) error {
+	seen := make(map[string]bool)
+	var platforms []string
+	for _, platform := range available {
+		platform = normalizePlatform(platform)
+		if !seen[platform] {
+			seen[platform] = true
+			platforms = append(platforms, platform)
+		}
+	}
+	sort.Strings(platforms)
+
+	if targetPlatform == "" {
+		return fmt.Errorf("%s has no universal build, a target platform is required (available: %s)",
+			extensionID, strings.Join(platforms, ", "))
+	}
+	return fmt.Errorf("no version of %s found for target platform %s (available: %s)",
+		extensionID, targetPlatform, strings.Join(platforms, ", "))
+}
+
+func normalizePlatform(platform string) string {
+	if platform == "" {
+		return UniversalPlatform
+	}
+	return platform
+}
+
+// vsixFileName returns the file name a downloaded package is stored under,
+// suffixed with the target platform for platform-specific builds.
+func vsixFileName(info *ExtensionInfo) string {
+	if info.TargetPlatform != "" && info.TargetPlatform != UniversalPlatform {
+		return fmt.Sprintf("%s-%s@%s.vsix", info.Name, info.Version, info.TargetPlatform)
+	}
+	return fmt.Sprintf("%s-%s.vsix", info.Name, info.Version)
+}
+
+// versionCollector groups per-platform package entries into VersionInfo
+// values, one per version.
+type versionCollector struct {
+	versions []VersionInfo
+	index    map[string]int
+}
+
+func (c *versionCollector) add(version, platform string, published time.Time, downloadURL string) {
+	if c.index == nil {
+		c.index = make(map[string]int)
+	}
+
+	i, ok := c.index[version]
+	if !ok {
+		i = len(c.versions)
+		c.index[version] = i
+		c.versions = append(c.versions, VersionInfo{
+			Version:      version,
+			DownloadURLs: make(map[string]string),
+		})
+	}
+
+	info := &c.versions[i]
+	platform = normalizePlatform(platform)
+	if _, seen := info.DownloadURLs[platform]; !seen {
+		info.TargetPlatforms = append(info.TargetPlatforms, platform)
+	}
+	info.DownloadURLs[platform] = downloadURL
+	if !published.IsZero() && (info.PublishedDate.IsZero() || published.Before(info.PublishedDate)) {
+		info.PublishedDate = published
+	}
+}
+
+// list returns the collected versions, newest first.
+func (c *versionCollector) list() []VersionInfo {
+	sort.SliceStable(c.versions, func(i, j int) bool {
+		return utils.CompareVersions(c.versions[i].Version, c.versions[j].Version) > 0
+	})
+	return c.versions
+}
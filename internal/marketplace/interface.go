@@ -1,11 +1,17 @@
 package marketplace
 
+import "context"
+
 // MarketplaceProvider defines the interface for different marketplace implementations
 type MarketplaceProvider interface {
 	GetExtensionInfo(marketplaceURL string) (*ExtensionInfo, error)
-	GetExtensionInfoByID(extensionID string) (*ExtensionInfo, error)
+	GetExtensionInfoByID(extensionID, targetPlatform string) (*ExtensionInfo, error)
+	GetVersions(extensionID string) ([]VersionInfo, error)
 	DownloadExtension(info *ExtensionInfo, targetDir string) (*DownloadResult, error)
 	GetName() string
+
+	// Ping sends a lightweight request to check the marketplace is reachable.
+	Ping(ctx context.Context) error
 }
 
 // MarketplaceType represents the type of marketplace
@@ -14,4 +20,10 @@ type MarketplaceType string
 const (
 	MarketplaceTypeMicrosoft MarketplaceType = "microsoft"
 	MarketplaceTypeOpenVSX   MarketplaceType = "open-vsx"
+
+	// MarketplaceTypeAuto tries several marketplaces in turn, see Factory.Resolve.
+	MarketplaceTypeAuto MarketplaceType = "auto"
 )
+
+// UniversalPlatform is the target platform of extensions that run anywhere.
+const UniversalPlatform = "universal"
@@ -1,13 +1,12 @@
 package marketplace
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -15,20 +14,55 @@ import (
 
 // ExtensionInfo represents extension information from any marketplace
 type ExtensionInfo struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	DisplayName string `json:"displayName"`
-	Description string `json:"description"`
-	Version     string `json:"version"`
-	Publisher   string `json:"publisher"`
-	DownloadURL string `json:"downloadUrl"`
-	FileSize    int64  `json:"fileSize"`
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	DisplayName    string `json:"displayName"`
+	Description    string `json:"description"`
+	Version        string `json:"version"`
+	Publisher      string `json:"publisher"`
+	DownloadURL    string `json:"downloadUrl"`
+	FileSize       int64  `json:"fileSize"`
+	TargetPlatform string `json:"targetPlatform"`
+
+	// SHA256URL points at the checksum the marketplace publishes for the
+	// package, if any; downloads must match it.
+	SHA256URL string `json:"sha256Url,omitempty"`
+
+	// Statistics are the install and rating numbers the marketplace reports
+	// for the extension, or nil when it reports none.
+	Statistics *Statistics `json:"statistics,omitempty"`
+}
+
+// FullName returns the "publisher.name" ID the extension is stored under.
+// ID is the gallery UUID for the Visual Studio Marketplace.
+func (i *ExtensionInfo) FullName() string {
+	return i.Publisher + "." + i.Name
+}
+
+// Statistics are the install and rating numbers of an extension in a
+// marketplace. RatingCount is 0 for unrated extensions.
+type Statistics struct {
+	Installs      int64   `json:"installs"`
+	AverageRating float64 `json:"averageRating"`
+	RatingCount   int64   `json:"ratingCount"`
+}
+
+// VersionInfo describes one published version of an extension across all
+// target platforms it was built for
+type VersionInfo struct {
+	Version         string            `json:"version"`
+	TargetPlatforms []string          `json:"targetPlatforms"`
+	PublishedDate   time.Time         `json:"publishedDate"`
+	DownloadURLs    map[string]string `json:"downloadUrls"` // keyed by target platform
 }
 
 // DownloadResult represents the result of a download operation
 type DownloadResult struct {
 	FilePath      string
 	WasDownloaded bool
+	// SHA256 is the checksum of the downloaded package, set when
+	// WasDownloaded.
+	SHA256 string
 }
 
 type MicrosoftMarketplace struct {
@@ -37,9 +71,7 @@ type MicrosoftMarketplace struct {
 
 func NewMicrosoft() *MicrosoftMarketplace {
 	return &MicrosoftMarketplace{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client: newClient(),
 	}
 }
 
@@ -59,7 +91,7 @@ func (m *MicrosoftMarketplace) GetExtensionInfo(marketplaceURL string) (*Extensi
 		return nil, fmt.Errorf("failed to extract extension ID: %w", err)
 	}
 
-	info, err := m.fetchExtensionInfo(extensionID)
+	info, err := m.fetchExtensionInfo(extensionID, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch extension info: %w", err)
 	}
@@ -67,27 +99,12 @@ func (m *MicrosoftMarketplace) GetExtensionInfo(marketplaceURL string) (*Extensi
 	return info, nil
 }
 
-func (m *MicrosoftMarketplace) GetExtensionInfoByID(extensionID string) (*ExtensionInfo, error) {
-	return m.fetchExtensionInfo(extensionID)
+func (m *MicrosoftMarketplace) GetExtensionInfoByID(extensionID, targetPlatform string) (*ExtensionInfo, error) {
+	return m.fetchExtensionInfo(extensionID, targetPlatform)
 }
 
 func (m *MicrosoftMarketplace) DownloadExtension(info *ExtensionInfo, targetDir string) (*DownloadResult, error) {
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	fileName := fmt.Sprintf("%s-%s.vsix", info.Name, info.Version)
-	filePath := filepath.Join(targetDir, fileName)
-
-	if _, err := os.Stat(filePath); err == nil {
-		return &DownloadResult{FilePath: filePath, WasDownloaded: false}, nil
-	}
-
-	if err := m.downloadFile(info.DownloadURL, filePath); err != nil {
-		return nil, err
-	}
-
-	return &DownloadResult{FilePath: filePath, WasDownloaded: true}, nil
+	return downloadExtension(m.client, info, targetDir)
 }
 
 func (m *MicrosoftMarketplace) extractExtensionID(parsedURL *url.URL) (string, error) {
@@ -117,9 +134,80 @@ func (m *MicrosoftMarketplace) extractExtensionID(parsedURL *url.URL) (string, e
 	return "", fmt.Errorf("could not extract extension ID from URL: %s", parsedURL.String())
 }
 
-func (m *MicrosoftMarketplace) fetchExtensionInfo(extensionID string) (*ExtensionInfo, error) {
-	apiURL := "https://marketplace.visualstudio.com/_apis/public/gallery/extensionquery"
+const microsoftGalleryURL = "https://marketplace.visualstudio.com/_apis/public/gallery/extensionquery"
 
+const vsixPackageAssetType = "Microsoft.VisualStudio.Services.VSIXPackage"
+
+type galleryVersion struct {
+	Version        string    `json:"version"`
+	TargetPlatform string    `json:"targetPlatform"`
+	LastUpdated    time.Time `json:"lastUpdated"`
+	Files          []struct {
+		AssetType string `json:"assetType"`
+		Source    string `json:"source"`
+	} `json:"files"`
+}
+
+// vsixURL returns the download URL of the version's package.
+func (v galleryVersion) vsixURL() string {
+	for _, file := range v.Files {
+		if file.AssetType == vsixPackageAssetType {
+			return file.Source
+		}
+	}
+	return ""
+}
+
+type galleryExtension struct {
+	ExtensionID      string           `json:"extensionId"`
+	ExtensionName    string           `json:"extensionName"`
+	DisplayName      string           `json:"displayName"`
+	ShortDescription string           `json:"shortDescription"`
+	Versions         []galleryVersion `json:"versions"`
+	Publisher        struct {
+		PublisherName string `json:"publisherName"`
+	} `json:"publisher"`
+	Statistics []struct {
+		StatisticName string  `json:"statisticName"`
+		Value         float64 `json:"value"`
+	} `json:"statistics"`
+}
+
+// statistics returns the install and rating numbers of the extension, or
+// nil when the gallery sent none.
+func (e *galleryExtension) statistics() *Statistics {
+	if len(e.Statistics) == 0 {
+		return nil
+	}
+	stats := &Statistics{}
+	for _, statistic := range e.Statistics {
+		switch statistic.StatisticName {
+		case "install":
+			stats.Installs = int64(statistic.Value)
+		case "averagerating":
+			stats.AverageRating = statistic.Value
+		case "ratingcount":
+			stats.RatingCount = int64(statistic.Value)
+		}
+	}
+	return stats
+}
+
+// latestPlatforms returns the target platforms the newest version was built for.
+func (e *galleryExtension) latestPlatforms() []string {
+	var platforms []string
+	for _, version := range e.Versions {
+		if version.Version == e.Versions[0].Version {
+			platforms = append(platforms, version.TargetPlatform)
+		}
+	}
+	return platforms
+}
+
+// queryExtension looks the extension up by its "publisher.name" identifier.
+// The returned versions are ordered newest first with one entry per target
+// platform.
+func (m *MicrosoftMarketplace) queryExtension(extensionID string) (*galleryExtension, error) {
 	requestBody := map[string]interface{}{
 		"filters": []map[string]interface{}{
 			{
@@ -133,7 +221,8 @@ func (m *MicrosoftMarketplace) fetchExtensionInfo(extensionID string) (*Extensio
 				"pageSize":   1,
 			},
 		},
-		"flags": 2151,
+		// 0x100 is IncludeStatistics, for the install and rating numbers.
+		"flags": 2151 | 0x100,
 	}
 
 	jsonData, err := json.Marshal(requestBody)
@@ -141,7 +230,7 @@ func (m *MicrosoftMarketplace) fetchExtensionInfo(extensionID string) (*Extensio
 		return nil, fmt.Errorf("failed to serialize request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(string(jsonData)))
+	req, err := http.NewRequest("POST", microsoftGalleryURL, strings.NewReader(string(jsonData)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -161,28 +250,17 @@ func (m *MicrosoftMarketplace) fetchExtensionInfo(extensionID string) (*Extensio
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
+	if err := checkJSONResponse(resp, bodyBytes); err != nil {
+		return nil, err
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("invalid status: %d, body: %s", resp.StatusCode, string(bodyBytes))
 	}
 
 	var response struct {
 		Results []struct {
-			Extensions []struct {
-				ExtensionID      string `json:"extensionId"`
-				ExtensionName    string `json:"extensionName"`
-				DisplayName      string `json:"displayName"`
-				ShortDescription string `json:"shortDescription"`
-				Versions         []struct {
-					Version string `json:"version"`
-					Files   []struct {
-						AssetType string `json:"assetType"`
-						Source    string `json:"source"`
-					} `json:"files"`
-				} `json:"versions"`
-				Publisher struct {
-					PublisherName string `json:"publisherName"`
-				} `json:"publisher"`
-			} `json:"extensions"`
+			Extensions []galleryExtension `json:"extensions"`
 		} `json:"results"`
 	}
 
@@ -194,59 +272,75 @@ func (m *MicrosoftMarketplace) fetchExtensionInfo(extensionID string) (*Extensio
 		return nil, fmt.Errorf("extension not found: %s", extensionID)
 	}
 
-	ext := response.Results[0].Extensions[0]
+	ext := &response.Results[0].Extensions[0]
 
 	if len(ext.Versions) == 0 {
-		return nil, fmt.Errorf("no versions found for extension")
+		return nil, fmt.Errorf("%s: %w", extensionID, ErrNoVersion)
 	}
 
-	latestVersion := ext.Versions[0]
-	var downloadURL string
+	return ext, nil
+}
 
-	for _, file := range latestVersion.Files {
-		if file.AssetType == "Microsoft.VisualStudio.Services.VSIXPackage" {
-			downloadURL = file.Source
-			break
-		}
-	}
+// Ping asks the gallery for a single VS Code extension without any details.
+func (m *MicrosoftMarketplace) Ping(ctx context.Context) error {
+	body := `{"filters":[{"criteria":[{"filterType":8,"value":"Microsoft.VisualStudio.Code"}],"pageNumber":1,"pageSize":1}],"flags":0}`
 
-	if downloadURL == "" {
-		return nil, fmt.Errorf("download URL not found")
+	req, err := http.NewRequestWithContext(ctx, "POST", microsoftGalleryURL, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json; api-version=3.0-preview.1")
 
-	return &ExtensionInfo{
-		ID:          ext.ExtensionID,
-		Name:        ext.ExtensionName,
-		DisplayName: ext.DisplayName,
-		Description: ext.ShortDescription,
-		Version:     latestVersion.Version,
-		Publisher:   ext.Publisher.PublisherName,
-		DownloadURL: downloadURL,
-	}, nil
+	return doPing(m.client, req)
 }
 
-func (m *MicrosoftMarketplace) downloadFile(downloadURL, filePath string) error {
-	resp, err := m.client.Get(downloadURL)
+func (m *MicrosoftMarketplace) fetchExtensionInfo(extensionID, targetPlatform string) (*ExtensionInfo, error) {
+	ext, err := m.queryExtension(extensionID)
 	if err != nil {
-		return fmt.Errorf("request error: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("invalid status code: %d", resp.StatusCode)
+	platforms := make([]string, len(ext.Versions))
+	for i, version := range ext.Versions {
+		platforms[i] = version.TargetPlatform
 	}
 
-	file, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+	versionIndex := selectPlatformIndex(platforms, targetPlatform)
+	if versionIndex < 0 {
+		return nil, platformNotFoundError(extensionID, targetPlatform, ext.latestPlatforms())
+	}
+
+	latestVersion := ext.Versions[versionIndex]
+	downloadURL := latestVersion.vsixURL()
+
+	if downloadURL == "" {
+		return nil, fmt.Errorf("%s %s (%s): %w", extensionID, latestVersion.Version, normalizePlatform(latestVersion.TargetPlatform), ErrNoDownloadURL)
 	}
-	defer file.Close()
 
-	written, err := io.Copy(file, resp.Body)
+	return &ExtensionInfo{
+		ID:             ext.ExtensionID,
+		Name:           ext.ExtensionName,
+		DisplayName:    ext.DisplayName,
+		Description:    ext.ShortDescription,
+		Version:        latestVersion.Version,
+		Publisher:      ext.Publisher.PublisherName,
+		DownloadURL:    downloadURL,
+		TargetPlatform: normalizePlatform(latestVersion.TargetPlatform),
+		Statistics:     ext.statistics(),
+	}, nil
+}
+
+// GetVersions lists every published version of the extension, newest first.
+func (m *MicrosoftMarketplace) GetVersions(extensionID string) ([]VersionInfo, error) {
+	ext, err := m.queryExtension(extensionID)
 	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+		return nil, err
 	}
 
-	fmt.Printf("Downloaded: %s (%d bytes)\n", filePath, written)
-	return nil
+	var versions versionCollector
+	for _, version := range ext.Versions {
+		versions.add(version.Version, version.TargetPlatform, version.LastUpdated, version.vsixURL())
+	}
+	return versions.list(), nil
 }
@@ -1,14 +1,14 @@
 package marketplace
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -19,9 +19,7 @@ type OpenVSXMarketplace struct {
 
 func NewOpenVSX() *OpenVSXMarketplace {
 	return &OpenVSXMarketplace{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client: newClient(),
 	}
 }
 
@@ -41,7 +39,7 @@ func (m *OpenVSXMarketplace) GetExtensionInfo(marketplaceURL string) (*Extension
 		return nil, fmt.Errorf("failed to extract extension ID: %w", err)
 	}
 
-	info, err := m.fetchExtensionInfo(extensionID)
+	info, err := m.fetchExtensionInfo(extensionID, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch extension info: %w", err)
 	}
@@ -49,27 +47,12 @@ func (m *OpenVSXMarketplace) GetExtensionInfo(marketplaceURL string) (*Extension
 	return info, nil
 }
 
-func (m *OpenVSXMarketplace) GetExtensionInfoByID(extensionID string) (*ExtensionInfo, error) {
-	return m.fetchExtensionInfo(extensionID)
+func (m *OpenVSXMarketplace) GetExtensionInfoByID(extensionID, targetPlatform string) (*ExtensionInfo, error) {
+	return m.fetchExtensionInfo(extensionID, targetPlatform)
 }
 
 func (m *OpenVSXMarketplace) DownloadExtension(info *ExtensionInfo, targetDir string) (*DownloadResult, error) {
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	fileName := fmt.Sprintf("%s-%s.vsix", info.Name, info.Version)
-	filePath := filepath.Join(targetDir, fileName)
-
-	if _, err := os.Stat(filePath); err == nil {
-		return &DownloadResult{FilePath: filePath, WasDownloaded: false}, nil
-	}
-
-	if err := m.downloadFile(info.DownloadURL, filePath); err != nil {
-		return nil, err
-	}
-
-	return &DownloadResult{FilePath: filePath, WasDownloaded: true}, nil
+	return downloadExtension(m.client, info, targetDir)
 }
 
 func (m *OpenVSXMarketplace) extractExtensionID(parsedURL *url.URL) (string, error) {
@@ -89,11 +72,45 @@ func (m *OpenVSXMarketplace) extractExtensionID(parsedURL *url.URL) (string, err
 	return "", fmt.Errorf("could not extract extension ID from Open VSX URL: %s", parsedURL.String())
 }
 
-func (m *OpenVSXMarketplace) fetchExtensionInfo(extensionID string) (*ExtensionInfo, error) {
-	// Open VSX Registry API endpoint
-	apiURL := fmt.Sprintf("https://open-vsx.org/api/-/query?extensionId=%s", extensionID)
+const openVSXQueryURL = "https://open-vsx.org/api/-/query"
+
+type openVSXExtension struct {
+	ExtensionName  string    `json:"name"`
+	DisplayName    string    `json:"displayName"`
+	Description    string    `json:"description"`
+	Publisher      string    `json:"namespace"`
+	LatestVersion  string    `json:"version"`
+	TargetPlatform string    `json:"targetPlatform"`
+	Timestamp      time.Time `json:"timestamp"`
+	DownloadCount  int64     `json:"downloadCount"`
+	AverageRating  *float64  `json:"averageRating"`
+	ReviewCount    int64     `json:"reviewCount"`
+	Files          struct {
+		Download string `json:"download"`
+		SHA256   string `json:"sha256"`
+	} `json:"files"`
+}
+
+// statistics returns the download and rating numbers of the extension.
+// Unrated extensions have no averageRating.
+func (e *openVSXExtension) statistics() *Statistics {
+	stats := &Statistics{Installs: e.DownloadCount}
+	if e.AverageRating != nil && e.ReviewCount > 0 {
+		stats.AverageRating, stats.RatingCount = *e.AverageRating, e.ReviewCount
+	}
+	return stats
+}
+
+type openVSXQueryResponse struct {
+	Offset     int                `json:"offset"`
+	TotalSize  int                `json:"totalSize"`
+	Extensions []openVSXExtension `json:"extensions"`
+}
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+// query calls the Open VSX query API, which returns one entry per published
+// version and target platform matching params.
+func (m *OpenVSXMarketplace) query(params url.Values) (*openVSXQueryResponse, error) {
+	req, err := http.NewRequest("GET", openVSXQueryURL+"?"+params.Encode(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -112,73 +129,133 @@ func (m *OpenVSXMarketplace) fetchExtensionInfo(extensionID string) (*ExtensionI
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("invalid status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	if err := checkJSONResponse(resp, bodyBytes); err != nil {
+		return nil, err
 	}
 
-	var response struct {
-		Extensions []struct {
-			ExtensionName string `json:"name"`
-			DisplayName   string `json:"displayName"`
-			Description   string `json:"description"`
-			Publisher     string `json:"namespace"`
-			LatestVersion string `json:"version"`
-			Files         struct {
-				Download string `json:"download"`
-			} `json:"files"`
-		} `json:"extensions"`
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status: %d, body: %s", resp.StatusCode, string(bodyBytes))
 	}
 
+	var response openVSXQueryResponse
 	if err := json.Unmarshal(bodyBytes, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
+	return &response, nil
+}
+
+// Ping asks the query API for a single extension.
+func (m *OpenVSXMarketplace) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", openVSXQueryURL+"?size=1", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	return doPing(m.client, req)
+}
+
+func (m *OpenVSXMarketplace) fetchExtensionInfo(extensionID, targetPlatform string) (*ExtensionInfo, error) {
+	params := url.Values{"extensionId": {extensionID}}
+	if targetPlatform != "" {
+		params.Set("targetPlatform", targetPlatform)
+	}
+
+	response, err := m.query(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Extensions) == 0 && targetPlatform != "" {
+		// Nothing for this platform; ask again to tell which platforms exist.
+		params.Del("targetPlatform")
+		if response, err = m.query(params); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(response.Extensions) == 0 {
 		return nil, fmt.Errorf("extension not found: %s", extensionID)
 	}
 
-	ext := response.Extensions[0]
+	platforms := make([]string, len(response.Extensions))
+	for i, candidate := range response.Extensions {
+		platforms[i] = candidate.TargetPlatform
+	}
 
+	extIndex := selectPlatformIndex(platforms, targetPlatform)
+	if extIndex < 0 {
+		return nil, platformNotFoundError(extensionID, targetPlatform, platforms)
+	}
+
+	ext := response.Extensions[extIndex]
+	platform := normalizePlatform(ext.TargetPlatform)
+
+	if ext.LatestVersion == "" {
+		return nil, fmt.Errorf("%s (%s): %w", extensionID, platform, ErrNoVersion)
+	}
 	if ext.Files.Download == "" {
-		return nil, fmt.Errorf("download URL not found")
+		return nil, fmt.Errorf("%s %s (%s): %w", extensionID, ext.LatestVersion, platform, ErrNoDownloadURL)
 	}
 
 	// Construct the full extension ID from namespace and name
 	fullExtensionID := fmt.Sprintf("%s.%s", ext.Publisher, ext.ExtensionName)
 
 	return &ExtensionInfo{
-		ID:          fullExtensionID,
-		Name:        ext.ExtensionName,
-		DisplayName: ext.DisplayName,
-		Description: ext.Description,
-		Version:     ext.LatestVersion,
-		Publisher:   ext.Publisher,
-		DownloadURL: ext.Files.Download,
+		ID:             fullExtensionID,
+		Name:           ext.ExtensionName,
+		DisplayName:    ext.DisplayName,
+		Description:    ext.Description,
+		Version:        ext.LatestVersion,
+		Publisher:      ext.Publisher,
+		DownloadURL:    ext.Files.Download,
+		SHA256URL:      ext.Files.SHA256,
+		TargetPlatform: platform,
+		Statistics:     ext.statistics(),
 	}, nil
 }
 
-func (m *OpenVSXMarketplace) downloadFile(downloadURL, filePath string) error {
-	resp, err := m.client.Get(downloadURL)
-	if err != nil {
-		return fmt.Errorf("request error: %w", err)
-	}
-	defer resp.Body.Close()
+// GetVersions lists every published version of the extension, newest first.
+// The query API is used instead of the per-extension versions endpoint as it
+// reports platforms, dates and download links without a request per version.
+func (m *OpenVSXMarketplace) GetVersions(extensionID string) ([]VersionInfo, error) {
+	const pageSize = 100
+
+	var versions versionCollector
+	seen := 0
+	for offset := 0; ; offset += pageSize {
+		response, err := m.query(url.Values{
+			"extensionId":        {extensionID},
+			"includeAllVersions": {"true"},
+			"offset":             {strconv.Itoa(offset)},
+			"size":               {strconv.Itoa(pageSize)},
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("invalid status code: %d", resp.StatusCode)
-	}
+		seen += len(response.Extensions)
+		for _, ext := range response.Extensions {
+			// Entries without a version or package cannot be downloaded, so
+			// they are not offered as versions.
+			if ext.LatestVersion == "" || ext.Files.Download == "" {
+				continue
+			}
+			versions.add(ext.LatestVersion, ext.TargetPlatform, ext.Timestamp, ext.Files.Download)
+		}
 
-	file, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		if len(response.Extensions) < pageSize || offset+pageSize >= response.TotalSize {
+			break
+		}
 	}
-	defer file.Close()
 
-	written, err := io.Copy(file, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	result := versions.list()
+	if len(result) == 0 {
+		if seen > 0 {
+			return nil, fmt.Errorf("%s: %w", extensionID, ErrNoVersion)
+		}
+		return nil, fmt.Errorf("extension not found: %s", extensionID)
 	}
-
-	fmt.Printf("Downloaded: %s (%d bytes)\n", filePath, written)
-	return nil
+	return result, nil
 }
@@ -0,0 +1,121 @@
+package marketplace
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// recordedTransport answers Open VSX query API requests with a recorded
+// response body, chosen by the targetPlatform parameter of the request.
+type recordedTransport map[string]string
+
+func (rt recordedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := rt[req.URL.Query().Get("targetPlatform")]
+	if !ok {
+		body = `{"offset":0,"totalSize":0,"extensions":[]}`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// openVSXResponse is a query API response for redhat.java as recorded from
+// open-vsx.org, with the given version and files object of its only entry.
+func openVSXResponse(platform, version, files string) string {
+	return `{"offset":0,"totalSize":1,"extensions":[{
+		"url":"https://open-vsx.org/api/redhat/java/` + platform + `/` + version + `",
+		"files":` + files + `,
+		"name":"java","namespace":"redhat","targetPlatform":"` + platform + `","version":"` + version + `",
+		"preRelease":false,"timestamp":"2024-05-14T08:23:11.893Z","displayName":"Language Support for Java(TM) by Red Hat",
+		"description":"Java Linting, Intellisense, formatting, refactoring, Maven/Gradle support and more...",
+		"downloadCount":2417791,"averageRating":4.2,"reviewCount":17}]}`
+}
+
+const openVSXFiles = `{
+	"download":"https://open-vsx.org/api/redhat/java/linux-x64/1.31.0/file/redhat.java-1.31.0@linux-x64.vsix",
+	"sha256":"https://open-vsx.org/api/redhat/java/linux-x64/1.31.0/file/redhat.java-1.31.0@linux-x64.sha256",
+	"manifest":"https://open-vsx.org/api/redhat/java/linux-x64/1.31.0/file/package.json"}`
+
+func TestOpenVSXFetchExtensionInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses recordedTransport
+		platform  string
+		wantErr   error
+		wantText  string
+	}{
+		{
+			name:      "complete entry",
+			responses: recordedTransport{"linux-x64": openVSXResponse("linux-x64", "1.31.0", openVSXFiles)},
+			platform:  "linux-x64",
+		},
+		{
+			name: "missing download URL",
+			responses: recordedTransport{"linux-x64": openVSXResponse("linux-x64", "1.31.0",
+				`{"sha256":"https://open-vsx.org/api/redhat/java/linux-x64/1.31.0/file/redhat.java-1.31.0@linux-x64.sha256"}`)},
+			platform: "linux-x64",
+			wantErr:  ErrNoDownloadURL,
+			wantText: "redhat.java 1.31.0 (linux-x64)",
+		},
+		{
+			name:      "empty download URL",
+			responses: recordedTransport{"": openVSXResponse("universal", "1.31.0", `{"download":""}`)},
+			wantErr:   ErrNoDownloadURL,
+		},
+		{
+			name:      "missing version",
+			responses: recordedTransport{"": openVSXResponse("universal", "", openVSXFiles)},
+			wantErr:   ErrNoVersion,
+		},
+		{
+			name:      "other platform only",
+			responses: recordedTransport{"": openVSXResponse("win32-x64", "1.31.0", openVSXFiles)},
+			platform:  "linux-x64",
+			wantText:  "win32-x64",
+		},
+		{
+			name:      "unknown extension",
+			responses: recordedTransport{},
+			wantText:  "extension not found: redhat.java",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &OpenVSXMarketplace{client: &http.Client{Transport: tt.responses}}
+			info, err := m.GetExtensionInfoByID("redhat.java", tt.platform)
+
+			if tt.wantErr == nil && tt.wantText == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.FullName() != "redhat.java" || info.Version != "1.31.0" || info.TargetPlatform != "linux-x64" {
+					t.Errorf("info = %+v", info)
+				}
+				if !strings.HasSuffix(info.DownloadURL, ".vsix") || !strings.HasSuffix(info.SHA256URL, ".sha256") {
+					t.Errorf("DownloadURL = %q, SHA256URL = %q", info.DownloadURL, info.SHA256URL)
+				}
+				if info.Statistics == nil || info.Statistics.Installs != 2417791 || info.Statistics.RatingCount != 17 {
+					t.Errorf("Statistics = %+v", info.Statistics)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("got %+v, want an error", info)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error %v is not %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantText) {
+				t.Errorf("error %q does not mention %q", err, tt.wantText)
+			}
+		})
+	}
+}
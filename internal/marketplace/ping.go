@@ -0,0 +1,82 @@
+package marketplace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PingResult reports whether a marketplace answered a lightweight request.
+type PingResult struct {
+	Type      MarketplaceType `json:"type"`
+	Name      string          `json:"name"`
+	Reachable bool            `json:"reachable"`
+	LatencyMS int64           `json:"latency_ms"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Ping checks every marketplace of types concurrently, giving each at most
+// timeout to answer. Results are in the order of types.
+func (f *Factory) Ping(ctx context.Context, types []MarketplaceType, timeout time.Duration) []PingResult {
+	results := make([]PingResult, len(types))
+
+	var wg sync.WaitGroup
+	for i, marketplaceType := range types {
+		results[i] = PingResult{Type: marketplaceType, Name: string(marketplaceType)}
+
+		provider, err := f.CreateByType(marketplaceType)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Name = provider.GetName()
+
+		wg.Add(1)
+		go func(result *PingResult) {
+			defer wg.Done()
+
+			pingCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := provider.Ping(pingCtx)
+			result.LatencyMS = time.Since(start).Milliseconds()
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			result.Reachable = true
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results
+}
+
+// doPing sends req and checks that the marketplace answered with JSON and
+// without a server error. Only the start of the body is read.
+func doPing(client *http.Client, req *http.Request) error {
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if err := checkJSONResponse(resp, body); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("invalid status: %d", resp.StatusCode)
+	}
+	return nil
+}
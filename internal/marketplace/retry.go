@@ -0,0 +1,146 @@
+package marketplace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"littlevsx/internal/config"
+)
+
+// responseTimeout bounds how long a marketplace may take to send response
+// headers, and how long a response body may stall between reads. There is
+// no limit on the whole request, as large packages take a while to download.
+const responseTimeout = 30 * time.Second
+
+// newClient returns the HTTP client of a marketplace. Its requests are
+// retried as configured by download.retries and download.retry_delay.
+func newClient() *http.Client {
+	cfg := config.GetConfig()
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.ResponseHeaderTimeout = responseTimeout
+	return &http.Client{
+		Transport: &retryTransport{
+			base:        base,
+			retries:     max(cfg.DownloadRetries, 0),
+			delay:       time.Duration(max(cfg.DownloadRetryDelay, 0)) * time.Second,
+			idleTimeout: responseTimeout,
+		},
+	}
+}
+
+// retryTransport retries requests that failed with a network error or a
+// server error (5xx), waiting delay before the first retry and twice as long
+// before each further one. Client errors such as 404 are returned at once.
+// Requests with a body are only retried when it can be rewound. A response
+// body that delivers no data for idleTimeout fails with errIdleTimeout.
+type retryTransport struct {
+	base        http.RoundTripper
+	retries     int
+	delay       time.Duration
+	idleTimeout time.Duration
+}
+
+// errIdleTimeout is returned by reads of a response body that stalled.
+var errIdleTimeout = errors.New("response body stalled")
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := t.delay
+	for attempt := 0; ; attempt++ {
+		resp, err := t.roundTrip(req)
+		if attempt >= t.retries || req.Context().Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		fmt.Printf("⚠️  %s %s failed (%s), retrying in %s (%d of %d)\n",
+			req.Method, req.URL.Redacted(), reason, delay, attempt+1, t.retries)
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		delay *= 2
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// roundTrip makes one attempt at req. Its body is read under an idle timer
+// that cancels the attempt when no data arrives for t.idleTimeout.
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if t.idleTimeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &idleTimeoutBody{ReadCloser: resp.Body, timeout: t.idleTimeout, cancel: cancel}
+	body.timer = time.AfterFunc(t.idleTimeout, body.expire)
+	resp.Body = body
+	return resp, nil
+}
+
+// idleTimeoutBody cancels its request when the timer, restarted after every
+// read, fires.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired atomic.Bool
+}
+
+func (b *idleTimeoutBody) expire() {
+	b.expired.Store(true)
+	b.cancel()
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.expired.Load() {
+		return n, errIdleTimeout
+	}
+	b.timer.Reset(b.timeout)
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryable reports whether a request that ended with resp or err may
+// succeed when sent again: network errors, including timeouts, and server
+// errors.
+func retryable(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= 500
+}
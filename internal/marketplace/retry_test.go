@@ -0,0 +1,165 @@
+package marketplace
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	const idle = 100 * time.Millisecond
+
+	tests := []struct {
+		name string
+		// respond answers the attempt'th request, counting from 1.
+		respond      func(w http.ResponseWriter, attempt int32)
+		wantStatus   int
+		wantBody     string
+		wantErr      error
+		wantAttempts int32
+	}{
+		{
+			name: "success",
+			respond: func(w http.ResponseWriter, attempt int32) {
+				io.WriteString(w, "ok")
+			},
+			wantStatus:   http.StatusOK,
+			wantBody:     "ok",
+			wantAttempts: 1,
+		},
+		{
+			name: "server errors are retried",
+			respond: func(w http.ResponseWriter, attempt int32) {
+				if attempt < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				io.WriteString(w, "ok")
+			},
+			wantStatus:   http.StatusOK,
+			wantBody:     "ok",
+			wantAttempts: 3,
+		},
+		{
+			name: "retries run out",
+			respond: func(w http.ResponseWriter, attempt int32) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantStatus:   http.StatusBadGateway,
+			wantAttempts: 3,
+		},
+		{
+			name: "not found is not retried",
+			respond: func(w http.ResponseWriter, attempt int32) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantStatus:   http.StatusNotFound,
+			wantAttempts: 1,
+		},
+		{
+			name: "slow steady body outlasts the idle timeout",
+			respond: func(w http.ResponseWriter, attempt int32) {
+				for range 5 {
+					io.WriteString(w, "x")
+					w.(http.Flusher).Flush()
+					time.Sleep(idle / 2)
+				}
+			},
+			wantStatus:   http.StatusOK,
+			wantBody:     "xxxxx",
+			wantAttempts: 1,
+		},
+		{
+			name: "stalled body",
+			respond: func(w http.ResponseWriter, attempt int32) {
+				io.WriteString(w, "x")
+				w.(http.Flusher).Flush()
+				time.Sleep(4 * idle)
+			},
+			wantStatus:   http.StatusOK,
+			wantErr:      errIdleTimeout,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.respond(w, attempts.Add(1))
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: &retryTransport{
+				base:        http.DefaultTransport,
+				retries:     2,
+				delay:       time.Millisecond,
+				idleTimeout: idle,
+			}}
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("reading the body: %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+// TestRetryTransportRewindsBody checks that a POST body is sent again on
+// every attempt.
+func TestRetryTransportRewindsBody(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &retryTransport{base: http.DefaultTransport, retries: 1, delay: time.Millisecond}}
+	resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{"q":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || strings.Join(bodies, " ") != `{"q":1} {"q":1}` {
+		t.Errorf("status %d after requests with bodies %q", resp.StatusCode, bodies)
+	}
+}
+
+// TestNewClientHasNoOverallTimeout checks that marketplace clients bound
+// the wait for response headers, not the whole download.
+func TestNewClientHasNoOverallTimeout(t *testing.T) {
+	client := newClient()
+	if client.Timeout != 0 {
+		t.Errorf("Timeout = %s, want none", client.Timeout)
+	}
+	transport := client.Transport.(*retryTransport)
+	if got := transport.base.(*http.Transport).ResponseHeaderTimeout; got != responseTimeout {
+		t.Errorf("ResponseHeaderTimeout = %s, want %s", got, responseTimeout)
+	}
+	if transport.idleTimeout != responseTimeout {
+		t.Errorf("idleTimeout = %s, want %s", transport.idleTimeout, responseTimeout)
+	}
+}
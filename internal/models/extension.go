@@ -35,6 +35,20 @@ type Extension struct {
 	Deprecated       bool      `json:"deprecated"`
 	TargetPlatform   string    `json:"targetPlatform"`
 	ReadmeContent    string    `json:"readmeContent"`
+	Featured         bool      `json:"featured"`
+	Source           string    `json:"source"`
+	MetadataOnly     bool      `json:"metadataOnly"`
+	Dependencies     []string  `json:"dependencies,omitempty"`
+	ExtensionPack    []string  `json:"extensionPack,omitempty"`
+	Pinned           bool      `json:"pinned"`
+	SHA256           string    `json:"sha256"`
+}
+
+// ArchiveEntry describes a file inside a .vsix package.
+type ArchiveEntry struct {
+	Name           string `json:"name"`
+	Size           uint64 `json:"size"`
+	CompressedSize uint64 `json:"compressedSize"`
 }
 
 type Engines struct {
@@ -49,6 +63,16 @@ type VersionReference struct {
 	Files          map[string]string `json:"files"`
 }
 
+// Publisher holds the gallery details of a publisher. Domain is shown by
+// clients next to the publisher name, with a checkmark when Verified is set.
+type Publisher struct {
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+	Domain      string    `json:"domain"`
+	Verified    bool      `json:"verified"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
 type Namespace struct {
 	Name        string    `json:"name"`
 	DisplayName string    `json:"displayName"`
@@ -59,14 +83,18 @@ type Namespace struct {
 	UpdatedAt   time.Time `json:"updatedAt"`
 }
 
-type QueryResult struct {
+type SearchResult struct {
 	Offset     int         `json:"offset"`
 	TotalSize  int         `json:"totalSize"`
 	Extensions []Extension `json:"extensions"`
 }
 
-type SearchResult struct {
-	Offset     int         `json:"offset"`
-	TotalSize  int         `json:"totalSize"`
-	Extensions []Extension `json:"extensions"`
+type CatalogEntry struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Version     string `json:"version"`
+	Publisher   string `json:"publisher"`
+	DownloadURL string `json:"downloadUrl"`
+	IconURL     string `json:"iconUrl,omitempty"`
 }
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/testutil"
+)
+
+// TestReadmeAssetsUnderURLPrefix rewrites a README with the asset
+// processor and requests the rewritten image URLs from the server, for the
+// default and a custom assets.url_prefix.
+func TestReadmeAssetsUnderURLPrefix(t *testing.T) {
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(testutil.PNG)
+	}))
+	defer images.Close()
+
+	tests := []struct {
+		name       string
+		prefix     string
+		wantPrefix string
+	}{
+		{"default prefix", "", "/_assets/"},
+		{"custom prefix", "/vsx/_assets", "/vsx/_assets/"},
+		{"prefix without slashes", "vsx/_assets/", "/vsx/_assets/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, map[string]interface{}{"assets.url_prefix": tt.prefix})
+			ap := extensions.NewAssetProcessor(config.GetConfig().AssetsDir, "http://gallery.test")
+			readme, err := ap.ProcessReadme(context.Background(), "![shot]("+images.URL+"/docs/shot.png?raw=true)", "acme.tool")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			localURL := regexp.MustCompile(`\(([^)]+)\)`).FindStringSubmatch(readme)
+			if localURL == nil || !strings.HasPrefix(localURL[1], "http://gallery.test"+tt.wantPrefix) {
+				t.Fatalf("README = %q, want an image under %s", readme, tt.wantPrefix)
+			}
+			path := strings.TrimPrefix(localURL[1], "http://gallery.test")
+
+			rec := serve(s, http.MethodGet, path, "", nil)
+			if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), testutil.PNG) {
+				t.Errorf("GET %s = %d with %d bytes, want the image", path, rec.Code, rec.Body.Len())
+			}
+			// READMEs rewritten before the prefix changed keep working.
+			legacy := config.DefaultAssetsURLPrefix + "/acme.tool/shot.png"
+			if rec := serve(s, http.MethodGet, legacy, "", nil); rec.Code != http.StatusOK {
+				t.Errorf("GET %s = %d, want 200", legacy, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+var (
+	gzipWriterPool = sync.Pool{
+		New: func() interface{} { return gzip.NewWriter(io.Discard) },
+	}
+	brotliWriterPool = sync.Pool{
+		New: func() interface{} { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) },
+	}
+)
+
+// compressibleContentTypes lists the response types worth compressing.
+// Everything else (VSIX packages, PNG icons, ...) is already compressed.
+var compressibleContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/xml",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+// compressionMiddleware compresses textual responses with brotli or gzip,
+// whichever the client ranks higher in Accept-Encoding. Brotli wins ties and
+// can be disabled with compression.brotli.
+func (s *Server) compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := s.settings.Load()
+		if !settings.compressionEnabled || r.Method == "HEAD" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), settings.brotliEnabled)
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+func negotiateEncoding(acceptEncoding string, brotliEnabled bool) string {
+	qualities := make(map[string]float64)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		qualities[coding] = quality
+	}
+
+	quality := func(coding string) float64 {
+		if q, ok := qualities[coding]; ok {
+			return q
+		}
+		return qualities["*"]
+	}
+
+	brotliQuality, gzipQuality := quality(encodingBrotli), quality(encodingGzip)
+
+	switch {
+	case brotliEnabled && brotliQuality > 0 && brotliQuality >= gzipQuality:
+		return encodingBrotli
+	case gzipQuality > 0:
+		return encodingGzip
+	}
+	return ""
+}
+
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range compressibleContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressResponseWriter decides whether to compress once the status code and
+// headers are known, so handlers keep full control over the content type.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	writer      io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	header := cw.Header()
+	if status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" &&
+		isCompressible(header.Get(contentTypeHeader)) {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		cw.writer = cw.newWriter()
+	}
+
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressResponseWriter) Write(data []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.writer != nil {
+		return cw.writer.Write(data)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+func (cw *compressResponseWriter) Flush() {
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) Close() error {
+	if cw.writer == nil {
+		return nil
+	}
+
+	err := cw.writer.Close()
+	switch writer := cw.writer.(type) {
+	case *gzip.Writer:
+		gzipWriterPool.Put(writer)
+	case *brotli.Writer:
+		brotliWriterPool.Put(writer)
+	}
+	cw.writer = nil
+	return err
+}
+
+func (cw *compressResponseWriter) newWriter() io.WriteCloser {
+	if cw.encoding == encodingBrotli {
+		writer := brotliWriterPool.Get().(*brotli.Writer)
+		writer.Reset(cw.ResponseWriter)
+		return writer
+	}
+
+	writer := gzipWriterPool.Get().(*gzip.Writer)
+	writer.Reset(cw.ResponseWriter)
+	return writer
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"littlevsx/internal/database"
+	"littlevsx/internal/testutil"
+)
+
+func TestGalleryTime(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*60*60)
+	tests := []struct {
+		time time.Time
+		want string
+	}{
+		{time.Date(2024, 5, 14, 8, 23, 11, 893000000, time.UTC), "2024-05-14T08:23:11.893Z"},
+		{time.Date(2024, 5, 14, 8, 23, 11, 0, time.UTC), "2024-05-14T08:23:11.000Z"},
+		{time.Date(2024, 5, 14, 8, 23, 11, 893456789, time.UTC), "2024-05-14T08:23:11.893Z"},
+		{time.Date(2024, 5, 14, 10, 23, 11, 5000000, berlin), "2024-05-14T08:23:11.005Z"},
+		{time.Date(2024, 1, 1, 0, 30, 0, 0, berlin), "2023-12-31T22:30:00.000Z"},
+	}
+	for _, tt := range tests {
+		if got := galleryTime(tt.time); got != tt.want {
+			t.Errorf("galleryTime(%s) = %s, want %s", tt.time, got, tt.want)
+		}
+	}
+}
+
+// TestGalleryResponseDates compares the date fields of an extensionquery
+// response with the layout of the Visual Studio Marketplace, e.g.
+// "2024-05-14T08:23:11.893Z".
+func TestGalleryResponseDates(t *testing.T) {
+	s, m := newTestServer(t, nil)
+	path := addTestPackage(t, m, testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"})
+	modified := time.Date(2024, 5, 14, 10, 23, 11, 893456789, time.FixedZone("CEST", 2*60*60))
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	ext, err := m.ReadExtensionInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.GetDB().UpsertExtension(database.ToDBExtension(ext)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(s, http.MethodPost, "/_apis/public/gallery/extensionquery",
+		`{"filters":[{"criteria":[{"filterType":7,"value":"acme.tool"}]}]}`, nil)
+	var response struct {
+		Results []struct {
+			Extensions []struct {
+				LastUpdated   string `json:"lastUpdated"`
+				PublishedDate string `json:"publishedDate"`
+				ReleaseDate   string `json:"releaseDate"`
+				Versions      []struct {
+					LastUpdated string `json:"lastUpdated"`
+				} `json:"versions"`
+			} `json:"extensions"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode %s: %v", rec.Body, err)
+	}
+	if len(response.Results) != 1 || len(response.Results[0].Extensions) != 1 {
+		t.Fatalf("got %s, want one extension", rec.Body)
+	}
+
+	layout := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`)
+	got := response.Results[0].Extensions[0]
+	fields := map[string]string{
+		"lastUpdated":   got.LastUpdated,
+		"publishedDate": got.PublishedDate,
+		"releaseDate":   got.ReleaseDate,
+	}
+	for i, version := range got.Versions {
+		fields[fmt.Sprintf("versions[%d].lastUpdated", i)] = version.LastUpdated
+	}
+	for name, value := range fields {
+		if !layout.MatchString(value) {
+			t.Errorf("%s = %q does not match the marketplace layout", name, value)
+		}
+	}
+	if want := "2024-05-14T08:23:11.893Z"; got.LastUpdated != want {
+		t.Errorf("lastUpdated = %q, want %q", got.LastUpdated, want)
+	}
+}
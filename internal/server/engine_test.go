@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+// TestEngineFilterServesNewestCompatible stores an extension whose latest
+// version needs a newer VS Code than an older one and checks what clients
+// of several versions are offered.
+func TestEngineFilterServesNewestCompatible(t *testing.T) {
+	packages := []testutil.VSIX{
+		{Publisher: "acme", Name: "tool", Version: "1.0.0", PackageJSON: map[string]interface{}{
+			"engines": map[string]string{"vscode": "^1.80.0"},
+		}},
+		{Publisher: "acme", Name: "tool", Version: "1.5.0", PackageJSON: map[string]interface{}{
+			"engines": map[string]string{"vscode": "^1.85.0"},
+		}},
+		{Publisher: "acme", Name: "tool", Version: "2.0.0", PackageJSON: map[string]interface{}{
+			"engines": map[string]string{"vscode": "^1.90.0"},
+		}},
+	}
+
+	tests := []struct {
+		name          string
+		filter        bool
+		clientVersion string
+		wantVersions  []string
+		wantLatest    string
+	}{
+		{"filter off", false, "1.82.0", []string{"2.0.0", "1.5.0", "1.0.0"}, "2.0.0"},
+		{"no client version", true, "", []string{"2.0.0", "1.5.0", "1.0.0"}, "2.0.0"},
+		{"current client", true, "1.95.0", []string{"2.0.0", "1.5.0", "1.0.0"}, "2.0.0"},
+		{"older client", true, "1.86.0", []string{"1.5.0", "1.0.0"}, "1.5.0"},
+		{"oldest client", true, "1.80.1", []string{"1.0.0"}, "1.0.0"},
+		{"client too old", true, "1.70.0", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, map[string]interface{}{"api.filter_by_engine": tt.filter}, packages...)
+			header := map[string]string{clientVersionHeader: tt.clientVersion}
+
+			for _, body := range []string{
+				`{"filters":[{"criteria":[{"filterType":7,"value":"acme.tool"}]}]}`,
+				`{"filters":[{"criteria":[{"filterType":10,"value":"tool"}]}]}`,
+			} {
+				extensions := query(t, s, body, header).Results[0].Extensions
+				if tt.wantVersions == nil {
+					if len(extensions) != 0 {
+						t.Errorf("%s: got %d extensions, want none", body, len(extensions))
+					}
+					continue
+				}
+				if len(extensions) != 1 {
+					t.Fatalf("%s: got %d extensions, want 1", body, len(extensions))
+				}
+				var versions []string
+				for _, version := range extensions[0].Versions {
+					versions = append(versions, version.Version)
+				}
+				if !slices.Equal(versions, tt.wantVersions) {
+					t.Errorf("%s: versions = %v, want %v", body, versions, tt.wantVersions)
+				}
+			}
+
+			rec := serve(s, http.MethodGet, "/_gallery/acme/tool/latest", "", header)
+			if tt.wantLatest == "" {
+				if rec.Code != http.StatusNotFound {
+					t.Errorf("latest answered %d, want 404", rec.Code)
+				}
+				return
+			}
+			var latest struct {
+				Version string `json:"version"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &latest); err != nil || rec.Code != http.StatusOK {
+				t.Fatalf("latest answered %d: %s", rec.Code, rec.Body)
+			}
+			if latest.Version != tt.wantLatest {
+				t.Errorf("latest = %s, want %s", latest.Version, tt.wantLatest)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+// TestErrorResponses checks that error responses are plain JSON with a
+// machine-readable code, whatever the route and request headers.
+func TestErrorResponses(t *testing.T) {
+	s, _ := newTestServer(t, nil, testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"})
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		header     map[string]string
+		wantStatus int
+		wantCode   string
+	}{
+		{"unknown extension", http.MethodGet, "/_gallery/acme/missing/latest", "", nil, http.StatusNotFound, "extension_not_found"},
+		{"unknown version", http.MethodGet, "/_assets/acme/tool/9.9.9/Microsoft.VisualStudio.Services.VSIXPackage", "", nil, http.StatusNotFound, "version_not_found"},
+		{"unknown asset type", http.MethodGet, "/_assets/acme/tool/1.0.0/Unknown.Asset", "", nil, http.StatusNotFound, "asset_type_not_supported"},
+		{"unknown route", http.MethodGet, "/no/such/page", "", nil, http.StatusNotFound, "not_found"},
+		{
+			name: "api-version Accept header", method: http.MethodGet, target: "/_gallery/acme/missing/latest",
+			header:     map[string]string{"Accept": "application/json;api-version=3.0-preview.1"},
+			wantStatus: http.StatusNotFound, wantCode: "extension_not_found",
+		},
+		{"invalid query", http.MethodPost, "/_apis/public/gallery/extensionquery", "{", nil, http.StatusBadRequest, "invalid_json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, tt.method, tt.target, tt.body, tt.header)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := rec.Header().Get(contentTypeHeader); got != jsonContentType {
+				t.Errorf("Content-Type = %q, want %q", got, jsonContentType)
+			}
+			var body struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+				Status  int    `json:"status"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v\n%s", err, rec.Body)
+			}
+			if body.Code != tt.wantCode || body.Status != tt.wantStatus || body.Message == "" {
+				t.Errorf("body = %+v, want code %q and status %d", body, tt.wantCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// TestWriteErrorReplacesContentType checks that an error written after a
+// handler chose an api-version content type is still sent as plain JSON.
+func TestWriteErrorReplacesContentType(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	rec := httptest.NewRecorder()
+	rec.Header().Set(contentTypeHeader, "application/json; charset=utf-8; api-version=7.2-preview.1")
+	rec.Header().Set("Content-Length", "1234")
+
+	s.writeError(rec, http.StatusNotFound, "extension_not_found", "Extension not found")
+
+	if got := rec.Header().Get(contentTypeHeader); got != jsonContentType {
+		t.Errorf("Content-Type = %q, want %q", got, jsonContentType)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Errorf("stale Content-Length %q was kept", got)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"littlevsx/internal/testutil"
+	"littlevsx/internal/utils"
+)
+
+// TestExtensionIDIsStable checks that the extensionId clients get is the
+// UUIDv5 of the lowercased ID, is the same in every response, and finds the
+// extension again through an ExtensionId filter.
+func TestExtensionIDIsStable(t *testing.T) {
+	s, _ := newTestServer(t, nil, testutil.VSIX{Publisher: "Acme", Name: "Tool", Version: "1.0.0"})
+	want := utils.UUIDv5(utils.NamespaceLittleVSX, "extension:acme.tool")
+
+	for _, body := range []string{
+		`{"filters":[{"criteria":[{"filterType":7,"value":"Acme.Tool"}]}]}`,
+		`{"filters":[{"criteria":[{"filterType":10,"value":"tool"}]}]}`,
+		`{"filters":[{"criteria":[{"filterType":4,"value":"` + want + `"}]}]}`,
+		`{"filters":[{"criteria":[{"filterType":4,"value":"` + want + `"}]}]}`,
+	} {
+		response := query(t, s, body, nil)
+		if len(response.Results) != 1 || len(response.Results[0].Extensions) != 1 {
+			t.Fatalf("%s: got %+v, want one extension", body, response)
+		}
+		if got := response.Results[0].Extensions[0].ExtensionID; got != want {
+			t.Errorf("%s: extensionId = %s, want %s", body, got, want)
+		}
+	}
+}
+
+// TestPublisherIDMatchesManifest checks that gallery responses and the
+// enriched manifest report the same publisherId.
+func TestPublisherIDMatchesManifest(t *testing.T) {
+	s, _ := newTestServer(t, nil, testutil.VSIX{Publisher: "Acme", Name: "Tool", Version: "1.0.0"})
+	want := utils.PublisherUUID("acme")
+
+	response := query(t, s, `{"filters":[{"criteria":[{"filterType":7,"value":"Acme.Tool"}]}]}`, nil)
+	if len(response.Results[0].Extensions) != 1 {
+		t.Fatalf("got %d extensions, want 1", len(response.Results[0].Extensions))
+	}
+	if got := response.Results[0].Extensions[0].Publisher.PublisherID; got != want {
+		t.Errorf("gallery publisherId = %s, want %s", got, want)
+	}
+
+	rec := serve(s, http.MethodGet, "/_assets/Acme/Tool/1.0.0/Microsoft.VisualStudio.Code.Manifest", "", nil)
+	var manifest struct {
+		Metadata struct {
+			PublisherID string `json:"publisherId"`
+		} `json:"__metadata"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &manifest); err != nil {
+		t.Fatalf("manifest = %d %s: %v", rec.Code, rec.Body, err)
+	}
+	if manifest.Metadata.PublisherID != want {
+		t.Errorf("manifest publisherId = %s, want %s", manifest.Metadata.PublisherID, want)
+	}
+}
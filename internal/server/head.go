@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headMiddleware answers HEAD requests with the status and headers the
+// handler produces, without a body. Handlers that buffer their response
+// (JSON, generated manifests, READMEs) need no HEAD handling of their own:
+// what they write is discarded and counted for the Content-Length. Handlers
+// that stream from a package still check for HEAD so the entry is not read.
+func (s *Server) headMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		hw := &headResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(hw, r)
+		hw.finish()
+	})
+}
+
+// headResponseWriter holds back the status until the handler returns, so
+// the length of the discarded body can still be sent with it.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (hw *headResponseWriter) WriteHeader(status int) {
+	if hw.status == 0 {
+		hw.status = status
+	}
+}
+
+func (hw *headResponseWriter) Write(p []byte) (int, error) {
+	hw.WriteHeader(http.StatusOK)
+	hw.written += int64(len(p))
+	return len(p), nil
+}
+
+// Unwrap gives http.ResponseController access to the connection.
+func (hw *headResponseWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+func (hw *headResponseWriter) finish() {
+	hw.WriteHeader(http.StatusOK)
+	if hw.written > 0 && hw.Header().Get("Content-Length") == "" {
+		hw.Header().Set("Content-Length", strconv.FormatInt(hw.written, 10))
+	}
+	hw.ResponseWriter.WriteHeader(hw.status)
+}
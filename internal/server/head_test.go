@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/testutil"
+)
+
+// TestHead sends HEAD and GET to the gallery and asset routes and checks
+// that HEAD gets the same status and headers without a body.
+func TestHead(t *testing.T) {
+	s, _ := newTestServer(t, nil, testutil.VSIX{
+		Publisher: "acme", Name: "tool", Version: "1.0.0",
+		Readme: "# Tool", Icon: testutil.PNG,
+		Files: map[string][]byte{"extension/LICENSE.md": []byte("MIT License")},
+	})
+	assetsDir := filepath.Join(config.GetConfig().AssetsDir, "acme.tool")
+	if err := os.MkdirAll(assetsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(assetsDir, "shot.png"), testutil.PNG, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const assets = "/_assets/acme/tool/1.0.0/"
+	targets := []string{
+		assets + vsixPackageAssetType,
+		assets + "Microsoft.VisualStudio.Services.Icons.Default",
+		assets + "Microsoft.VisualStudio.Services.VsixManifest",
+		assets + "Microsoft.VisualStudio.Services.Content.License",
+		assets + "Microsoft.VisualStudio.Services.Content.Details",
+		assets + "Microsoft.VisualStudio.Code.Manifest",
+		"/_gallery/acme/tool/latest",
+		"/_gallery/acme/tool/1.0.0/package.json",
+		"/_assets/acme.tool/shot.png",
+		"/_assets/acme/tool/9.9.9/" + vsixPackageAssetType,
+	}
+
+	for _, target := range targets {
+		t.Run(target, func(t *testing.T) {
+			get := serve(s, http.MethodGet, target, "", nil)
+			head := serve(s, http.MethodHead, target, "", nil)
+
+			if head.Code != get.Code {
+				t.Errorf("HEAD = %d, GET = %d", head.Code, get.Code)
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD wrote a %d byte body", head.Body.Len())
+			}
+			for _, key := range []string{contentTypeHeader, "ETag", "Last-Modified", "Content-Disposition"} {
+				if got, want := head.Header().Get(key), get.Header().Get(key); got != want {
+					t.Errorf("HEAD %s = %q, GET has %q", key, got, want)
+				}
+			}
+			// net/http adds Content-Length to small GET responses itself.
+			if head.Header().Get("Content-Length") != strconv.Itoa(get.Body.Len()) {
+				t.Errorf("HEAD Content-Length = %q, GET body has %d bytes", head.Header().Get("Content-Length"), get.Body.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"littlevsx/internal/marketplace"
+)
+
+// handleHealth reports that the server is up. With health.check_upstream it
+// also pings the marketplaces of marketplace.order; an unreachable upstream
+// marks the status as degraded, since the mirror itself keeps serving.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	log.Printf("API: GET /_health - health request")
+	health := map[string]interface{}{
+		"status":     "ok",
+		"extensions": s.extManager.Count(),
+	}
+
+	st := s.settings.Load()
+	if st.healthCheckUpstream {
+		results := marketplace.NewFactory().Ping(r.Context(), st.upstreams, st.pingTimeout)
+		for _, result := range results {
+			if !result.Reachable {
+				health["status"] = "degraded"
+			}
+		}
+		health["upstreams"] = results
+	}
+
+	s.writeJSON(w, http.StatusOK, health)
+}
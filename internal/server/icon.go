@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"littlevsx/internal/models"
+)
+
+const (
+	placeholderIconSize  = 128
+	placeholderGlyphZoom = 8
+)
+
+// glyphs is a 5x7 bitmap font for the initials drawn on placeholder icons.
+var glyphs = map[rune][7]string{
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"####.", "#...#", "#...#", "#...#", "#...#", "#...#", "####."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I': {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L': {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q': {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S': {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U': {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z': {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'?': {".###.", "#...#", "....#", "...#.", "..#..", ".....", "..#.."},
+}
+
+// servePlaceholderIcon serves a PNG with the extension's initials for
+// extensions without a usable icon. Icons are generated once per extension.
+func (s *Server) servePlaceholderIcon(w http.ResponseWriter, r *http.Request, ext *models.Extension) {
+	initials := iconInitials(ext)
+	key := ext.Publisher + "|" + initials
+
+	icon, ok := s.placeholderIcons.Load(key)
+	if !ok {
+		generated, err := placeholderIcon(initials, ext.Publisher)
+		if err != nil {
+			log.Printf("API: Error generating placeholder icon: %v", err)
+			s.writeError(w, http.StatusInternalServerError, "icon_failed", "Failed to generate icon")
+			return
+		}
+		icon, _ = s.placeholderIcons.LoadOrStore(key, generated)
+	}
+
+	writeStream(w, r, bytes.NewReader(icon.([]byte)), int64(len(icon.([]byte))), "image/png")
+}
+
+// iconInitials returns up to two initials from the words of the extension's
+// display name, falling back to its name and then to "?".
+func iconInitials(ext *models.Extension) string {
+	for _, name := range []string{ext.DisplayName, ext.Name} {
+		words := strings.FieldsFunc(name, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		var initials []rune
+		for _, word := range words {
+			r := unicode.ToUpper([]rune(word)[0])
+			if _, ok := glyphs[r]; ok {
+				initials = append(initials, r)
+			}
+			if len(initials) == 2 {
+				break
+			}
+		}
+		if len(initials) > 0 {
+			return string(initials)
+		}
+	}
+	return "?"
+}
+
+// placeholderIcon draws initials in white on a background whose hue is
+// derived from the publisher, so extensions of one publisher share a color.
+func placeholderIcon(initials, publisher string) ([]byte, error) {
+	hash := fnv.New32a()
+	hash.Write([]byte(strings.ToLower(publisher)))
+	background := hslColor(float64(hash.Sum32()%360), 0.55, 0.45)
+
+	img := image.NewRGBA(image.Rect(0, 0, placeholderIconSize, placeholderIconSize))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = background.R, background.G, background.B, 255
+	}
+
+	const glyphWidth, glyphHeight, gap = 5 * placeholderGlyphZoom, 7 * placeholderGlyphZoom, placeholderGlyphZoom
+	letters := []rune(initials)
+	width := len(letters)*glyphWidth + (len(letters)-1)*gap
+	x0 := (placeholderIconSize - width) / 2
+	y0 := (placeholderIconSize - glyphHeight) / 2
+
+	white := color.RGBA{255, 255, 255, 255}
+	for i, letter := range letters {
+		left := x0 + i*(glyphWidth+gap)
+		for row, line := range glyphs[letter] {
+			for col, pixel := range line {
+				if pixel != '#' {
+					continue
+				}
+				for dy := 0; dy < placeholderGlyphZoom; dy++ {
+					for dx := 0; dx < placeholderGlyphZoom; dx++ {
+						img.SetRGBA(left+col*placeholderGlyphZoom+dx, y0+row*placeholderGlyphZoom+dy, white)
+					}
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func hslColor(hue, saturation, lightness float64) color.RGBA {
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	sector := hue / 60
+	x := chroma * (1 - math.Abs(math.Mod(sector, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case sector < 1:
+		r, g = chroma, x
+	case sector < 2:
+		r, g = x, chroma
+	case sector < 3:
+		g, b = chroma, x
+	case sector < 4:
+		g, b = x, chroma
+	case sector < 5:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+
+	m := lightness - chroma/2
+	return color.RGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 255}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+// TestIconContentType serves icons packaged under various names and checks
+// the content type: taken from the file extension when known, sniffed from
+// the content otherwise.
+func TestIconContentType(t *testing.T) {
+	const svg = `<svg xmlns="http://www.w3.org/2000/svg" width="1" height="1"/>`
+	gif := []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;")
+
+	tests := []struct {
+		name     string
+		icon     string
+		content  []byte
+		wantType string
+	}{
+		{"png", "icon.png", testutil.PNG, "image/png"},
+		{"svg", "media/icon.svg", []byte(svg), "image/svg+xml; charset=utf-8"},
+		{"svg with XML declaration", "media/Icon.SVG", []byte(`<?xml version="1.0"?>` + svg), "image/svg+xml; charset=utf-8"},
+		{"extensionless png", "media/icon", testutil.PNG, "image/png"},
+		{"extensionless svg", "media/icon", []byte(svg), "image/svg+xml; charset=utf-8"},
+		{"extensionless gif", "media/icon", gif, "image/gif"},
+		{"unknown extension", "media/icon.img", gif, "image/gif"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, nil, testutil.VSIX{
+				Publisher: "acme", Name: "tool", Version: "1.0.0",
+				PackageJSON: map[string]interface{}{"icon": tt.icon},
+				Files:       map[string][]byte{"extension/" + tt.icon: tt.content},
+			})
+
+			rec := serve(s, http.MethodGet, "/_assets/acme/tool/1.0.0/Microsoft.VisualStudio.Services.Icons.Default", "", nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(contentTypeHeader); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			// Sniffing must not consume the start of the streamed icon.
+			if !bytes.Equal(rec.Body.Bytes(), tt.content) {
+				t.Errorf("body = %q, want the packaged icon %q", rec.Body.Bytes(), tt.content)
+			}
+		})
+	}
+}
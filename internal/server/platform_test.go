@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+// TestAssetPrefersPlatformBuild requests the package of a version stored as
+// a universal and several platform builds and checks which file is served.
+func TestAssetPrefersPlatformBuild(t *testing.T) {
+	base := testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"}
+	s, m := newTestServer(t, nil)
+	files := make(map[string][]byte)
+	for _, v := range base.ForPlatforms("universal", "linux-x64", "win32-x64") {
+		content, err := os.ReadFile(addTestPackage(t, m, v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[v.TargetPlatform] = content
+	}
+	addTestPackage(t, m, testutil.VSIX{Publisher: "acme", Name: "tool", Version: "0.9.0", TargetPlatform: "darwin-arm64"})
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBuild  string
+		wantCode   string
+	}{
+		{"platform build", "/_assets/acme/tool/1.0.0/" + vsixPackageAssetType + "?targetPlatform=linux-x64", http.StatusOK, "linux-x64", ""},
+		{"other platform build", "/_assets/acme/tool/1.0.0/" + vsixPackageAssetType + "?targetPlatform=win32-x64", http.StatusOK, "win32-x64", ""},
+		{"universal fallback", "/_assets/acme/tool/1.0.0/" + vsixPackageAssetType + "?targetPlatform=darwin-arm64", http.StatusOK, "universal", ""},
+		{"no platform", "/_assets/acme/tool/1.0.0/" + vsixPackageAssetType, http.StatusOK, "universal", ""},
+		{"missing platform build", "/_assets/acme/tool/0.9.0/" + vsixPackageAssetType + "?targetPlatform=linux-x64", http.StatusNotFound, "", "platform_not_found"},
+		{"missing version", "/_assets/acme/tool/0.1.0/" + vsixPackageAssetType, http.StatusNotFound, "", "version_not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, http.MethodGet, tt.target, "", nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantBuild != "" && !bytes.Equal(rec.Body.Bytes(), files[tt.wantBuild]) {
+				t.Errorf("served %s, want the %s build", rec.Header().Get(contentDispositionHeader), tt.wantBuild)
+			}
+			if tt.wantCode != "" && !strings.Contains(rec.Body.String(), tt.wantCode) {
+				t.Errorf("body = %s, want error %s", rec.Body, tt.wantCode)
+			}
+		})
+	}
+}
+
+// TestQueryListsPlatformBuilds checks that every build of a version is
+// listed with its platform and that its files name that platform.
+func TestQueryListsPlatformBuilds(t *testing.T) {
+	base := testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"}
+	s, _ := newTestServer(t, nil, base.ForPlatforms("universal", "linux-x64")...)
+
+	response := query(t, s, `{"filters":[{"criteria":[{"filterType":7,"value":"acme.tool"}]}]}`, nil)
+	extensions := response.Results[0].Extensions
+	if len(extensions) != 1 {
+		t.Fatalf("got %d extensions, want 1", len(extensions))
+	}
+
+	platforms := make(map[string]bool)
+	for _, version := range extensions[0].Versions {
+		platforms[version.TargetPlatform] = true
+		for _, file := range version.Files {
+			hasQuery := strings.Contains(file.Source, "?targetPlatform="+version.TargetPlatform)
+			if hasQuery != (version.TargetPlatform != "universal") {
+				t.Errorf("%s build lists %s", version.TargetPlatform, file.Source)
+			}
+		}
+	}
+	if len(platforms) != 2 || !platforms["universal"] || !platforms["linux-x64"] {
+		t.Errorf("listed platforms %v, want universal and linux-x64", platforms)
+	}
+}
+
+// TestDefaultTargetPlatform covers clients that send no targetPlatform,
+// which get the universal build unless extensions.default_target_platform
+// names another one.
+func TestDefaultTargetPlatform(t *testing.T) {
+	tests := []struct {
+		name            string
+		defaultPlatform string
+		query           string
+		want            string
+	}{
+		{"universal by default", "", "", "universal"},
+		{"configured default", "linux-x64", "", "linux-x64"},
+		{"client platform beats default", "linux-x64", "?targetPlatform=win32-x64", "win32-x64"},
+		{"client asks for universal", "linux-x64", "?targetPlatform=universal", "universal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestServer(t, map[string]interface{}{"extensions.default_target_platform": tt.defaultPlatform})
+			base := testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"}
+			files := make(map[string][]byte)
+			for _, v := range base.ForPlatforms("universal", "linux-x64", "win32-x64") {
+				content, err := os.ReadFile(addTestPackage(t, m, v))
+				if err != nil {
+					t.Fatal(err)
+				}
+				files[v.TargetPlatform] = content
+			}
+
+			rec := serve(s, http.MethodGet, "/_assets/acme/tool/1.0.0/"+vsixPackageAssetType+tt.query, "", nil)
+			if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), files[tt.want]) {
+				t.Errorf("package = %d %s, want the %s build", rec.Code, rec.Header().Get(contentDispositionHeader), tt.want)
+			}
+
+			rec = serve(s, http.MethodGet, "/_gallery/acme/tool/latest"+tt.query, "", nil)
+			var latest struct {
+				TargetPlatform string `json:"targetPlatform"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &latest); err != nil {
+				t.Fatalf("latest = %d %s: %v", rec.Code, rec.Body, err)
+			}
+			if latest.TargetPlatform != tt.want {
+				t.Errorf("latest is the %s build, want %s", latest.TargetPlatform, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"littlevsx/internal/models"
+)
+
+// Filter types of the gallery extensionquery protocol supported by
+// handleExtensionQuery. Criteria with any other filterType are logged and
+// ignored, so an unknown code never narrows the result set.
+const (
+	filterTypeTag              = 1  // extension tag (keyword)
+	filterTypeExtensionID      = 4  // extension ID
+	filterTypeCategory         = 5  // category name
+	filterTypeExtensionName    = 7  // fully qualified "publisher.name"
+	filterTypeTarget           = 8  // installation target, e.g. Microsoft.VisualStudio.Code
+	filterTypeFeatured         = 9  // featured extensions only (no value)
+	filterTypeSearchText       = 10 // free-text search
+	filterTypeExcludeWithFlags = 12 // exclude extensions having any of these flags
+)
+
+const vscodeTarget = "Microsoft.VisualStudio.Code"
+
+// Sort criteria of the gallery extensionquery protocol, sent as sortBy in
+// the first filter. Unknown codes keep the relevance order.
+const (
+	sortByNoneOrRelevance = 0
+	sortByLastUpdatedDate = 1
+	sortByTitle           = 2
+	sortByPublisherName   = 3
+	sortByInstallCount    = 4
+	sortByAverageRating   = 6
+	sortByPublishedDate   = 10
+	sortByWeightedRating  = 12
+)
+
+// Sort orders sent as sortOrder. The default order is descending for
+// counts, ratings and dates and ascending for names.
+const (
+	sortOrderDefault    = 0
+	sortOrderAscending  = 1
+	sortOrderDescending = 2
+)
+
+// extensionFlagPreview excludes pre-release extensions when passed with
+// filterTypeExcludeWithFlags. Other flags (e.g. Unpublished) never apply to
+// locally hosted extensions.
+const extensionFlagPreview = 0x200
+
+// queryFlagLatestVersionOnly in the flags of a query asks for only the latest
+// version of each extension. VS Code sets it when searching and leaves it out
+// when it needs the older versions, e.g. for "Install Specific Version".
+const queryFlagLatestVersionOnly = 0x200
+
+// Page size of extensionquery results when the client does not send one, and
+// the upper bounds for the size and page number it may ask for, which keep
+// the offset of a page far from overflowing. Queries by extension ID are
+// bounded by the request itself and are never paged.
+const (
+	defaultQueryPageSize = 50
+	maxQueryPageSize     = 500
+	maxQueryPageNumber   = 100000
+)
+
+type extensionQuery struct {
+	searchText   string
+	extensionIDs []string
+	categories   []string
+	tags         []string
+	targets      []string
+	excludeFlags int
+	featured     bool
+	pageNumber   int
+	pageSize     int
+	sortBy       int
+	sortOrder    int
+	latestOnly   bool
+
+	// assetTypes limits the files listed for each version; empty lists all.
+	assetTypes []string
+
+	// clientVersion is the X-Client-Version of the request when
+	// api.filter_by_engine is on; the newest version of each extension
+	// whose engines.vscode range allows it is returned instead of the
+	// latest, and extensions without one are left out.
+	clientVersion string
+}
+
+func parseExtensionQuery(body map[string]interface{}) extensionQuery {
+	q := extensionQuery{pageNumber: 1, pageSize: defaultQueryPageSize}
+
+	if flags, ok := body["flags"].(float64); ok {
+		q.latestOnly = int(flags)&queryFlagLatestVersionOnly != 0
+	}
+
+	if assetTypes, ok := body["assetTypes"].([]interface{}); ok {
+		for _, assetType := range assetTypes {
+			if value, ok := assetType.(string); ok {
+				q.assetTypes = appendNonEmpty(q.assetTypes, value)
+			}
+		}
+	}
+
+	filters, ok := body["filters"].([]interface{})
+	if !ok || len(filters) == 0 {
+		return q
+	}
+
+	filter, ok := filters[0].(map[string]interface{})
+	if !ok {
+		return q
+	}
+
+	// The bounds are applied before converting, as converting a float
+	// beyond the range of int gives an arbitrary value.
+	if pageNumber, ok := filter["pageNumber"].(float64); ok && pageNumber >= 1 {
+		q.pageNumber = int(min(pageNumber, maxQueryPageNumber))
+	}
+	if pageSize, ok := filter["pageSize"].(float64); ok && pageSize >= 1 {
+		q.pageSize = int(min(pageSize, maxQueryPageSize))
+	}
+	if sortBy, ok := filter["sortBy"].(float64); ok {
+		switch int(sortBy) {
+		case sortByLastUpdatedDate, sortByTitle, sortByPublisherName, sortByInstallCount,
+			sortByAverageRating, sortByPublishedDate, sortByWeightedRating:
+			q.sortBy = int(sortBy)
+		}
+	}
+	if sortOrder, ok := filter["sortOrder"].(float64); ok {
+		switch int(sortOrder) {
+		case sortOrderAscending, sortOrderDescending:
+			q.sortOrder = int(sortOrder)
+		}
+	}
+
+	criteria, ok := filter["criteria"].([]interface{})
+	if !ok {
+		return q
+	}
+
+	for _, criterion := range criteria {
+		criterionMap, ok := criterion.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		filterType, ok := criterionMap["filterType"].(float64)
+		if !ok {
+			continue
+		}
+
+		value, _ := criterionMap["value"].(string)
+
+		switch int(filterType) {
+		case filterTypeTag:
+			q.tags = appendNonEmpty(q.tags, value)
+		case filterTypeExtensionID, filterTypeExtensionName:
+			q.extensionIDs = appendNonEmpty(q.extensionIDs, value)
+		case filterTypeCategory:
+			q.categories = appendNonEmpty(q.categories, value)
+		case filterTypeTarget:
+			q.targets = appendNonEmpty(q.targets, value)
+		case filterTypeFeatured:
+			q.featured = true
+		case filterTypeSearchText:
+			q.searchText = value
+		case filterTypeExcludeWithFlags:
+			if flags, err := strconv.Atoi(value); err == nil {
+				q.excludeFlags |= flags
+			}
+		default:
+			log.Printf("API: extensionquery - ignoring unsupported filterType %v (value: '%s')", filterType, value)
+		}
+	}
+
+	return q
+}
+
+func appendNonEmpty(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	return append(values, value)
+}
+
+// matches reports whether ext satisfies the category, tag, target, featured,
+// flag and engine criteria of the query. Search text and IDs are resolved by
+// the caller.
+func (q extensionQuery) matches(ext *models.Extension) bool {
+	if q.excludesVSCode() {
+		return false
+	}
+	if q.featured && !ext.Featured {
+		return false
+	}
+	for _, category := range q.categories {
+		if !containsFold(ext.Categories, category) {
+			return false
+		}
+	}
+	for _, tag := range q.tags {
+		if !containsFold(ext.Tags, tag) {
+			return false
+		}
+	}
+	if q.excludeFlags&extensionFlagPreview != 0 && ext.PreRelease {
+		return false
+	}
+	if q.clientVersion != "" && !engineAllows(ext, q.clientVersion) {
+		return false
+	}
+	return true
+}
+
+// filtersInMemory reports whether the query has criteria or a sort order
+// that are applied after loading extensions, which rules out paging in the
+// database.
+func (q extensionQuery) filtersInMemory() bool {
+	return q.sortBy != sortByNoneOrRelevance || len(q.categories) > 0 || len(q.tags) > 0 || q.featured ||
+		q.excludeFlags&extensionFlagPreview != 0 || q.excludesVSCode() || q.clientVersion != ""
+}
+
+// excludesVSCode reports whether the query targets only other products.
+func (q extensionQuery) excludesVSCode() bool {
+	return len(q.targets) > 0 && !containsFold(q.targets, vscodeTarget)
+}
+
+// sort orders exts by the requested criterion. Ties and queries without
+// sortBy keep the order exts are in, e.g. search relevance.
+func (q extensionQuery) sort(exts []*models.Extension) {
+	var compare func(a, b *models.Extension) int
+	descending := true
+	switch q.sortBy {
+	case sortByLastUpdatedDate:
+		compare = func(a, b *models.Extension) int { return a.LastUpdated.Compare(b.LastUpdated) }
+	case sortByPublishedDate:
+		compare = func(a, b *models.Extension) int { return a.PublishedDate.Compare(b.PublishedDate) }
+	case sortByInstallCount:
+		compare = func(a, b *models.Extension) int { return cmp.Compare(a.DownloadCount, b.DownloadCount) }
+	case sortByAverageRating, sortByWeightedRating:
+		compare = func(a, b *models.Extension) int {
+			if c := cmp.Compare(a.AverageRating, b.AverageRating); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.ReviewCount, b.ReviewCount)
+		}
+	case sortByTitle:
+		compare = func(a, b *models.Extension) int { return compareFold(displayTitle(a), displayTitle(b)) }
+		descending = false
+	case sortByPublisherName:
+		compare = func(a, b *models.Extension) int { return compareFold(a.Publisher, b.Publisher) }
+		descending = false
+	default:
+		return
+	}
+
+	switch q.sortOrder {
+	case sortOrderAscending:
+		descending = false
+	case sortOrderDescending:
+		descending = true
+	}
+	slices.SortStableFunc(exts, func(a, b *models.Extension) int {
+		if descending {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+}
+
+// displayTitle is the name clients show for ext.
+func displayTitle(ext *models.Extension) string {
+	if ext.DisplayName != "" {
+		return ext.DisplayName
+	}
+	return ext.Name
+}
+
+func compareFold(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// page returns the requested page of exts.
+func (q extensionQuery) page(exts []*models.Extension) []*models.Extension {
+	offset := (q.pageNumber - 1) * q.pageSize
+	if offset < 0 || offset >= len(exts) {
+		return nil
+	}
+	return exts[offset:min(offset+q.pageSize, len(exts))]
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
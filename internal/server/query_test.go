@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"littlevsx/internal/models"
+	"littlevsx/internal/testutil"
+	"littlevsx/internal/utils"
+)
+
+func TestParseExtensionQueryPaging(t *testing.T) {
+	tests := []struct {
+		name           string
+		filter         string
+		wantPageNumber int
+		wantPageSize   int
+	}{
+		{"defaults", `{}`, 1, defaultQueryPageSize},
+		{"requested page", `{"pageNumber":3,"pageSize":20}`, 3, 20},
+		{"largest size", `{"pageSize":500}`, 1, 500},
+		{"size capped", `{"pageSize":501}`, 1, maxQueryPageSize},
+		{"size far above cap", `{"pageSize":100000}`, 1, maxQueryPageSize},
+		{"zero ignored", `{"pageNumber":0,"pageSize":0}`, 1, defaultQueryPageSize},
+		{"negative ignored", `{"pageNumber":-4,"pageSize":-1}`, 1, defaultQueryPageSize},
+		{"huge page number", `{"pageNumber":1e19,"pageSize":50}`, maxQueryPageNumber, 50},
+		{"huge page size", `{"pageNumber":2,"pageSize":1e300}`, 2, maxQueryPageSize},
+		{"fractions truncated", `{"pageNumber":2.7,"pageSize":10.2}`, 2, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]interface{}
+			if err := json.Unmarshal([]byte(`{"filters":[`+tt.filter+`]}`), &body); err != nil {
+				t.Fatal(err)
+			}
+			q := parseExtensionQuery(body)
+			if q.pageNumber != tt.wantPageNumber || q.pageSize != tt.wantPageSize {
+				t.Errorf("page %d size %d, want page %d size %d", q.pageNumber, q.pageSize, tt.wantPageNumber, tt.wantPageSize)
+			}
+		})
+	}
+}
+
+func TestExtensionQueryPage(t *testing.T) {
+	exts := make([]*models.Extension, 5)
+	for i := range exts {
+		exts[i] = &models.Extension{ID: fmt.Sprintf("acme.tool%d", i)}
+	}
+
+	tests := []struct {
+		name       string
+		pageNumber int
+		pageSize   int
+		want       int
+	}{
+		{"first page", 1, 2, 2},
+		{"last partial page", 3, 2, 1},
+		{"past the end", 4, 2, 0},
+		{"overflowing offset", maxQueryPageNumber, int(^uint(0) >> 2), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := extensionQuery{pageNumber: tt.pageNumber, pageSize: tt.pageSize}
+			if got := q.page(exts); len(got) != tt.want {
+				t.Errorf("got %d extensions, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+// TestExtensionQueryPages fills more than one page and checks that the
+// filterless, search and in-memory paths all page it and report the total.
+func TestExtensionQueryPages(t *testing.T) {
+	const count = 7
+	packages := make([]testutil.VSIX, count)
+	for i := range packages {
+		packages[i] = testutil.VSIX{Publisher: "acme", Name: fmt.Sprintf("tool%d", i), Version: "1.0.0"}
+	}
+	s, _ := newTestServer(t, map[string]interface{}{"cache.query_enabled": false}, packages...)
+
+	paths := []struct {
+		name      string
+		criterion string
+		sortBy    int
+	}{
+		{"filterless", ``, sortByNoneOrRelevance},
+		{"search", `{"filterType":10,"value":"tool"}`, sortByNoneOrRelevance},
+		{"sorted", `{"filterType":8,"value":"Microsoft.VisualStudio.Code"}`, sortByTitle},
+	}
+	tests := []struct {
+		pageNumber string
+		pageSize   int
+		want       int
+	}{
+		{"1", 3, 3},
+		{"2", 3, 3},
+		{"3", 3, 1},
+		{"4", 3, 0},
+		{"1e19", 3, 0},
+		{"-1e19", 3, 3},
+	}
+
+	for _, path := range paths {
+		name, seen := path.name, make(map[string]bool)
+		for _, tt := range tests {
+			body := fmt.Sprintf(`{"filters":[{"criteria":[%s],"pageNumber":%s,"pageSize":%d,"sortBy":%d}]}`,
+				path.criterion, tt.pageNumber, tt.pageSize, path.sortBy)
+			result := query(t, s, body, nil).Results[0]
+			if len(result.Extensions) != tt.want {
+				t.Errorf("%s page %s: got %d extensions, want %d", name, tt.pageNumber, len(result.Extensions), tt.want)
+			}
+			if total := result.ResultMetadata[0].MetadataItems[0].Count; total != count {
+				t.Errorf("%s page %s: TotalCount = %d, want %d", name, tt.pageNumber, total, count)
+			}
+			if tt.pageNumber != "-1e19" {
+				for _, ext := range result.Extensions {
+					if seen[ext.ExtensionName] {
+						t.Errorf("%s: %s is on more than one page", name, ext.ExtensionName)
+					}
+					seen[ext.ExtensionName] = true
+				}
+			}
+		}
+		if len(seen) != count {
+			t.Errorf("%s: pages held %d extensions, want %d", name, len(seen), count)
+		}
+	}
+
+	rec := serve(s, http.MethodPost, "/_apis/public/gallery/extensionquery", `{"filters":[{"pageNumber":1e308,"pageSize":1e308}]}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Errorf("huge paging answered %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// TestExtensionQueryVSCodeBodies posts the bodies VS Code sends when
+// searching and when looking extensions up by name or UUID. A stray
+// top-level "query" field must not override the criteria.
+func TestExtensionQueryVSCodeBodies(t *testing.T) {
+	s, _ := newTestServer(t, nil,
+		testutil.VSIX{Publisher: "acme", Name: "linter", Version: "1.0.0", DisplayName: "Linter"},
+		testutil.VSIX{Publisher: "acme", Name: "formatter", Version: "1.0.0", DisplayName: "Formatter"},
+	)
+	header := map[string]string{"X-Market-Client-Id": "VSCode 1.95.3", "User-Agent": "VSCode/1.95.3 (Code)"}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "search",
+			body: `{"filters":[{"criteria":[{"filterType":8,"value":"Microsoft.VisualStudio.Code"},{"filterType":10,"value":"linter"},{"filterType":12,"value":"4096"}],"pageNumber":1,"pageSize":50,"sortBy":0,"sortOrder":0}],"assetTypes":[],"flags":950}`,
+			want: "linter",
+		},
+		{
+			name: "lookup by name",
+			body: `{"filters":[{"criteria":[{"filterType":7,"value":"acme.formatter"},{"filterType":8,"value":"Microsoft.VisualStudio.Code"},{"filterType":12,"value":"4096"}],"pageNumber":1,"pageSize":1,"sortBy":0,"sortOrder":0}],"assetTypes":[],"flags":950}`,
+			want: "formatter",
+		},
+		{
+			name: "lookup by UUID",
+			body: `{"filters":[{"criteria":[{"filterType":4,"value":"` + utils.ExtensionUUID("", "acme.linter") + `"},{"filterType":8,"value":"Microsoft.VisualStudio.Code"},{"filterType":12,"value":"4096"}],"pageNumber":1,"pageSize":1,"sortBy":0,"sortOrder":0}],"assetTypes":[],"flags":950}`,
+			want: "linter",
+		},
+		{
+			name: "stray query field",
+			body: `{"query":"formatter","filters":[{"criteria":[{"filterType":8,"value":"Microsoft.VisualStudio.Code"},{"filterType":10,"value":"linter"}],"pageNumber":1,"pageSize":50,"sortBy":0,"sortOrder":0}],"assetTypes":[],"flags":950}`,
+			want: "linter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extensions := query(t, s, tt.body, header).Results[0].Extensions
+			if len(extensions) != 1 || extensions[0].ExtensionName != tt.want {
+				names := make([]string, len(extensions))
+				for i, ext := range extensions {
+					names[i] = ext.ExtensionName
+				}
+				t.Fatalf("got %v, want [%s]", names, tt.want)
+			}
+			// Flags 950 include IncludeLatestVersionOnly and IncludeFiles.
+			if versions := extensions[0].Versions; len(versions) != 1 || len(versions[0].Files) == 0 {
+				t.Errorf("got %d versions, want the latest one with its files", len(versions))
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxQueryCacheEntries bounds the memory used by distinct queries, e.g. when
+// clients search for many different terms.
+const maxQueryCacheEntries = 1000
+
+// queryCache keeps serialized extensionquery responses. Entries expire after
+// the TTL and are all dropped when the database fingerprint changes, which
+// also covers changes made by CLI commands running in another process.
+type queryCache struct {
+	ttl time.Duration
+
+	mu          sync.Mutex
+	fingerprint string
+	entries     map[string]queryCacheEntry
+}
+
+type queryCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+func newQueryCache(ttl time.Duration) *queryCache {
+	return &queryCache{
+		ttl:     ttl,
+		entries: make(map[string]queryCacheEntry),
+	}
+}
+
+func (c *queryCache) get(key, fingerprint string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if fingerprint == "" || fingerprint != c.fingerprint {
+		return nil, false
+	}
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (c *queryCache) put(key, fingerprint string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if fingerprint != c.fingerprint {
+		c.fingerprint = fingerprint
+		c.entries = make(map[string]queryCacheEntry)
+	}
+
+	if len(c.entries) >= maxQueryCacheEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxQueryCacheEntries {
+			c.entries = make(map[string]queryCacheEntry)
+		}
+	}
+
+	c.entries[key] = queryCacheEntry{body: body, expires: now.Add(c.ttl)}
+}
+
+// cacheKey returns the same key for queries that produce the same response,
+// regardless of the order of categories, tags and targets and the case of
+// IDs, categories and tags. Extension IDs keep their order, since results
+// are listed in request order. Lists are quoted so that values containing
+// the separator cannot make two queries share a key.
+func (q extensionQuery) cacheKey() string {
+	return fmt.Sprintf("ids=%s|text=%q|categories=%s|tags=%s|targets=%s|flags=%d|featured=%t|page=%d/%d|sort=%d/%d|assets=%s|client=%q|latest=%t",
+		normalizedList(q.extensionIDs, false), q.searchText, normalizedList(q.categories, true),
+		normalizedList(q.tags, true), normalizedList(q.targets, true), q.excludeFlags&extensionFlagPreview,
+		q.featured, q.pageNumber, q.pageSize, q.sortBy, q.sortOrder, normalizedList(q.assetTypes, true), q.clientVersion, q.latestOnly)
+}
+
+// normalizedList lower-cases values, sorts them unless their order matters,
+// and quotes each one.
+func normalizedList(values []string, sorted bool) string {
+	normalized := make([]string, len(values))
+	for i, value := range values {
+		normalized[i] = strings.ToLower(value)
+	}
+	if sorted {
+		sort.Strings(normalized)
+	}
+	return fmt.Sprintf("%q", normalized)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+func TestQueryCacheKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b extensionQuery
+		same bool
+	}{
+		{
+			name: "ID case",
+			a:    extensionQuery{extensionIDs: []string{"Acme.Tool"}},
+			b:    extensionQuery{extensionIDs: []string{"acme.tool"}},
+			same: true,
+		},
+		{
+			name: "ID order",
+			a:    extensionQuery{extensionIDs: []string{"acme.a", "acme.b"}},
+			b:    extensionQuery{extensionIDs: []string{"acme.b", "acme.a"}},
+		},
+		{
+			name: "category order",
+			a:    extensionQuery{categories: []string{"Linters", "Themes"}},
+			b:    extensionQuery{categories: []string{"themes", "linters"}},
+			same: true,
+		},
+		{
+			name: "tag order",
+			a:    extensionQuery{tags: []string{"go", "lint"}},
+			b:    extensionQuery{tags: []string{"lint", "go"}},
+			same: true,
+		},
+		{
+			name: "separator inside a value",
+			a:    extensionQuery{extensionIDs: []string{"acme.a,acme.b"}},
+			b:    extensionQuery{extensionIDs: []string{"acme.a", "acme.b"}},
+		},
+		{
+			name: "separator inside a tag",
+			a:    extensionQuery{tags: []string{"a,b"}},
+			b:    extensionQuery{tags: []string{"a", "b"}},
+		},
+		{
+			name: "field separator in search text",
+			a:    extensionQuery{searchText: "x|categories=[]"},
+			b:    extensionQuery{searchText: "x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.a.cacheKey() == tt.b.cacheKey(); same != tt.same {
+				t.Errorf("keys %q and %q: same = %v, want %v", tt.a.cacheKey(), tt.b.cacheKey(), same, tt.same)
+			}
+		})
+	}
+}
+
+// TestQueryCacheKeepsIDOrder looks up the same extensions in two orders
+// with the query cache on; each response lists them in request order.
+func TestQueryCacheKeepsIDOrder(t *testing.T) {
+	s, _ := newTestServer(t, map[string]interface{}{"cache.query_enabled": true},
+		testutil.VSIX{Publisher: "acme", Name: "alpha", Version: "1.0.0"},
+		testutil.VSIX{Publisher: "acme", Name: "beta", Version: "1.0.0"},
+	)
+
+	for _, ids := range [][2]string{{"alpha", "beta"}, {"beta", "alpha"}, {"Beta", "ALPHA"}} {
+		body := `{"filters":[{"criteria":[{"filterType":7,"value":"acme.` + ids[0] + `"},{"filterType":7,"value":"acme.` + ids[1] + `"}]}]}`
+		extensions := query(t, s, body, nil).Results[0].Extensions
+		if len(extensions) != 2 {
+			t.Fatalf("%v: got %d extensions, want 2", ids, len(extensions))
+		}
+		for i, ext := range extensions {
+			if want := strings.ToLower(ids[i]); ext.ExtensionName != want {
+				t.Errorf("%v: extension %d is %s, want %s", ids, i, ext.ExtensionName, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+func TestPrefersHTML(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/markdown", false},
+		{"text/html", true},
+		{"text/html, */*", false},
+		{"text/html, */*;q=0.8", true},
+		{"text/html;q=0.5, text/markdown", false},
+		{"text/markdown;q=0.5, text/html", true},
+		{"TEXT/HTML", true},
+		{"text/html;q=0", false},
+		{"text/*;q=0.9, text/html", true},
+		{"application/json", false},
+	}
+	for _, tt := range tests {
+		if got := prefersHTML(tt.accept); got != tt.want {
+			t.Errorf("prefersHTML(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+// TestDetailsAssetNegotiation requests the README details asset with
+// different Accept headers and checks that HTML is rendered, and
+// sanitized, only when the client asks for it.
+func TestDetailsAssetNegotiation(t *testing.T) {
+	s, _ := newTestServer(t, nil, testutil.VSIX{
+		Publisher: "acme", Name: "tool", Version: "1.0.0",
+		Readme: "# Tool\n\n<script>alert(1)</script>\n\nSee **docs**.",
+	})
+	const target = "/_assets/acme/tool/1.0.0/Microsoft.VisualStudio.Services.Content.Details"
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{"no Accept header", "", markdownContentType, "# Tool"},
+		{"wildcard", "*/*", markdownContentType, "# Tool"},
+		{"markdown", "text/markdown", markdownContentType, "See **docs**."},
+		{"html", "text/html", htmlContentType, "<strong>docs</strong>"},
+		{"browser", "text/html,application/xhtml+xml,*/*;q=0.8", htmlContentType, "<h1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{}
+			if tt.accept != "" {
+				header["Accept"] = tt.accept
+			}
+			rec := serve(s, http.MethodGet, target, "", header)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(contentTypeHeader); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Accept") {
+				t.Errorf("Vary = %q, want it to list Accept", vary)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body does not contain %q:\n%s", tt.wantBody, body)
+			}
+			if tt.wantContentType == htmlContentType && strings.Contains(body, "<script") {
+				t.Errorf("rendered README keeps a script:\n%s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRecoveryMiddleware checks that a panicking handler is answered with
+// a 500 JSON error naming the request ID and that the server keeps serving.
+func TestRecoveryMiddleware(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	s.router.HandleFunc("/_test/panic", func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["boom"]++
+	})
+
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{"generated request ID", ""},
+		{"client request ID", "client-42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{}
+			if tt.requestID != "" {
+				header[requestIDHeader] = tt.requestID
+			}
+			rec := serve(s, http.MethodGet, "/_test/panic", "", header)
+			if rec.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want 500: %s", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(contentTypeHeader); got != jsonContentType {
+				t.Errorf("Content-Type = %q, want %q", got, jsonContentType)
+			}
+
+			requestID := rec.Header().Get(requestIDHeader)
+			if requestID == "" || (tt.requestID != "" && requestID != tt.requestID) {
+				t.Errorf("%s = %q, want %q or a generated one", requestIDHeader, requestID, tt.requestID)
+			}
+			var body struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v\n%s", err, rec.Body)
+			}
+			if body.Code != "internal_error" || !strings.Contains(body.Message, requestID) {
+				t.Errorf("body = %+v, want internal_error naming request %s", body, requestID)
+			}
+
+			if rec := serve(s, http.MethodGet, "/_stats", "", nil); rec.Code != http.StatusOK {
+				t.Errorf("after the panic /_stats answered %d", rec.Code)
+			}
+		})
+	}
+}
+
+// TestRecoveryMiddlewareAbort checks that http.ErrAbortHandler is passed on
+// to net/http, which uses it to drop the connection quietly.
+func TestRecoveryMiddlewareAbort(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	handler := s.recoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", recovered)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	t.Error("ErrAbortHandler was swallowed")
+}
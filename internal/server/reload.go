@@ -0,0 +1,204 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"littlevsx/internal/config"
+	"littlevsx/internal/marketplace"
+	"littlevsx/internal/storage"
+)
+
+// settings holds the configuration read while handling requests. It is
+// replaced as a whole on reload, so handlers always see a consistent set.
+type settings struct {
+	requestTimeout     time.Duration
+	compressionEnabled bool
+	brotliEnabled      bool
+
+	// queryCache is nil when cache.query_enabled is off.
+	queryCache *queryCache
+
+	// upstreams are pinged by /_health when health.check_upstream is on.
+	healthCheckUpstream bool
+	upstreams           []marketplace.MarketplaceType
+	pingTimeout         time.Duration
+
+	// trackUsers records distinct X-Market-User-Id values per download.
+	trackUsers bool
+
+	// signaturesEnabled lists and serves the .sigzip next to packages.
+	signaturesEnabled bool
+
+	// filterByEngine serves the newest version the client's
+	// X-Client-Version can install instead of the latest.
+	filterByEngine bool
+
+	// assetPaths maps lower-cased asset types without a dedicated handler
+	// to the archive paths tried for them.
+	assetPaths map[string][]string
+	// stubAssetTypes are the lower-cased assets.stub_types.
+	stubAssetTypes map[string]bool
+}
+
+// newSettings builds settings from cfg, keeping the query cache of previous
+// when its TTL is unchanged so a reload does not drop cached responses. The
+// cache is dropped when signatures.enabled changes, as it changes the files
+// listed in responses.
+func newSettings(cfg config.Config, previous *settings) *settings {
+	st := &settings{
+		requestTimeout:     time.Duration(cfg.RequestTimeout) * time.Second,
+		compressionEnabled: cfg.CompressionEnabled,
+		brotliEnabled:      cfg.BrotliEnabled,
+
+		healthCheckUpstream: cfg.HealthCheckUpstream,
+		pingTimeout:         time.Duration(cfg.MarketplacePingTimeout) * time.Second,
+
+		trackUsers:        cfg.TrackUsers,
+		filterByEngine:    cfg.FilterByEngine,
+		signaturesEnabled: cfg.SignaturesEnabled,
+	}
+	for _, name := range cfg.MarketplaceOrder {
+		st.upstreams = append(st.upstreams, marketplace.MarketplaceType(name))
+	}
+
+	var invalid []string
+	st.assetPaths, invalid = cfg.AssetPaths()
+	for _, entry := range invalid {
+		log.Printf("Config: ignoring assets.type_paths entry %q, expected AssetType=path", entry)
+	}
+
+	st.stubAssetTypes = make(map[string]bool, len(cfg.AssetStubTypes))
+	for _, assetType := range cfg.AssetStubTypes {
+		st.stubAssetTypes[strings.ToLower(strings.TrimSpace(assetType))] = true
+	}
+
+	if cfg.QueryCacheEnabled && cfg.QueryCacheTTL > 0 {
+		ttl := time.Duration(cfg.QueryCacheTTL) * time.Second
+		if previous != nil && previous.queryCache != nil && previous.queryCache.ttl == ttl &&
+			previous.signaturesEnabled == st.signaturesEnabled {
+			st.queryCache = previous.queryCache
+		} else {
+			st.queryCache = newQueryCache(ttl)
+		}
+	}
+	return st
+}
+
+type configChange struct {
+	key      string
+	old, new interface{}
+}
+
+// secretChange is a configChange for a credential, whose values are not
+// logged.
+func secretChange(key, old, new string) configChange {
+	if old == new {
+		return configChange{key, "", ""}
+	}
+	return configChange{key, "(hidden)", "(changed)"}
+}
+
+// reloadStorageSecrets re-reads the secrets the package storage reads from
+// files on config reload.
+func (s *Server) reloadStorageSecrets() {
+	reloader, ok := s.extManager.Storage().(storage.Reloader)
+	if !ok {
+		return
+	}
+	reloaded, err := reloader.Reload()
+	if err != nil {
+		log.Printf("Config reload: %v, keeping current storage credentials", err)
+		return
+	}
+	if reloaded {
+		log.Printf("Config reload: storage credentials reloaded from storage.s3.secret_key_file")
+	}
+}
+
+// Reload applies the settings of cfg that can change without restarting the
+// listener and logs every change. Changed settings that are only read at
+// startup are logged with a note that a restart is required. The TLS
+// certificate and storage.s3.secret_key_file are re-read from their files,
+// so rotated secrets are picked up.
+func (s *Server) Reload(cfg config.Config) {
+	old := s.cfg
+	s.reloadCertificate()
+	s.reloadStorageSecrets()
+
+	reloadable := []configChange{
+		{"server.request_timeout", old.RequestTimeout, cfg.RequestTimeout},
+		{"compression.enabled", old.CompressionEnabled, cfg.CompressionEnabled},
+		{"compression.brotli", old.BrotliEnabled, cfg.BrotliEnabled},
+		{"cache.query_enabled", old.QueryCacheEnabled, cfg.QueryCacheEnabled},
+		{"cache.query_ttl", old.QueryCacheTTL, cfg.QueryCacheTTL},
+		{"marketplace.order", old.MarketplaceOrder, cfg.MarketplaceOrder},
+		{"marketplace.ping_timeout", old.MarketplacePingTimeout, cfg.MarketplacePingTimeout},
+		{"health.check_upstream", old.HealthCheckUpstream, cfg.HealthCheckUpstream},
+		{"assets.type_paths", old.AssetTypePaths, cfg.AssetTypePaths},
+		{"assets.stub_types", old.AssetStubTypes, cfg.AssetStubTypes},
+		{"statistics.track_users", old.TrackUsers, cfg.TrackUsers},
+		{"api.filter_by_engine", old.FilterByEngine, cfg.FilterByEngine},
+		{"signatures.enabled", old.SignaturesEnabled, cfg.SignaturesEnabled},
+	}
+	restartRequired := []configChange{
+		{"server.host", old.Host, cfg.Host},
+		{"server.port", old.Port, cfg.Port},
+		{"server.unix_socket", old.UnixSocket, cfg.UnixSocket},
+		{"server.trailing_slash", old.TrailingSlash, cfg.TrailingSlash},
+		{"server.unix_socket_mode", old.UnixSocketMode, cfg.UnixSocketMode},
+		{"server.https", old.UseHTTPS, cfg.UseHTTPS},
+		{"server.cert_file", old.CertFile, cfg.CertFile},
+		{"server.key_file", old.KeyFile, cfg.KeyFile},
+		{"server.base_url", old.BaseURL, cfg.BaseURL},
+		{"database.path", old.DBPath, cfg.DBPath},
+		{"extensions.directory", old.ExtensionsDir, cfg.ExtensionsDir},
+		{"extensions.default_target_platform", old.DefaultTargetPlatform, cfg.DefaultTargetPlatform},
+		{"storage.backend", old.StorageBackend, cfg.StorageBackend},
+		{"storage.s3.endpoint", old.S3Endpoint, cfg.S3Endpoint},
+		{"storage.s3.region", old.S3Region, cfg.S3Region},
+		{"storage.s3.bucket", old.S3Bucket, cfg.S3Bucket},
+		{"storage.s3.prefix", old.S3Prefix, cfg.S3Prefix},
+		{"storage.s3.path_style", old.S3PathStyle, cfg.S3PathStyle},
+		{"storage.s3.secret_key_file", old.S3SecretKeyFile, cfg.S3SecretKeyFile},
+		secretChange("storage.s3.access_key", old.S3AccessKey, cfg.S3AccessKey),
+		secretChange("storage.s3.secret_key", old.S3SecretKey, cfg.S3SecretKey),
+		{"assets.directory", old.AssetsDir, cfg.AssetsDir},
+		{"assets.url_prefix", old.AssetsURLPrefix, cfg.AssetsURLPrefix},
+		{"logging.file", old.LogFile, cfg.LogFile},
+		{"debug.enabled", old.DebugEnabled, cfg.DebugEnabled},
+		{"search.max_results", old.SearchMaxResults, cfg.SearchMaxResults},
+		{"cache.extract_max_mb", old.ExtractCacheMaxMB, cfg.ExtractCacheMaxMB},
+	}
+
+	changed := 0
+	for _, c := range reloadable {
+		if fmt.Sprint(c.old) != fmt.Sprint(c.new) {
+			log.Printf("Config reload: %s changed from %v to %v", c.key, c.old, c.new)
+			changed++
+		}
+	}
+	for _, c := range restartRequired {
+		if fmt.Sprint(c.old) != fmt.Sprint(c.new) {
+			log.Printf("Config reload: %s changed from %v to %v, restart required to apply", c.key, c.old, c.new)
+		}
+	}
+
+	// Settings that need a restart keep their running values, so the next
+	// reload reports them again until the server is restarted.
+	applied := cfg
+	applied.Host, applied.Port, applied.UseHTTPS = old.Host, old.Port, old.UseHTTPS
+	applied.CertFile, applied.KeyFile, applied.BaseURL = old.CertFile, old.KeyFile, old.BaseURL
+	applied.DBPath, applied.ExtensionsDir, applied.AssetsDir = old.DBPath, old.ExtensionsDir, old.AssetsDir
+	applied.LogFile, applied.DebugEnabled = old.LogFile, old.DebugEnabled
+	applied.SearchMaxResults, applied.AssetsURLPrefix = old.SearchMaxResults, old.AssetsURLPrefix
+
+	s.cfg = applied
+	s.settings.Store(newSettings(applied, s.settings.Load()))
+
+	if changed == 0 {
+		log.Printf("Config reload: no runtime settings changed")
+	}
+}
@@ -1,19 +1,29 @@
 package server
 
 import (
-	"archive/zip"
+	"bufio"
 	"context"
-	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"littlevsx/internal/config"
 	"littlevsx/internal/extensions"
 	"littlevsx/internal/models"
 	"littlevsx/internal/utils"
@@ -24,16 +34,42 @@ import (
 const (
 	contentTypeHeader        = "Content-Type"
 	contentDispositionHeader = "Content-Disposition"
+	requestIDHeader          = "X-Request-Id"
+	clientVersionHeader      = "X-Client-Version"
 	cacheControlHeader       = "Cache-Control"
 
 	jsonContentType        = "application/json"
 	xmlContentType         = "application/xml"
 	markdownContentType    = "text/markdown"
+	htmlContentType        = "text/html; charset=utf-8"
 	octetStreamContentType = "application/octet-stream"
+	zipContentType         = "application/zip"
+
+	galleryTimeLayout = "2006-01-02T15:04:05.000Z"
 
-	packageJSONPath  = "extension/package.json"
 	vsixManifestPath = "extension.vsixmanifest"
-	readmePaths      = "extension/README.md"
+	packageJSONPath  = "extension/package.json"
+
+	emptyCatalogMessage = "No extensions present — run `littlevsx download ...` to populate"
+
+	universalPlatform = "universal"
+
+	vsixPackageAssetType   = "Microsoft.VisualStudio.Services.VSIXPackage"
+	vsixSignatureAssetType = "Microsoft.VisualStudio.Services.VsixSignature"
+
+	// rawManifestAssetType serves the package.json exactly as packaged, while
+	// Microsoft.VisualStudio.Code.Manifest serves it merged with gallery data.
+	rawManifestAssetType = "LittleVSX.Code.Manifest.Raw"
+
+	// sourcePropertyKey is the version property naming the marketplace a
+	// version was downloaded from.
+	sourcePropertyKey = "LittleVSX.Source"
+
+	// server.trailing_slash modes: redirect "/_stats/" to "/_stats", serve
+	// both forms, or answer 404 for the form that is not registered.
+	trailingSlashRedirect = "redirect"
+	trailingSlashIgnore   = "ignore"
+	trailingSlashStrict   = "strict"
 )
 
 type Server struct {
@@ -44,6 +80,28 @@ type Server struct {
 	certFile   string
 	keyFile    string
 	baseURL    string
+
+	// certificates is set while serving HTTPS, see certificateStore.
+	certificates *certificateStore
+
+	debugEnabled bool
+
+	// fileRoutes holds the path templates of the routes serving packages
+	// and assets, which are exempt from server.request_timeout.
+	fileRoutes map[string]bool
+
+	// cfg is the configuration last applied, settings the part of it that
+	// can change at runtime (see Reload).
+	cfg      config.Config
+	settings atomic.Pointer[settings]
+
+	// placeholderIcons caches generated icons, see servePlaceholderIcon.
+	placeholderIcons sync.Map
+
+	catalogMu          sync.Mutex
+	catalogFingerprint string
+	catalogETag        string
+	catalogBody        []byte
 }
 
 func New(extManager *extensions.Manager, baseURL string) *Server {
@@ -53,6 +111,7 @@ func New(extManager *extensions.Manager, baseURL string) *Server {
 		useHTTPS:   false,
 		baseURL:    baseURL,
 	}
+	s.applyConfig(config.GetConfig())
 	s.setupRoutes()
 	return s
 }
@@ -66,49 +125,195 @@ func NewWithHTTPS(extManager *extensions.Manager, certFile, keyFile string, base
 		keyFile:    keyFile,
 		baseURL:    baseURL,
 	}
+	s.applyConfig(config.GetConfig())
 	s.setupRoutes()
 	return s
 }
 
+func (s *Server) applyConfig(cfg config.Config) {
+	s.cfg = cfg
+	s.debugEnabled = cfg.DebugEnabled
+	s.settings.Store(newSettings(cfg, nil))
+}
+
+// Router returns the handler serving all routes. server.trailing_slash
+// decides what happens to a path with an extra trailing slash: with ignore
+// the slash is removed before routing, with redirect the client is sent to
+// the path without it when a route serves that path.
 func (s *Server) Router() http.Handler {
-	return s.router
+	if s.cfg.TrailingSlash == trailingSlashStrict {
+		return s.router
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Path) <= 1 || !strings.HasSuffix(r.URL.Path, "/") {
+			s.router.ServeHTTP(w, r)
+			return
+		}
+		path := strings.TrimRight(r.URL.Path, "/")
+		if path == "" {
+			path = "/"
+		}
+
+		target := *r.URL
+		target.Path, target.RawPath = path, ""
+		if s.cfg.TrailingSlash == trailingSlashIgnore {
+			r.URL = &target
+			s.router.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflights are answered for every path, and browsers do not
+		// follow redirects for them.
+		probe := r.Clone(r.Context())
+		probe.URL = &target
+		var match mux.RouteMatch
+		if r.Method == http.MethodOptions || !s.router.Match(probe, &match) || match.MatchErr != nil {
+			s.router.ServeHTTP(w, r)
+			return
+		}
+		// 308 keeps the method and body of POST requests, which clients
+		// resend as GET after a 301.
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		s.setCORSHeaders(w)
+		http.Redirect(w, r, target.String(), status)
+	})
 }
 
+// ListenAndServe serves on addr, or on the Unix socket configured with
+// server.unix_socket instead when one is set.
 func (s *Server) ListenAndServe(addr string) error {
 	s.server = &http.Server{
 		Addr:    addr,
-		Handler: s.router,
+		Handler: s.Router(),
+	}
+
+	if s.useHTTPS {
+		certificates, err := newCertificateStore(s.certFile, s.keyFile)
+		if err != nil {
+			return err
+		}
+		s.certificates = certificates
+		s.server.TLSConfig = &tls.Config{GetCertificate: certificates.getCertificate}
+	}
+
+	if s.cfg.UnixSocket == "" {
+		if s.useHTTPS {
+			log.Printf("Starting HTTPS server on %s", addr)
+			return s.server.ListenAndServeTLS("", "")
+		}
+		log.Printf("Starting HTTP server on %s", addr)
+		return s.server.ListenAndServe()
+	}
+
+	listener, err := listenUnix(s.cfg.UnixSocket, s.cfg.UnixSocketMode)
+	if err != nil {
+		return err
 	}
 
 	if s.useHTTPS {
-		log.Printf("Starting HTTPS server on %s", addr)
-		return s.server.ListenAndServeTLS(s.certFile, s.keyFile)
+		log.Printf("Starting HTTPS server on unix socket %s", s.cfg.UnixSocket)
+		return s.server.ServeTLS(listener, "", "")
 	}
-	log.Printf("Starting HTTP server on %s", addr)
-	return s.server.ListenAndServe()
+	log.Printf("Starting HTTP server on unix socket %s", s.cfg.UnixSocket)
+	return s.server.Serve(listener)
+}
+
+// listenUnix creates the socket at path with the given octal permissions. A
+// socket left behind by a previous run is replaced, any other file is not.
+func listenUnix(path, mode string) (net.Listener, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server.unix_socket_mode %q: %w", mode, err)
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	return listener, nil
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
-	if s.server != nil {
-		return s.server.Shutdown(ctx)
+	if s.server == nil {
+		return nil
 	}
-	return nil
+	err := s.server.Shutdown(ctx)
+	if s.cfg.UnixSocket != "" {
+		if removeErr := os.Remove(s.cfg.UnixSocket); removeErr != nil && !os.IsNotExist(removeErr) {
+			log.Printf("Failed to remove unix socket %s: %v", s.cfg.UnixSocket, removeErr)
+		}
+	}
+	return err
 }
 
 func (s *Server) setupRoutes() {
+	switch s.cfg.TrailingSlash {
+	case trailingSlashRedirect, trailingSlashIgnore, trailingSlashStrict:
+	default:
+		log.Printf("Unknown server.trailing_slash %q (expected %s, %s or %s), using %s",
+			s.cfg.TrailingSlash, trailingSlashRedirect, trailingSlashIgnore, trailingSlashStrict, trailingSlashRedirect)
+		s.cfg.TrailingSlash = trailingSlashRedirect
+	}
 	root := s.router.PathPrefix("/").Subrouter()
 
-	root.HandleFunc("/", s.handleRoot).Methods("GET", "OPTIONS")
+	// Preflight requests are answered here for every path, so the routes
+	// below only list the methods they serve.
+	root.Methods("OPTIONS").HandlerFunc(s.handlePreflight)
 
-	root.HandleFunc("/_apis/public/gallery/extensionquery", s.handleExtensionQuery).Methods("POST", "OPTIONS")
+	root.HandleFunc("/", s.handleRoot).Methods("GET")
 
-	root.HandleFunc("/_gallery/{publisher}/{name}/latest", s.handleVSCodeExtension).Methods("GET", "OPTIONS")
+	root.HandleFunc("/_apis/public/gallery/extensionquery", s.handleExtensionQuery).Methods("POST")
+
+	root.HandleFunc("/_catalog.json", s.handleCatalog).Methods("GET")
+	root.HandleFunc("/_stats", s.handleStats).Methods("GET")
+	root.HandleFunc("/_metrics", s.handleMetrics).Methods("GET")
+	root.HandleFunc("/_health", s.handleHealth).Methods("GET")
+
+	// Gallery and asset routes also answer HEAD, so clients can check that a
+	// file exists and how large it is without downloading it.
+	root.HandleFunc("/_gallery/{publisher}/{name}/latest", s.handleVSCodeExtension).Methods("GET", "HEAD")
+	if s.debugEnabled {
+		root.HandleFunc("/_gallery/{publisher}/{name}/{version}/files", s.handleVSIXFiles).Methods("GET", "HEAD")
+	}
 
-	root.HandleFunc("/_assets/{publisher}/{name}/{version}/{assetType}", s.handleVSCodeAsset).Methods("GET", "OPTIONS")
-	root.HandleFunc("/_assets/{extensionID}/{filename}", s.handleExtensionAssets).Methods("GET", "OPTIONS")
+	// File routes stream from the packages and the assets directory and
+	// are exempt from server.request_timeout, see timeoutMiddleware.
+	s.fileRoutes = make(map[string]bool)
+	fileRoute := func(path string, handler http.HandlerFunc) {
+		root.HandleFunc(path, handler).Methods("GET", "HEAD")
+		s.fileRoutes[path] = true
+	}
+	fileRoute("/_gallery/{publisher}/{name}/{version}/package.json", s.handleGalleryPackageJSON)
+	fileRoute("/_assets/{publisher}/{name}/{version}/{assetType}", s.handleVSCodeAsset)
+	// README assets are served under assets.url_prefix. The default path
+	// stays registered so READMEs rewritten before a change keep working.
+	if prefix := s.cfg.AssetsRoutePrefix(); prefix != config.DefaultAssetsURLPrefix {
+		fileRoute(prefix+"/{extensionID}/{filename}", s.handleExtensionAssets)
+	}
+	fileRoute(config.DefaultAssetsURLPrefix+"/{extensionID}/{filename}", s.handleExtensionAssets)
 
+	s.router.Use(s.recoveryMiddleware)
 	s.router.Use(s.corsMiddleware)
 	s.router.Use(s.loggingMiddleware)
+	s.router.Use(s.compressionMiddleware)
+	s.router.Use(s.timeoutMiddleware)
+	s.router.Use(s.headMiddleware)
 
 	s.router.NotFoundHandler = http.HandlerFunc(s.handleNotFound)
 	s.router.MethodNotAllowedHandler = http.HandlerFunc(s.handleMethodNotAllowed)
@@ -127,6 +332,184 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// recoveryMiddleware turns a panicking handler into a 500 instead of taking
+// the whole server down. Every request gets an X-Request-Id, taken from the
+// client when it sends one, so the logged stack can be matched to a report.
+func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID = utils.NewUUID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// The client went away; net/http handles this quietly itself.
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			log.Printf("API: PANIC in %s %s (request %s): %v\n%s", r.Method, r.URL.Path, requestID, recovered, debug.Stack())
+			// Once the response has started an error cannot be sent;
+			// dropping the connection tells the client it is incomplete.
+			if sw.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			s.writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error (request "+requestID+")")
+		}()
+
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// timeoutMiddleware answers requests still running after
+// server.request_timeout with a 503, unless the handler has already started
+// its response, and cancels their context. Unlike http.TimeoutHandler it
+// does not buffer responses. File routes are exempt, since large packages
+// legitimately take a long time on slow links.
+func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestTimeout := s.settings.Load().requestTimeout
+		if requestTimeout <= 0 || s.isFileRoute(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+
+		tw := &timeoutWriter{ResponseWriter: w, header: w.Header().Clone()}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					panicked <- recovered
+				}
+				close(done)
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+		}()
+
+		// repanic hands a panic of the finished handler on to
+		// recoveryMiddleware.
+		repanic := func() {
+			select {
+			case recovered := <-panicked:
+				panic(recovered)
+			default:
+			}
+		}
+
+		select {
+		case <-done:
+			repanic()
+			tw.finish()
+		case <-ctx.Done():
+			if !tw.expire() {
+				// The response has started; let the handler complete it.
+				<-done
+				repanic()
+				return
+			}
+			if ctx.Err() == context.DeadlineExceeded {
+				log.Printf("API: %s %s - timed out after %v", r.Method, r.URL.Path, requestTimeout)
+				s.writeError(w, http.StatusServiceUnavailable, "timeout", "Request timed out")
+			}
+		}
+	})
+}
+
+// isFileRoute reports whether r was routed to one of s.fileRoutes.
+func (s *Server) isFileRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	template, err := route.GetPathTemplate()
+	return err == nil && s.fileRoutes[template]
+}
+
+// timeoutWriter is the ResponseWriter of handlers run by timeoutMiddleware.
+// Writes go straight to the client. The handler gets a header map of its
+// own, copied over when the response starts, so the middleware can still
+// answer with a 503 while the handler runs; after that, writes fail with
+// http.ErrHandlerTimeout.
+type timeoutWriter struct {
+	http.ResponseWriter
+	header http.Header
+
+	mu      sync.Mutex
+	started bool
+	expired bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.expired {
+		tw.start(status)
+	}
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.start(http.StatusOK)
+	return tw.ResponseWriter.Write(p)
+}
+
+// Unwrap gives http.ResponseController access to the connection.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// start sends the handler's header with status unless already done. tw.mu
+// must be held.
+func (tw *timeoutWriter) start(status int) {
+	if tw.started {
+		return
+	}
+	tw.started = true
+	header := tw.ResponseWriter.Header()
+	clear(header)
+	for key, values := range tw.header {
+		header[key] = values
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+// finish sends the header of a handler that returned without writing.
+func (tw *timeoutWriter) finish() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.start(http.StatusOK)
+}
+
+// expire stops further writes and reports whether the response had not
+// started yet, so the caller may still send one.
+func (tw *timeoutWriter) expire() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.started {
+		return false
+	}
+	tw.expired = true
+	return true
+}
+
 func (s *Server) logRequest(r *http.Request) {
 	userAgent := s.getHeaderValue(r, "User-Agent", "Unknown")
 	referer := s.getHeaderValue(r, "Referer", "Direct")
@@ -154,7 +537,7 @@ func (s *Server) logVSCodiumHeaders(r *http.Request) {
 		r.Header.Get("X-Market-Client-Id"),
 		r.Header.Get("X-Market-User-Id"),
 		r.Header.Get("X-Client-Name"),
-		r.Header.Get("X-Client-Version"),
+		r.Header.Get(clientVersionHeader),
 	}
 
 	hasHeaders := false
@@ -175,17 +558,17 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		s.setCORSHeaders(w)
 		s.setHTTPHeaders(w)
-
-		if r.Method == "OPTIONS" {
-			log.Printf("API: OPTIONS %s - CORS preflight request", r.URL.Path)
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
 		next.ServeHTTP(w, r)
 	})
 }
 
+// handlePreflight answers CORS preflight requests; corsMiddleware has
+// already set the headers.
+func (s *Server) handlePreflight(w http.ResponseWriter, r *http.Request) {
+	log.Printf("API: OPTIONS %s - CORS preflight request", r.URL.Path)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s *Server) setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "OPTIONS,GET,POST,PATCH,PUT,DELETE")
@@ -205,102 +588,161 @@ func (s *Server) setHTTPHeaders(w http.ResponseWriter) {
 }
 
 func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	log.Printf("API: GET / - root endpoint request")
 
+	count := s.extManager.Count()
 	info := map[string]interface{}{
 		"name":        "LittleVSX",
 		"description": "Local marketplace for Visual Studio Code",
 		"version":     "1.0.0",
+		"extensions":  count,
 		"endpoints": map[string]string{
 			"vscode": "/_apis/public/gallery/extensionquery",
 		},
 	}
+	if count == 0 {
+		info["message"] = emptyCatalogMessage
+	}
 
 	s.writeJSON(w, http.StatusOK, info)
 }
 
-func (s *Server) handleExtensionQuery(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	log.Printf("API: GET /_stats - stats request")
+	stats := s.extManager.GetStats()
+	if total, ok := stats["total_extensions"].(int64); !ok || total == 0 {
+		stats["empty"] = true
+		stats["message"] = emptyCatalogMessage
 	}
+	if s.settings.Load().trackUsers {
+		if installs, err := s.extManager.GetUniqueInstalls(); err != nil {
+			log.Printf("API: GET /_stats - error counting installs: %v", err)
+		} else {
+			stats["unique_installs"] = installs
+		}
+	}
+	s.writeJSON(w, http.StatusOK, stats)
+}
 
+// handleMetrics exposes the extraction cache counters in the Prometheus text
+// exposition format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := s.extManager.ExtractCacheStats()
+	metrics := []struct {
+		name, kind, help string
+		value            int64
+	}{
+		{"littlevsx_extract_cache_hits_total", "counter", "Files served from the extraction cache.", stats.Hits},
+		{"littlevsx_extract_cache_misses_total", "counter", "Files extracted from a .vsix package because they were not cached.", stats.Misses},
+		{"littlevsx_extract_cache_evictions_total", "counter", "Files removed from the extraction cache to make room.", stats.Evictions},
+		{"littlevsx_extract_cache_entries", "gauge", "Files in the extraction cache.", int64(stats.Entries)},
+		{"littlevsx_extract_cache_bytes", "gauge", "Size of the files in the extraction cache.", stats.Bytes},
+		{"littlevsx_extract_cache_max_bytes", "gauge", "Configured size limit of the extraction cache (cache.extract_max_mb).", stats.MaxBytes},
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", metric.name, metric.help, metric.name, metric.kind, metric.name, metric.value)
+	}
+}
+
+func (s *Server) handleExtensionQuery(w http.ResponseWriter, r *http.Request) {
 	var query map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
 		log.Printf("API: POST %s - invalid JSON body: %v", r.URL.Path, err)
-		s.writeError(w, http.StatusBadRequest, "Invalid JSON format")
+		s.writeError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
 		return
 	}
 
 	log.Printf("API: POST %s - received query: %+v", r.URL.Path, query)
 
-	var searchQuery string
-	var extensionId string
-
-	if q, ok := query["query"].(string); ok && q != "" {
-		searchQuery = q
-	} else if filters, ok := query["filters"].([]interface{}); ok && len(filters) > 0 {
-		if filter, ok := filters[0].(map[string]interface{}); ok {
-			if criteria, ok := filter["criteria"].([]interface{}); ok && len(criteria) > 0 {
-				for _, criterion := range criteria {
-					if criterionMap, ok := criterion.(map[string]interface{}); ok {
-						if filterType, ok := criterionMap["filterType"].(float64); ok {
-							switch filterType {
-							case 10: // filterType 10 = Search query
-								if value, ok := criterionMap["value"].(string); ok {
-									searchQuery = value
-								}
-							case 4: // filterType 4 = Extension ID
-								if value, ok := criterionMap["value"].(string); ok {
-									extensionId = value
-								}
-							}
-						}
-					}
-				}
-			}
-		}
+	q := parseExtensionQuery(query)
+	st := s.settings.Load()
+	if st.filterByEngine {
+		q.clientVersion = r.Header.Get(clientVersionHeader)
 	}
 
 	w.Header().Set("Content-Type", utils.HTTPAPIVersion)
 
-	var results []interface{}
+	cache := st.queryCache
+	var cacheKey, fingerprint string
+	if cache != nil {
+		cacheKey, fingerprint = q.cacheKey(), s.extManager.GetFingerprint()
+		if body, ok := cache.get(cacheKey, fingerprint); ok {
+			log.Printf("API: POST %s - serving cached response", r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+			w.Write(body)
+			return
+		}
+	}
 
-	if extensionId != "" {
-		log.Printf("API: POST %s - searching by extension ID: '%s'", r.URL.Path, extensionId)
-		ext, found := s.extManager.GetByID(extensionId)
-		if found && ext != nil {
-			extensionInfo := s.createExtensionInfo(ext)
-			if extensionInfo != nil {
-				results = []interface{}{extensionInfo}
+	var candidates []*models.Extension
+	var matched []*models.Extension
+	var totalCount int
+
+	// searchTotal is the number of catalog matches of a text search that
+	// was cut off at search.max_results, 0 when it was not.
+	var searchTotal int64
+
+	if len(q.extensionIDs) > 0 {
+		log.Printf("API: POST %s - searching by extension IDs: %v", r.URL.Path, q.extensionIDs)
+		for _, id := range q.extensionIDs {
+			if ext, found := s.extManager.GetByIDFold(id); found && ext != nil {
+				candidates = append(candidates, ext)
+			} else if ext, found := s.extManager.GetByExtensionID(id); found {
+				candidates = append(candidates, ext)
 			}
 		}
-	} else if searchQuery != "" {
-		log.Printf("API: POST %s - search query: '%s'", r.URL.Path, searchQuery)
-		extensions := s.extManager.Search(searchQuery)
-		for _, ext := range extensions {
-			if ext != nil {
-				extensionInfo := s.createExtensionInfo(ext)
-				if extensionInfo != nil {
-					results = append(results, extensionInfo)
-				}
-			}
+	} else if q.searchText != "" {
+		log.Printf("API: POST %s - search query: '%s'", r.URL.Path, q.searchText)
+		var total int64
+		candidates, total = s.extManager.Search(q.searchText)
+		if total > int64(len(candidates)) {
+			log.Printf("API: POST %s - search truncated to %d of %d matches", r.URL.Path, len(candidates), total)
+			searchTotal = total
 		}
+	} else if len(q.tags) > 0 {
+		log.Printf("API: POST %s - searching by tag: '%s'", r.URL.Path, q.tags[0])
+		candidates = s.extManager.GetByTag(q.tags[0])
+	} else if len(q.categories) > 0 {
+		log.Printf("API: POST %s - searching by category: '%s'", r.URL.Path, q.categories[0])
+		candidates = s.extManager.GetByCategory(q.categories[0])
+	} else if !q.filtersInMemory() {
+		log.Printf("API: POST %s - no filters, returning page %d (size %d)", r.URL.Path, q.pageNumber, q.pageSize)
+		var total int64
+		candidates, total = s.extManager.GetPage(q.pageNumber, q.pageSize)
+		totalCount = int(total)
 	} else {
-		log.Printf("API: POST %s - no search query or extension ID found, returning all extensions", r.URL.Path)
-		allExtensions := s.extManager.GetAll()
-		for _, ext := range allExtensions {
-			if ext != nil {
-				extensionInfo := s.createExtensionInfo(ext)
-				if extensionInfo != nil {
-					results = append(results, extensionInfo)
-				}
-			}
+		log.Printf("API: POST %s - no search query or extension ID found, filtering all extensions", r.URL.Path)
+		candidates = s.extManager.GetAll()
+	}
+
+	for _, ext := range candidates {
+		if ext != nil && q.clientVersion != "" {
+			ext = s.newestCompatible(ext, q.clientVersion)
+		}
+		if ext != nil && q.matches(ext) {
+			matched = append(matched, ext)
+		}
+	}
+
+	q.sort(matched)
+
+	switch {
+	case len(q.extensionIDs) > 0:
+		totalCount = len(matched)
+	case q.searchText != "" || q.filtersInMemory():
+		totalCount = len(matched)
+		matched = q.page(matched)
+	}
+
+	publishers := s.extManager.GetPublishers()
+
+	var results []interface{}
+	for _, ext := range matched {
+		extensionInfo := s.createExtensionInfo(ext, publishers, q)
+		if extensionInfo != nil {
+			results = append(results, extensionInfo)
 		}
 	}
 
@@ -308,21 +750,37 @@ func (s *Server) handleExtensionQuery(w http.ResponseWriter, r *http.Request) {
 		results = []interface{}{}
 	}
 
+	resultMetadata := []map[string]interface{}{
+		{
+			"metadataType": "ResultCount",
+			"metadataItems": []map[string]interface{}{
+				{
+					"name":  "TotalCount",
+					"count": totalCount,
+				},
+			},
+		},
+	}
+	// Clients ignore metadata types they do not know, so the truncation is
+	// reported alongside instead of inflating TotalCount beyond what can be
+	// paged through.
+	if searchTotal > 0 {
+		resultMetadata = append(resultMetadata, map[string]interface{}{
+			"metadataType": "Truncated",
+			"metadataItems": []map[string]interface{}{
+				{
+					"name":  "TotalSize",
+					"count": searchTotal,
+				},
+			},
+		})
+	}
+
 	response := map[string]interface{}{
 		"results": []map[string]interface{}{
 			{
-				"extensions": results,
-				"resultMetadata": []map[string]interface{}{
-					{
-						"metadataType": "ResultCount",
-						"metadataItems": []map[string]interface{}{
-							{
-								"name":  "TotalCount",
-								"count": len(results),
-							},
-						},
-					},
-				},
+				"extensions":     results,
+				"resultMetadata": resultMetadata,
 			},
 		},
 	}
@@ -332,22 +790,83 @@ func (s *Server) handleExtensionQuery(w http.ResponseWriter, r *http.Request) {
 		log.Printf("API: POST %s - no results found, returning empty array", r.URL.Path)
 	}
 	log.Printf("API: POST %s - response structure: %+v", r.URL.Path, response)
-	s.writeJSON(w, http.StatusOK, response)
+
+	if cache == nil {
+		s.writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+		s.writeError(w, http.StatusInternalServerError, "encoding_failed", "JSON encoding error")
+		return
+	}
+	cache.put(cacheKey, fingerprint, body)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// createExtensionInfo describes the latest version ext of an extension in
+// gallery responses, or the newest one compatible with the client of q.
+// Its versions array lists the stored builds newest version first, with an
+// entry per target platform, or only ext when q asks for the latest version
+// only. Versions the client of q cannot run are left out.
+func (s *Server) createExtensionInfo(ext *models.Extension, publishers map[string]*models.Publisher, q extensionQuery) map[string]interface{} {
+	assetTypes := q.assetTypes
+	extensionId := utils.ExtensionUUID(ext.ExtensionID, ext.ID)
+
+	versions := []map[string]interface{}{s.galleryVersion(ext, assetTypes)}
+	if !q.latestOnly {
+		for _, older := range s.extManager.GetVersions(ext.ID) {
+			if older.Version == ext.Version && older.TargetPlatform == ext.TargetPlatform {
+				continue
+			}
+			if q.clientVersion != "" && !engineAllows(older, q.clientVersion) {
+				continue
+			}
+			versions = append(versions, s.galleryVersion(older, assetTypes))
+		}
+	}
+
+	return map[string]interface{}{
+		"extensionId":      extensionId,
+		"extensionName":    ext.Name,
+		"displayName":      ext.DisplayName,
+		"shortDescription": ext.Description,
+		"publisher":        publisherInfo(ext.Publisher, publishers),
+		"versions":         versions,
+		"statistics":       galleryStatistics(ext),
+		"tags":             ext.Tags,
+		"releaseDate":      galleryTime(ext.LastUpdated),
+		"publishedDate":    galleryTime(ext.LastUpdated),
+		"lastUpdated":      galleryTime(ext.LastUpdated),
+		"categories":       ext.Categories,
+		"flags":            "",
+	}
 }
 
-func (s *Server) createExtensionInfo(ext *models.Extension) map[string]interface{} {
-	extensionId := ext.ID
-	if extensionId == "" {
-		extensionId = generateUUID()
+// gallerySource returns the marketplace ext was downloaded from, or
+// "unknown" for extensions added with import or before sources were
+// recorded.
+func gallerySource(ext *models.Extension) string {
+	if ext.Source == "" {
+		return "unknown"
 	}
+	return ext.Source
+}
 
+// galleryVersion describes one version of an extension with its files and
+// properties.
+func (s *Server) galleryVersion(ext *models.Extension, assetTypes []string) map[string]interface{} {
 	// Создаем версию расширения
 	version := map[string]interface{}{
 		"version":          ext.Version,
-		"lastUpdated":      ext.LastUpdated,
+		"lastUpdated":      galleryTime(ext.LastUpdated),
 		"assetUri":         fmt.Sprintf("%s/_assets/%s/%s/%s", s.baseURL, ext.Publisher, ext.Name, ext.Version),
 		"fallbackAssetUri": fmt.Sprintf("%s/_assets/%s/%s/%s", s.baseURL, ext.Publisher, ext.Name, ext.Version),
-		"targetPlatform":   "universal",
+		"targetPlatform":   ext.TargetPlatform,
 		"files": []map[string]interface{}{
 			{
 				"assetType": "Microsoft.VisualStudio.Code.Manifest",
@@ -361,14 +880,6 @@ func (s *Server) createExtensionInfo(ext *models.Extension) map[string]interface
 				"assetType": "Microsoft.VisualStudio.Services.VsixManifest",
 				"source":    fmt.Sprintf("%s/_gallery/%s/%s/%s/file/extension.vsixmanifest", s.baseURL, ext.Publisher, ext.Name, ext.Version),
 			},
-			{
-				"assetType": "Microsoft.VisualStudio.Services.VsixSignature",
-				"source":    fmt.Sprintf("%s/_gallery/%s/%s/%s/file/%s.sigzip", s.baseURL, ext.Publisher, ext.Name, ext.Version, strings.TrimSuffix(filepath.Base(ext.FilePath), ".vsix")),
-			},
-			{
-				"assetType": "Microsoft.VisualStudio.Services.PublicKey",
-				"source":    fmt.Sprintf("%s/_gallery/-/public-key/%s", s.baseURL, generateUUID()),
-			},
 		},
 		"properties": []map[string]interface{}{
 			{"key": "Microsoft.VisualStudio.Services.Branding.Color", "value": ""},
@@ -376,13 +887,23 @@ func (s *Server) createExtensionInfo(ext *models.Extension) map[string]interface
 			{"key": "Microsoft.VisualStudio.Services.Links.Source", "value": ext.Repository},
 			{"key": "Microsoft.VisualStudio.Code.SponsorLink", "value": ""},
 			{"key": "Microsoft.VisualStudio.Code.Engine", "value": ext.Engines.VSCode},
-			{"key": "Microsoft.VisualStudio.Code.ExtensionDependencies", "value": ""},
-			{"key": "Microsoft.VisualStudio.Code.ExtensionPack", "value": ""},
+			{"key": "Microsoft.VisualStudio.Code.ExtensionDependencies", "value": strings.Join(ext.Dependencies, ",")},
+			{"key": "Microsoft.VisualStudio.Code.ExtensionPack", "value": strings.Join(ext.ExtensionPack, ",")},
 			{"key": "Microsoft.VisualStudio.Code.LocalizedLanguages", "value": ""},
-			{"key": "Microsoft.VisualStudio.Code.PreRelease", "value": "false"},
+			{"key": "Microsoft.VisualStudio.Code.PreRelease", "value": strconv.FormatBool(ext.PreRelease)},
+			{"key": sourcePropertyKey, "value": gallerySource(ext)},
 		},
 	}
 
+	// Clients verify a listed signature and reject the extension when it is
+	// invalid, so only real ones are listed.
+	if s.hasSignature(ext) {
+		version["files"] = append(version["files"].([]map[string]interface{}), map[string]interface{}{
+			"assetType": vsixSignatureAssetType,
+			"source":    fmt.Sprintf("%s/_assets/%s/%s/%s/%s", s.baseURL, ext.Publisher, ext.Name, ext.Version, vsixSignatureAssetType),
+		})
+	}
+
 	// Добавляем README если есть
 	if ext.ReadmeContent != "" || ext.Description != "" {
 		version["files"] = append(version["files"].([]map[string]interface{}), map[string]interface{}{
@@ -399,55 +920,157 @@ func (s *Server) createExtensionInfo(ext *models.Extension) map[string]interface
 		})
 	}
 
-	// Добавляем иконку если есть
+	// Добавляем иконку; без неё отдаётся сгенерированная заглушка
+	iconSource := fmt.Sprintf("%s/_assets/%s/%s/%s/Microsoft.VisualStudio.Services.Icons.Default", s.baseURL, ext.Publisher, ext.Name, ext.Version)
 	if ext.Icon != "" {
-		version["files"] = append(version["files"].([]map[string]interface{}), map[string]interface{}{
-			"assetType": "Microsoft.VisualStudio.Services.Icons.Default",
-			"source":    fmt.Sprintf("%s/_assets/%s/%s/%s/file/%s", s.baseURL, ext.Publisher, ext.Name, ext.Version, filepath.Base(ext.Icon)),
-		})
+		iconSource = fmt.Sprintf("%s/_assets/%s/%s/%s/file/%s", s.baseURL, ext.Publisher, ext.Name, ext.Version, filepath.Base(ext.Icon))
 	}
+	version["files"] = append(version["files"].([]map[string]interface{}), map[string]interface{}{
+		"assetType": "Microsoft.VisualStudio.Services.Icons.Default",
+		"source":    iconSource,
+	})
 
-	return map[string]interface{}{
-		"extensionId":      extensionId,
-		"extensionName":    ext.Name,
-		"displayName":      ext.DisplayName,
-		"shortDescription": ext.Description,
-		"publisher": map[string]interface{}{
-			"displayName":      ext.Publisher,
-			"publisherId":      generateUUID(),
-			"publisherName":    ext.Publisher,
-			"domain":           nil,
-			"isDomainVerified": nil,
-		},
-		"versions": []map[string]interface{}{version},
-		"statistics": []map[string]interface{}{
-			{"statisticName": "install", "value": 0.0},
-			{"statisticName": "ratingcount", "value": 0.0},
-		},
-		"tags":          ext.Tags,
-		"releaseDate":   ext.LastUpdated,
-		"publishedDate": ext.LastUpdated,
-		"lastUpdated":   ext.LastUpdated,
-		"categories":    ext.Categories,
-		"flags":         "",
+	// Each platform build of a version is listed separately, so its files
+	// name the build they belong to.
+	if ext.TargetPlatform != "" && ext.TargetPlatform != universalPlatform {
+		for _, file := range version["files"].([]map[string]interface{}) {
+			file["source"] = file["source"].(string) + "?targetPlatform=" + url.QueryEscape(ext.TargetPlatform)
+		}
 	}
+
+	if len(assetTypes) > 0 {
+		version["files"] = filterAssetFiles(version["files"].([]map[string]interface{}), assetTypes)
+	}
+
+	return version
 }
 
-func generateUUID() string {
-	b := make([]byte, 16)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "00000000-0000-0000-0000-000000000000"
+// galleryStatistics returns the statistics listed for an extension. The
+// rating is left out until the extension has one, from its marketplace or
+// the rate command, so clients show it as unrated instead of with an
+// invented score.
+func galleryStatistics(ext *models.Extension) []map[string]interface{} {
+	statistics := []map[string]interface{}{
+		{"statisticName": "install", "value": float64(ext.DownloadCount)},
 	}
-	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	if ext.ReviewCount > 0 {
+		statistics = append(statistics,
+			map[string]interface{}{"statisticName": "averagerating", "value": ext.AverageRating},
+			map[string]interface{}{"statisticName": "ratingcount", "value": float64(ext.ReviewCount)},
+		)
+	}
+	return statistics
 }
 
-func (s *Server) handleVSCodeExtension(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+// galleryTime formats t like the Visual Studio Marketplace does, in UTC with
+// millisecond precision, e.g. "2024-03-05T14:07:09.123Z".
+func galleryTime(t time.Time) string {
+	return t.UTC().Format(galleryTimeLayout)
+}
+
+// filterAssetFiles keeps the version files whose asset type was requested
+// in the query's assetTypes.
+func filterAssetFiles(files []map[string]interface{}, assetTypes []string) []map[string]interface{} {
+	filtered := []map[string]interface{}{}
+	for _, file := range files {
+		assetType, _ := file["assetType"].(string)
+		for _, requested := range assetTypes {
+			if strings.EqualFold(assetType, requested) {
+				filtered = append(filtered, file)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// publisherInfo describes a publisher in gallery responses, using the domain
+// and verification set with "littlevsx publisher set" when there is a record.
+func publisherInfo(name string, publishers map[string]*models.Publisher) map[string]interface{} {
+	info := map[string]interface{}{
+		"displayName":      name,
+		"publisherId":      utils.PublisherUUID(name),
+		"publisherName":    name,
+		"domain":           nil,
+		"isDomainVerified": nil,
+	}
+
+	pub, ok := publishers[strings.ToLower(name)]
+	if !ok {
+		return info
+	}
+	if pub.DisplayName != "" {
+		info["displayName"] = pub.DisplayName
+	}
+	if pub.Domain != "" {
+		info["domain"] = pub.Domain
+		info["isDomainVerified"] = pub.Verified
+	}
+	return info
+}
+
+func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	body, etag, err := s.getCatalog()
+	if err != nil {
+		log.Printf("API: GET /_catalog.json - error building catalog: %v", err)
+		s.writeError(w, http.StatusInternalServerError, "catalog_failed", "Failed to build catalog")
 		return
 	}
 
+	w.Header().Set("ETag", etag)
+	w.Header().Set(cacheControlHeader, "public, no-cache")
+
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// getCatalog returns the serialized catalog, rebuilding it only when the
+// database fingerprint has changed since the last call.
+func (s *Server) getCatalog() ([]byte, string, error) {
+	fingerprint := s.extManager.GetFingerprint()
+
+	s.catalogMu.Lock()
+	defer s.catalogMu.Unlock()
+
+	if s.catalogBody != nil && fingerprint != "" && fingerprint == s.catalogFingerprint {
+		return s.catalogBody, s.catalogETag, nil
+	}
+
+	entries := []models.CatalogEntry{}
+	for _, ext := range s.extManager.GetAll() {
+		assetURI := fmt.Sprintf("%s/_assets/%s/%s/%s", s.baseURL, ext.Publisher, ext.Name, ext.Version)
+		entry := models.CatalogEntry{
+			ID:          ext.ID,
+			Name:        ext.Name,
+			DisplayName: ext.DisplayName,
+			Version:     ext.Version,
+			Publisher:   ext.Publisher,
+			DownloadURL: assetURI + "/Microsoft.VisualStudio.Services.VSIXPackage",
+		}
+		entry.IconURL = assetURI + "/Microsoft.VisualStudio.Services.Icons.Default"
+		entries = append(entries, entry)
+	}
+
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.catalogFingerprint = fingerprint
+	s.catalogETag = fmt.Sprintf("\"%x\"", sha256.Sum256(body))
+	s.catalogBody = body
+
+	log.Printf("API: catalog rebuilt with %d extensions", len(entries))
+	return s.catalogBody, s.catalogETag, nil
+}
+
+func (s *Server) handleVSCodeExtension(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	publisher := vars["publisher"]
 	name := vars["name"]
@@ -456,35 +1079,119 @@ func (s *Server) handleVSCodeExtension(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("API: GET /_gallery/%s/%s/latest - looking for extension: %s", publisher, name, extensionID)
 
-	ext, exists := s.extManager.GetByID(extensionID)
+	targetPlatform := r.URL.Query().Get("targetPlatform")
+	ext, exists := s.extManager.GetForPlatform(extensionID, targetPlatform)
 	if !exists {
 		log.Printf("API: GET /_gallery/%s/%s/latest - NOT FOUND: %s", publisher, name, extensionID)
-		s.writeError(w, http.StatusNotFound, "Extension not found")
+		s.writeError(w, http.StatusNotFound, "extension_not_found", "Extension not found")
 		return
 	}
 
+	if clientVersion := r.Header.Get(clientVersionHeader); clientVersion != "" && s.settings.Load().filterByEngine {
+		compatible := s.newestCompatible(ext, clientVersion)
+		if compatible != nil && compatible != ext {
+			compatible, _ = s.extManager.GetVersionForPlatform(extensionID, compatible.Version, targetPlatform)
+		}
+		if compatible == nil {
+			log.Printf("API: GET /_gallery/%s/%s/latest - no version runs on VS Code %s (latest %s requires %s)", publisher, name, clientVersion, ext.Version, ext.Engines.VSCode)
+			s.writeError(w, http.StatusNotFound, "no_compatible_version", "No version compatible with this client")
+			return
+		}
+		if compatible != ext {
+			log.Printf("API: GET /_gallery/%s/%s/latest - %s requires VS Code %s, serving %s to client %s", publisher, name, ext.Version, ext.Engines.VSCode, compatible.Version, clientVersion)
+		}
+		ext = compatible
+	}
+
 	log.Printf("API: GET /_gallery/%s/%s/latest - FOUND: %s by %s", publisher, name, ext.DisplayName, ext.Publisher)
 	s.writeJSON(w, http.StatusOK, ext)
 }
 
-func (s *Server) handleNotFound(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+// engineAllows reports whether the engines.vscode range of ext allows
+// clientVersion.
+func engineAllows(ext *models.Extension, clientVersion string) bool {
+	return utils.ParseEngineRange(ext.Engines.VSCode).Allows(clientVersion)
+}
+
+// newestCompatible returns ext when its engines.vscode range allows
+// clientVersion, and otherwise the first build of the newest stored version
+// of the extension that does. It returns nil when no stored version runs on
+// clientVersion.
+func (s *Server) newestCompatible(ext *models.Extension, clientVersion string) *models.Extension {
+	if engineAllows(ext, clientVersion) {
+		return ext
+	}
+	for _, older := range s.extManager.GetVersions(ext.ID) {
+		if engineAllows(older, clientVersion) {
+			return older
+		}
+	}
+	return nil
+}
+
+// handleGalleryPackageJSON serves the package.json of an extension exactly as
+// packaged, for tooling that wants the real manifest rather than our model.
+// The version is either a stored one or "latest"; only the latter may change
+// over time, so only its responses must be revalidated.
+func (s *Server) handleGalleryPackageJSON(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	extensionID := fmt.Sprintf("%s.%s", vars["publisher"], vars["name"])
+	version := vars["version"]
+
+	var ext *models.Extension
+	var exists bool
+	if version == "latest" {
+		ext, exists = s.extManager.GetForPlatform(extensionID, r.URL.Query().Get("targetPlatform"))
+	} else {
+		ext, exists = s.extManager.GetVersionForPlatform(extensionID, version, r.URL.Query().Get("targetPlatform"))
+	}
+	if !exists {
+		log.Printf("API: GET %s - NOT FOUND: %s@%s", r.URL.Path, extensionID, version)
+		s.writeError(w, http.StatusNotFound, "extension_not_found", "Extension not found")
 		return
 	}
 
-	log.Printf("API: 404 - Not Found: %s %s", r.Method, r.URL.Path)
-	s.writeError(w, http.StatusNotFound, "Page not found")
+	if version == "latest" {
+		w.Header().Set(cacheControlHeader, "public, no-cache")
+	} else {
+		w.Header().Set(cacheControlHeader, "public, max-age=86400")
+	}
+	s.servePackageJSON(w, r, ext)
 }
 
-func (s *Server) handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+// handleVSIXFiles lists the entries of an extension's .vsix package. It is
+// only registered when debug.enabled is set and helps diagnosing assets that
+// are missing because of non-standard packaging.
+func (s *Server) handleVSIXFiles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	extensionID := fmt.Sprintf("%s.%s", vars["publisher"], vars["name"])
+
+	ext, exists := s.extManager.GetVersionForPlatform(extensionID, vars["version"], r.URL.Query().Get("targetPlatform"))
+	if !exists {
+		log.Printf("API: GET %s - NOT FOUND: %s@%s", r.URL.Path, extensionID, vars["version"])
+		s.writeError(w, http.StatusNotFound, "extension_not_found", "Extension not found")
 		return
 	}
 
+	entries, err := s.extManager.ListVSIXEntries(ext)
+	if err != nil {
+		log.Printf("API: GET %s - failed to list VSIX entries: %v", r.URL.Path, err)
+		s.writeError(w, http.StatusInternalServerError, "vsix_unreadable", "Failed to read VSIX file")
+		return
+	}
+
+	log.Printf("API: GET %s - %d entries", r.URL.Path, len(entries))
+	s.writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	log.Printf("API: 404 - Not Found: %s %s", r.Method, r.URL.Path)
+	s.writeError(w, http.StatusNotFound, "not_found", "Page not found")
+}
+
+func (s *Server) handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
 	log.Printf("API: 405 - Method Not Allowed: %s %s", r.Method, r.URL.Path)
-	s.writeError(w, http.StatusMethodNotAllowed, "Method not supported")
+	s.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not supported")
 }
 
 func (s *Server) writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -499,22 +1206,23 @@ func (s *Server) writeJSON(w http.ResponseWriter, status int, data interface{})
 	}
 }
 
-func (s *Server) writeError(w http.ResponseWriter, status int, message string) {
+// writeError sends a JSON error body. code is a stable, machine-readable
+// identifier of the error, message a human-readable description. The content
+// type is always plain JSON, even if a handler already set another one.
+func (s *Server) writeError(w http.ResponseWriter, status int, code, message string) {
 	errorResponse := map[string]interface{}{
 		"error":   http.StatusText(status),
+		"code":    code,
 		"message": message,
 		"status":  status,
 	}
 
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Del("Content-Length")
 	s.writeJSON(w, status, errorResponse)
 }
 
 func (s *Server) handleVSCodeAsset(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	vars := mux.Vars(r)
 	publisher := vars["publisher"]
 	name := vars["name"]
@@ -522,67 +1230,124 @@ func (s *Server) handleVSCodeAsset(w http.ResponseWriter, r *http.Request) {
 	assetType := vars["assetType"]
 
 	extensionID := fmt.Sprintf("%s.%s", publisher, name)
+	targetPlatform := r.URL.Query().Get("targetPlatform")
 
 	log.Printf("API: GET /_assets/%s/%s/%s/%s - asset request", publisher, name, version, assetType)
 
-	ext, exists := s.extManager.GetByID(extensionID)
+	ext, exists := s.extManager.GetVersionForPlatform(extensionID, version, targetPlatform)
 	if !exists {
-		log.Printf("API: GET /_assets/%s/%s/%s/%s - EXTENSION NOT FOUND", publisher, name, version, assetType)
-		s.writeError(w, http.StatusNotFound, "Extension not found")
-		return
-	}
-
-	if ext.Version != version {
-		log.Printf("API: GET /_assets/%s/%s/%s/%s - VERSION NOT FOUND (available: %s)", publisher, name, version, assetType, ext.Version)
-		s.writeError(w, http.StatusNotFound, "Version not found")
+		latest, known := s.extManager.GetByID(extensionID)
+		if !known {
+			log.Printf("API: GET /_assets/%s/%s/%s/%s - EXTENSION NOT FOUND", publisher, name, version, assetType)
+			s.writeError(w, http.StatusNotFound, "extension_not_found", "Extension not found")
+			return
+		}
+		if builds := s.extManager.GetBuilds(extensionID, version); len(builds) > 0 {
+			log.Printf("API: GET /_assets/%s/%s/%s/%s - NO BUILD FOR PLATFORM %q", publisher, name, version, assetType, targetPlatform)
+			s.writeError(w, http.StatusNotFound, "platform_not_found", "No build of this version for the target platform")
+			return
+		}
+		log.Printf("API: GET /_assets/%s/%s/%s/%s - VERSION NOT FOUND (latest: %s)", publisher, name, version, assetType, latest.Version)
+		s.writeError(w, http.StatusNotFound, "version_not_found", "Version not found")
 		return
 	}
 
 	switch assetType {
 	case "Microsoft.VisualStudio.Code.Manifest":
-		s.servePackageJSON(w, ext)
-	case "Microsoft.VisualStudio.Services.VSIXPackage":
+		s.serveManifest(w, ext)
+	case rawManifestAssetType:
+		s.servePackageJSON(w, r, ext)
+	case vsixPackageAssetType:
 		s.serveVSIXFile(w, r, ext)
 	case "Microsoft.VisualStudio.Services.VsixManifest":
-		s.serveVSIXManifest(w, ext)
-	case "Microsoft.VisualStudio.Services.VsixSignature":
-		s.serveEmptySignature(w)
+		s.serveVSIXManifest(w, r, ext)
+	case vsixSignatureAssetType:
+		s.serveSignature(w, r, ext)
 	case "Microsoft.VisualStudio.Services.PublicKey":
-		s.serveEmptyPublicKey(w)
+		s.writeError(w, http.StatusNotFound, "public_key_not_found", "No public key is available")
 	case "Microsoft.VisualStudio.Services.Content.Details":
-		s.serveREADME(w, ext)
+		s.serveREADME(w, r, ext)
 	case "Microsoft.VisualStudio.Services.Content.License":
-		s.serveLICENSE(w, ext)
+		s.serveLICENSE(w, r, ext)
 	case "Microsoft.VisualStudio.Services.Icons.Default":
-		s.serveIcon(w, ext)
+		s.serveIcon(w, r, ext)
 	default:
-		log.Printf("API: GET /_assets/%s/%s/%s/%s - UNKNOWN ASSET TYPE", publisher, name, version, assetType)
-		s.writeError(w, http.StatusNotFound, "Asset type not supported")
+		if s.settings.Load().stubAssetTypes[strings.ToLower(assetType)] {
+			s.serveStubAsset(w)
+			return
+		}
+		s.serveMappedAsset(w, r, ext, assetType)
+	}
+}
+
+// serveStubAsset answers an asset type listed in assets.stub_types, which
+// some clients request but LittleVSX has no content for, with an empty body
+// so they do not log the request as failed.
+func (s *Server) serveStubAsset(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte{})
+}
+
+// serveMappedAsset serves an asset type without a dedicated handler from the
+// first of its assets.type_paths entries found in the .vsix. Unmapped types
+// are logged so operators can add a mapping for them.
+func (s *Server) serveMappedAsset(w http.ResponseWriter, r *http.Request, ext *models.Extension, assetType string) {
+	paths := s.settings.Load().assetPaths[strings.ToLower(assetType)]
+	if len(paths) == 0 {
+		log.Printf("API: GET /_assets/%s/%s/%s/%s - UNMAPPED ASSET TYPE, add it to assets.type_paths to serve it", ext.Publisher, ext.Name, ext.Version, assetType)
+		s.writeError(w, http.StatusNotFound, "asset_type_not_supported", "Asset type not supported")
+		return
+	}
+
+	for _, path := range paths {
+		if err := s.streamFromVSIX(w, r, ext, path, assetContentType(path)); err == nil {
+			return
+		}
+	}
+
+	log.Printf("API: GET /_assets/%s/%s/%s/%s - NONE OF %s FOUND", ext.Publisher, ext.Name, ext.Version, assetType, strings.Join(paths, ", "))
+	s.writeError(w, http.StatusNotFound, "asset_not_found", "Asset not found")
+}
+
+// assetContentType guesses the content type of a mapped asset from its
+// file extension.
+func assetContentType(path string) string {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".md":
+		return markdownContentType
+	case "":
+		return "text/plain; charset=utf-8"
+	default:
+		if contentType := mime.TypeByExtension(ext); contentType != "" {
+			return contentType
+		}
+		return octetStreamContentType
+	}
+}
+
+func (s *Server) serveManifest(w http.ResponseWriter, ext *models.Extension) {
+	manifest, err := s.extManager.BuildManifest(ext)
+	if err != nil {
+		log.Printf("API: Error building manifest: %v", err)
+		s.writeError(w, http.StatusInternalServerError, "manifest_failed", "Failed to build manifest")
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(manifest)
 }
 
-func (s *Server) servePackageJSON(w http.ResponseWriter, ext *models.Extension) {
-	packageJSON, err := s.extractFileFromVSIX(ext.FilePath, packageJSONPath)
+func (s *Server) servePackageJSON(w http.ResponseWriter, r *http.Request, ext *models.Extension) {
+	if s.notModified(w, r, ext, packageJSONPath) {
+		return
+	}
+
+	packageJSON, err := s.extManager.ReadPackageJSON(ext)
 	if err != nil {
 		log.Printf("API: Error extracting package.json: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		basicInfo := map[string]interface{}{
-			"name":        ext.Name,
-			"displayName": ext.DisplayName,
-			"description": ext.Description,
-			"version":     ext.Version,
-			"publisher":   ext.Publisher,
-			"engines":     ext.Engines,
-			"categories":  ext.Categories,
-			"tags":        ext.Tags,
-			"icon":        ext.Icon,
-			"repository":  ext.Repository,
-			"homepage":    ext.Homepage,
-			"bugs":        ext.Bugs,
-			"license":     ext.License,
-		}
-		jsonData, _ := json.Marshal(basicInfo)
-		w.Write(jsonData)
+		w.Header().Del("ETag")
+		w.Header().Del(cacheControlHeader)
+		s.writeError(w, http.StatusNotFound, "package_json_not_found", "package.json not found")
 		return
 	}
 
@@ -590,63 +1355,198 @@ func (s *Server) servePackageJSON(w http.ResponseWriter, ext *models.Extension)
 	w.Write(packageJSON)
 }
 
+// notModified sets a strong ETag for a file packaged in the extension's .vsix
+// and answers 304 when the request's If-None-Match already has it. Packages
+// are immutable, so this never needs to open the archive.
+func (s *Server) notModified(w http.ResponseWriter, r *http.Request, ext *models.Extension, entry string) bool {
+	etag, err := s.extManager.AssetETag(ext, entry)
+	if err != nil {
+		log.Printf("API: Error computing ETag for %s: %v", entry, err)
+		return false
+	}
+
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
 func (s *Server) serveVSIXFile(w http.ResponseWriter, r *http.Request, ext *models.Extension) {
+	if userID := r.Header.Get("X-Market-User-Id"); userID != "" && s.settings.Load().trackUsers {
+		if err := s.extManager.RecordInstall(ext, userID); err != nil {
+			log.Printf("API: Error recording install of %s: %v", ext.ID, err)
+		}
+	}
+
 	fileName := filepath.Base(ext.FilePath)
 	w.Header().Set(contentDispositionHeader, fmt.Sprintf("attachment; filename=\"%s\"", fileName))
 	w.Header().Set("Content-Type", octetStreamContentType)
-	http.ServeFile(w, r, ext.FilePath)
+
+	// Only whole packages sent to a GET count as a download, not HEAD
+	// requests, range requests resuming one, 304s or errors.
+	sw := &statusWriter{ResponseWriter: w}
+	s.extManager.Storage().Serve(sw, r, ext.FilePath)
+	if r.Method == http.MethodGet && sw.status == http.StatusOK {
+		if err := s.extManager.IncrementDownloadCount(ext); err != nil {
+			log.Printf("API: Error counting download of %s: %v", ext.ID, err)
+		}
+	}
 }
 
-func (s *Server) serveVSIXManifest(w http.ResponseWriter, ext *models.Extension) {
-	manifest, err := s.extractFileFromVSIX(ext.FilePath, vsixManifestPath)
-	if err != nil {
-		log.Printf("API: Error extracting extension.vsixmanifest: %v", err)
-		w.Header().Set("Content-Type", xmlContentType)
-		basicManifest := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
-<PackageManifest Version="2.0.0" xmlns="http://schemas.microsoft.com/developer/vsx-schema/2011">
-  <Metadata>
-    <Identity Id="%s" Version="%s" Publisher="%s" Language="en-US" />
-    <DisplayName>%s</DisplayName>
-    <Description>%s</Description>
-  </Metadata>
-</PackageManifest>`, ext.ID, ext.Version, ext.Publisher, ext.DisplayName, ext.Description)
-		w.Write([]byte(basicManifest))
+// statusWriter records the status code written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// ReadFrom keeps the sendfile path of the underlying writer for packages
+// served by http.ServeFile.
+func (sw *statusWriter) ReadFrom(r io.Reader) (int64, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return io.Copy(sw.ResponseWriter, r)
+}
+
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+func (s *Server) serveVSIXManifest(w http.ResponseWriter, r *http.Request, ext *models.Extension) {
+	if s.notModified(w, r, ext, vsixManifestPath) {
 		return
 	}
 
+	if err := s.streamFromVSIX(w, r, ext, vsixManifestPath, xmlContentType); err == nil {
+		return
+	}
+
+	if rc, size, err := s.extManager.OpenVSIXManifest(ext); err == nil {
+		defer rc.Close()
+		writeStream(w, r, rc, size, xmlContentType)
+		return
+	}
+
+	log.Printf("API: No vsixmanifest in %s, serving a generated one", ext.FilePath)
+	w.Header().Del("ETag")
+	manifest, err := s.extManager.BuildVSIXManifest(ext)
+	if err != nil {
+		log.Printf("API: Error generating vsixmanifest: %v", err)
+		s.writeError(w, http.StatusInternalServerError, "manifest_failed", "Failed to build manifest")
+		return
+	}
 	w.Header().Set("Content-Type", xmlContentType)
 	w.Write(manifest)
 }
 
-func (s *Server) serveEmptySignature(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", octetStreamContentType)
-	w.Write([]byte{})
+// signaturePath returns where the .sigzip of ext's package is stored: next
+// to the package, with the same name.
+func signaturePath(ext *models.Extension) string {
+	return strings.TrimSuffix(ext.FilePath, filepath.Ext(ext.FilePath)) + ".sigzip"
 }
 
-func (s *Server) serveEmptyPublicKey(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", octetStreamContentType)
-	w.Write([]byte{})
+// hasSignature reports whether a signature of ext is stored and
+// signatures.enabled is on.
+func (s *Server) hasSignature(ext *models.Extension) bool {
+	if !s.settings.Load().signaturesEnabled {
+		return false
+	}
+	_, err := s.extManager.Storage().Stat(signaturePath(ext))
+	return err == nil
 }
 
-func (s *Server) serveREADME(w http.ResponseWriter, ext *models.Extension) {
-	w.Header().Set("Content-Type", markdownContentType)
+// serveSignature serves the .sigzip of ext. Without one the request fails
+// rather than returning an empty body, which clients reject as an invalid
+// signature.
+func (s *Server) serveSignature(w http.ResponseWriter, r *http.Request, ext *models.Extension) {
+	if !s.hasSignature(ext) {
+		s.writeError(w, http.StatusNotFound, "signature_not_found", "Extension is not signed")
+		return
+	}
+	w.Header().Set("Content-Type", zipContentType)
+	s.extManager.Storage().Serve(w, r, signaturePath(ext))
+}
 
-	if ext.ReadmeContent != "" {
-		w.Write([]byte(ext.ReadmeContent))
-	} else {
-		readme, err := s.extractFileFromVSIX(ext.FilePath, readmePaths)
-		if err != nil {
-			message := fmt.Sprintf("# %s\n\nDescription for this extension is not available.\n\n**Publisher:** %s\n**Version:** %s",
-				ext.DisplayName, ext.Publisher, ext.Version)
-			w.Write([]byte(message))
+func (s *Server) serveREADME(w http.ResponseWriter, r *http.Request, ext *models.Extension) {
+	readme, err := s.extManager.ReadReadme(ext)
+	if err != nil {
+		readme = []byte(fmt.Sprintf("# %s\n\nDescription for this extension is not available.\n\n**Publisher:** %s\n**Version:** %s",
+			ext.DisplayName, ext.Publisher, ext.Version))
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	if prefersHTML(r.Header.Get("Accept")) {
+		html, err := utils.RenderMarkdown(readme)
+		if err == nil {
+			w.Header().Set("Content-Type", htmlContentType)
+			w.Write(html)
 			return
 		}
-		w.Write(readme)
+		log.Printf("API: Error rendering README of %s: %v", ext.ID, err)
 	}
+
+	w.Header().Set("Content-Type", markdownContentType)
+	w.Write(readme)
 }
 
-func (s *Server) serveLICENSE(w http.ResponseWriter, ext *models.Extension) {
-	license, err := s.extractFileFromVSIX(ext.FilePath, "extension/LICENSE.md")
+// prefersHTML reports whether the Accept header explicitly ranks text/html
+// above markdown. Wildcards alone keep the markdown default.
+func prefersHTML(accept string) bool {
+	htmlQuality, markdownQuality := 0.0, 0.0
+
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+
+		switch mediaType {
+		case "text/html":
+			htmlQuality = math.Max(htmlQuality, quality)
+		case "text/markdown", "text/*", "*/*":
+			markdownQuality = math.Max(markdownQuality, quality)
+		}
+	}
+
+	return htmlQuality > 0 && htmlQuality > markdownQuality
+}
+
+func (s *Server) serveLICENSE(w http.ResponseWriter, r *http.Request, ext *models.Extension) {
+	license, size, err := s.extManager.OpenLicense(ext)
 	if err != nil {
 		w.Header().Set("Content-Type", markdownContentType)
 		message := fmt.Sprintf("# License\n\nLicense information for extension **%s** is not available.\n\n**Publisher:** %s\n**Version:** %s",
@@ -654,73 +1554,65 @@ func (s *Server) serveLICENSE(w http.ResponseWriter, ext *models.Extension) {
 		w.Write([]byte(message))
 		return
 	}
+	defer license.Close()
 
-	w.Header().Set("Content-Type", markdownContentType)
-	w.Write(license)
+	writeStream(w, r, license, size, markdownContentType)
 }
 
-func (s *Server) serveIcon(w http.ResponseWriter, ext *models.Extension) {
+func (s *Server) serveIcon(w http.ResponseWriter, r *http.Request, ext *models.Extension) {
 	if ext.Icon == "" {
-		w.Header().Set("Content-Type", "text/plain")
-		message := fmt.Sprintf("Icon for extension %s is not available", ext.DisplayName)
-		w.Write([]byte(message))
+		s.servePlaceholderIcon(w, r, ext)
 		return
 	}
 
-	iconPath := fmt.Sprintf("extension/%s", ext.Icon)
-	icon, err := s.extractFileFromVSIX(ext.FilePath, iconPath)
-	if err != nil {
-		log.Printf("API: Error extracting icon: %v", err)
-		w.Header().Set("Content-Type", "text/plain")
-		message := fmt.Sprintf("Icon for extension %s not found", ext.DisplayName)
-		w.Write([]byte(message))
-		return
+	// Icons without a known file extension are sniffed rather than assumed
+	// to be PNG.
+	mimeType := utils.NewFileUtils().GetMimeTypeByExtension(ext.Icon)
+	if mimeType == utils.OctetStreamContentType {
+		mimeType = ""
 	}
 
-	fileExt := filepath.Ext(ext.Icon)
-	var mimeType string
-	switch strings.ToLower(fileExt) {
-	case ".png":
-		mimeType = "image/png"
-	case ".jpg", ".jpeg":
-		mimeType = "image/jpeg"
-	case ".gif":
-		mimeType = "image/gif"
-	case ".svg":
-		mimeType = "image/svg+xml"
-	default:
-		mimeType = "image/png"
+	iconPath := fmt.Sprintf("extension/%s", ext.Icon)
+	if err := s.streamFromVSIX(w, r, ext, iconPath, mimeType); err != nil {
+		log.Printf("API: Error extracting icon: %v", err)
+		s.servePlaceholderIcon(w, r, ext)
 	}
-
-	w.Header().Set("Content-Type", mimeType)
-	w.Write(icon)
 }
 
-func (s *Server) extractFileFromVSIX(vsixPath, filePath string) ([]byte, error) {
-	reader, err := zip.OpenReader(vsixPath)
+// streamFromVSIX copies a file from a .vsix archive to the response without
+// holding it in memory. An empty contentType is sniffed from the start of the
+// file. Nothing is written when the file cannot be opened, so callers can
+// still send a fallback.
+func (s *Server) streamFromVSIX(w http.ResponseWriter, r *http.Request, ext *models.Extension, filePath, contentType string) error {
+	rc, size, err := s.extManager.OpenVSIXEntry(ext, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open .vsix file: %w", err)
+		return err
+	}
+	defer rc.Close()
+
+	var body io.Reader = rc
+	if contentType == "" {
+		buffered := bufio.NewReaderSize(rc, 512)
+		head, _ := buffered.Peek(512)
+		contentType = utils.NewFileUtils().SniffContentType(head)
+		body = buffered
 	}
-	defer reader.Close()
-
-	for _, file := range reader.File {
-		if file.Name == filePath {
-			rc, err := file.Open()
-			if err != nil {
-				return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
-			}
-			defer rc.Close()
 
-			content, err := io.ReadAll(rc)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
-			}
+	writeStream(w, r, body, size, contentType)
+	return nil
+}
 
-			return content, nil
-		}
+// writeStream sends size bytes read from body. HEAD requests only get the
+// headers, so body is not read for them.
+func writeStream(w http.ResponseWriter, r *http.Request, body io.Reader, size int64, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("API: Error streaming response: %v", err)
 	}
-
-	return nil, fmt.Errorf("file %s not found in .vsix archive", filePath)
 }
 
 func (s *Server) handleExtensionAssets(w http.ResponseWriter, r *http.Request) {
@@ -729,17 +1621,21 @@ func (s *Server) handleExtensionAssets(w http.ResponseWriter, r *http.Request) {
 	filename := vars["filename"]
 
 	if extensionID == "" || filename == "" {
-		s.writeError(w, http.StatusBadRequest, "Invalid request parameters")
+		s.writeError(w, http.StatusBadRequest, "invalid_parameters", "Invalid request parameters")
 		return
 	}
 
-	assetsDir := filepath.Join(s.extManager.GetExtensionsDir(), "assets", extensionID)
+	assetsDir := filepath.Join(s.cfg.AssetsDir, extensionID)
 	filePath := filepath.Join(assetsDir, filename)
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		s.writeError(w, http.StatusNotFound, "Asset not found")
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		s.writeError(w, http.StatusNotFound, "asset_not_found", "Asset not found")
 		return
 	}
+	if err == nil {
+		extensions.TouchAsset(filePath, info)
+	}
 
 	contentType := "application/octet-stream"
 	fileExt := strings.ToLower(filepath.Ext(filename))
@@ -764,7 +1660,7 @@ func (s *Server) handleExtensionAssets(w http.ResponseWriter, r *http.Request) {
 	case ".webp":
 		contentType = "image/webp"
 	default:
-		contentType = s.detectContentType(filePath)
+		contentType = utils.NewFileUtils().DetectContentType(filePath)
 	}
 
 	w.Header().Set("Content-Type", contentType)
@@ -772,26 +1668,3 @@ func (s *Server) handleExtensionAssets(w http.ResponseWriter, r *http.Request) {
 
 	http.ServeFile(w, r, filePath)
 }
-
-func (s *Server) detectContentType(filePath string) string {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "application/octet-stream"
-	}
-	defer file.Close()
-
-	buffer := make([]byte, 512)
-	bytesRead, err := file.Read(buffer)
-	if err != nil && err != io.EOF {
-		return "application/octet-stream"
-	}
-
-	contentType := http.DetectContentType(buffer[:bytesRead])
-
-	if strings.Contains(string(buffer[:bytesRead]), "<?xml") ||
-		strings.Contains(string(buffer[:bytesRead]), "<svg") {
-		return "image/svg+xml; charset=utf-8"
-	}
-
-	return contentType
-}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"littlevsx/internal/database"
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/testutil"
+)
+
+// newTestServer returns a Server on a temporary catalog holding packages.
+// overrides sets further configuration keys.
+func newTestServer(t *testing.T, overrides map[string]interface{}, packages ...testutil.VSIX) (*Server, *extensions.Manager) {
+	t.Helper()
+	testutil.TempConfig(t, overrides)
+	m, err := extensions.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	if err := os.MkdirAll(m.GetExtensionsDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range packages {
+		addTestPackage(t, m, v)
+	}
+	return New(m, "http://gallery.test"), m
+}
+
+// addTestPackage writes v into the extensions directory and adds it to the
+// database, as download does.
+func addTestPackage(t testing.TB, m *extensions.Manager, v testutil.VSIX) string {
+	t.Helper()
+	path := filepath.Join(m.GetExtensionsDir(), v.FileName())
+	if err := v.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	ext, err := m.ReadExtensionInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.GetDB().UpsertExtension(database.ToDBExtension(ext)); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serve sends a request with an optional JSON body to s and returns the
+// recorded response.
+func serve(s *Server, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, target, nil)
+	} else {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(contentTypeHeader, jsonContentType)
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	s.Router().ServeHTTP(rec, req)
+	return rec
+}
+
+// galleryResponse is the part of an extensionquery response the tests read.
+type galleryResponse struct {
+	Results []struct {
+		Extensions []struct {
+			ExtensionID   string `json:"extensionId"`
+			ExtensionName string `json:"extensionName"`
+			Publisher     struct {
+				PublisherID string `json:"publisherId"`
+			} `json:"publisher"`
+			Versions []struct {
+				Version        string `json:"version"`
+				TargetPlatform string `json:"targetPlatform"`
+				Files          []struct {
+					AssetType string `json:"assetType"`
+					Source    string `json:"source"`
+				} `json:"files"`
+			} `json:"versions"`
+		} `json:"extensions"`
+		ResultMetadata []struct {
+			MetadataType  string `json:"metadataType"`
+			MetadataItems []struct {
+				Name  string `json:"name"`
+				Count int    `json:"count"`
+			} `json:"metadataItems"`
+		} `json:"resultMetadata"`
+	} `json:"results"`
+}
+
+// query posts an extensionquery to s and decodes the response.
+func query(t *testing.T, s *Server, body string, header map[string]string) galleryResponse {
+	t.Helper()
+	rec := serve(s, http.MethodPost, "/_apis/public/gallery/extensionquery", body, header)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("extensionquery answered %d: %s", rec.Code, rec.Body)
+	}
+	var response galleryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode %s: %v", rec.Body, err)
+	}
+	if len(response.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(response.Results))
+	}
+	return response
+}
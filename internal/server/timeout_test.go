@@ -0,0 +1,219 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestTimeoutMiddleware runs slow and fast handlers behind the router's
+// middleware with a short server.request_timeout.
+func TestTimeoutMiddleware(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	const slow = 4 * timeout
+
+	tests := []struct {
+		name       string
+		fileRoute  bool
+		handler    func(t *testing.T, rec *httptest.ResponseRecorder) http.HandlerFunc
+		wantStatus int
+		wantBody   string
+		wantHeader string
+	}{
+		{
+			name: "fast handler",
+			handler: func(*testing.T, *httptest.ResponseRecorder) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Test", "fast")
+					w.WriteHeader(http.StatusAccepted)
+					w.Write([]byte("done"))
+				}
+			},
+			wantStatus: http.StatusAccepted,
+			wantBody:   "done",
+			wantHeader: "fast",
+		},
+		{
+			name: "handler without body",
+			handler: func(*testing.T, *httptest.ResponseRecorder) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Test", "empty")
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: "empty",
+		},
+		{
+			name: "slow handler",
+			handler: func(*testing.T, *httptest.ResponseRecorder) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					time.Sleep(slow)
+					w.Header().Set("X-Test", "late")
+					w.Write([]byte("late"))
+				}
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "slow handler watching its context",
+			handler: func(*testing.T, *httptest.ResponseRecorder) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(slow):
+						w.Write([]byte("context was not cancelled"))
+					}
+				}
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "started response is streamed to the end",
+			handler: func(t *testing.T, rec *httptest.ResponseRecorder) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("first "))
+					if rec.Body.String() != "first " {
+						t.Errorf("write was buffered, client has %q", rec.Body)
+					}
+					time.Sleep(slow)
+					w.Write([]byte("second"))
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "first second",
+		},
+		{
+			name:      "slow file route",
+			fileRoute: true,
+			handler: func(*testing.T, *httptest.ResponseRecorder) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					time.Sleep(slow)
+					w.Write([]byte("package"))
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "package",
+		},
+		{
+			name: "panicking handler",
+			handler: func(*testing.T, *httptest.ResponseRecorder) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					panic("boom")
+				}
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, nil)
+			st := *s.settings.Load()
+			st.requestTimeout = timeout
+			s.settings.Store(&st)
+
+			rec := httptest.NewRecorder()
+			path := "/_test/handler"
+			s.router.HandleFunc(path, tt.handler(t, rec))
+			if tt.fileRoute {
+				s.fileRoutes[path] = true
+			}
+
+			s.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable {
+				var body struct {
+					Code string `json:"code"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != "timeout" {
+					t.Errorf("body = %s, want a JSON timeout error", rec.Body)
+				}
+				if got := rec.Header().Get(contentTypeHeader); got != jsonContentType {
+					t.Errorf("Content-Type = %q, want %q", got, jsonContentType)
+				}
+				if got := rec.Header().Get("X-Test"); got != "" {
+					t.Errorf("late handler header X-Test = %q reached the client", got)
+				}
+				// Let the abandoned handler finish before the next test
+				// replaces the global configuration.
+				time.Sleep(slow)
+				return
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+			}
+			if got := rec.Header().Get("X-Test"); got != tt.wantHeader {
+				t.Errorf("X-Test = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+// TestTimeoutMiddlewareLatePanic panics in a handler that started its
+// response and outlived server.request_timeout. The panic must reach
+// recoveryMiddleware, which drops the connection rather than appending an
+// error to the partial body.
+func TestTimeoutMiddlewareLatePanic(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	s, _ := newTestServer(t, nil)
+	st := *s.settings.Load()
+	st.requestTimeout = timeout
+	s.settings.Store(&st)
+
+	path := "/_test/handler"
+	s.router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		time.Sleep(4 * timeout)
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != http.ErrAbortHandler {
+				t.Errorf("recovered %v, want http.ErrAbortHandler", recovered)
+			}
+		}()
+		s.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		t.Error("the panic was swallowed")
+	}()
+	if rec.Body.String() != "partial" {
+		t.Errorf("body = %q, want only the partial response", rec.Body)
+	}
+}
+
+// TestFileRoutesAreExempt checks that package, asset and README asset
+// routes are registered as file routes and the JSON routes are not.
+func TestFileRoutesAreExempt(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	tests := []struct {
+		target string
+		exempt bool
+	}{
+		{"/_assets/acme/tool/1.0.0/" + vsixPackageAssetType, true},
+		{"/_assets/acme/tool/1.0.0/" + vsixSignatureAssetType, true},
+		{"/_assets/acme/tool/1.0.0/Microsoft.VisualStudio.Services.Icons.Default", true},
+		{"/_gallery/acme/tool/1.0.0/package.json", true},
+		{"/_assets/acme.tool/image.png", true},
+		{"/_gallery/acme/tool/latest", false},
+		{"/_catalog.json", false},
+		{"/_stats", false},
+	}
+	var exempt bool
+	s.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			exempt = s.isFileRoute(r)
+		})
+	})
+	for _, tt := range tests {
+		exempt = false
+		s.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if exempt != tt.exempt {
+			t.Errorf("%s: exempt = %v, want %v", tt.target, exempt, tt.exempt)
+		}
+	}
+}
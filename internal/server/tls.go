@@ -0,0 +1,52 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// certificateStore holds the certificate presented to TLS clients. It is read
+// for every handshake, so replacing it takes effect without restarting the
+// listener, e.g. after a mounted secret was rotated.
+type certificateStore struct {
+	certFile string
+	keyFile  string
+	current  atomic.Pointer[tls.Certificate]
+}
+
+func newCertificateStore(certFile, keyFile string) (*certificateStore, error) {
+	store := &certificateStore{certFile: certFile, keyFile: keyFile}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// load re-reads the certificate and key files. On failure the certificate in
+// use is kept.
+func (c *certificateStore) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate %s: %w", c.certFile, err)
+	}
+	c.current.Store(&cert)
+	return nil
+}
+
+func (c *certificateStore) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.current.Load(), nil
+}
+
+// reloadCertificate re-reads the TLS certificate on config reload.
+func (s *Server) reloadCertificate() {
+	if s.certificates == nil {
+		return
+	}
+	if err := s.certificates.load(); err != nil {
+		log.Printf("Config reload: %v, keeping current certificate", err)
+		return
+	}
+	log.Printf("Config reload: TLS certificate reloaded from %s", s.certificates.certFile)
+}
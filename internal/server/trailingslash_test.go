@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+// TestTrailingSlash requests the main endpoints with an extra trailing
+// slash in each server.trailing_slash mode.
+func TestTrailingSlash(t *testing.T) {
+	const queryBody = `{"filters":[{"criteria":[{"filterType":8,"value":"Microsoft.VisualStudio.Code"}]}],"flags":0}`
+	endpoints := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/_stats", ""},
+		{http.MethodGet, "/_health", ""},
+		{http.MethodGet, "/_metrics", ""},
+		{http.MethodGet, "/_catalog.json", ""},
+		{http.MethodGet, "/_gallery/acme/tool/latest", ""},
+		{http.MethodHead, "/_gallery/acme/tool/latest", ""},
+		{http.MethodGet, "/_gallery/acme/tool/1.0.0/package.json", ""},
+		{http.MethodGet, "/_assets/acme/tool/1.0.0/Microsoft.VisualStudio.Code.Manifest", ""},
+		{http.MethodPost, "/_apis/public/gallery/extensionquery", queryBody},
+	}
+
+	for _, mode := range []string{trailingSlashRedirect, trailingSlashIgnore, trailingSlashStrict} {
+		t.Run(mode, func(t *testing.T) {
+			s, _ := newTestServer(t, map[string]interface{}{"server.trailing_slash": mode},
+				testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"})
+
+			for _, e := range endpoints {
+				if rec := serve(s, e.method, e.path, e.body, nil); rec.Code != http.StatusOK {
+					t.Fatalf("%s %s = %d, want 200", e.method, e.path, rec.Code)
+				}
+
+				rec := serve(s, e.method, e.path+"/", e.body, nil)
+				switch mode {
+				case trailingSlashRedirect:
+					want := http.StatusMovedPermanently
+					if e.method == http.MethodPost {
+						want = http.StatusPermanentRedirect
+					}
+					if rec.Code != want || rec.Header().Get("Location") != e.path {
+						t.Errorf("%s %s/ = %d to %q, want %d to %s", e.method, e.path, rec.Code, rec.Header().Get("Location"), want, e.path)
+					}
+				case trailingSlashIgnore:
+					if rec.Code != http.StatusOK {
+						t.Errorf("%s %s/ = %d, want 200", e.method, e.path, rec.Code)
+					}
+				case trailingSlashStrict:
+					if rec.Code != http.StatusNotFound {
+						t.Errorf("%s %s/ = %d, want 404", e.method, e.path, rec.Code)
+					}
+				}
+			}
+
+			// Only paths a route serves are redirected, and the query is kept.
+			if rec := serve(s, http.MethodGet, "/_unknown/", "", nil); rec.Code != http.StatusNotFound {
+				t.Errorf("GET /_unknown/ = %d, want 404", rec.Code)
+			}
+			if mode == trailingSlashRedirect {
+				rec := serve(s, http.MethodGet, "/_catalog.json/?q=tool", "", nil)
+				if location := rec.Header().Get("Location"); location != "/_catalog.json?q=tool" {
+					t.Errorf("GET /_catalog.json/?q=tool redirects to %q, want /_catalog.json?q=tool", location)
+				}
+			}
+		})
+	}
+}
+
+// TestPreflight sends OPTIONS to registered, unknown and trailing-slash
+// paths, which all get the same answer from the catch-all route.
+func TestPreflight(t *testing.T) {
+	paths := []string{
+		"/",
+		"/_stats",
+		"/_stats/",
+		"/_apis/public/gallery/extensionquery",
+		"/_apis/public/gallery/extensionquery/",
+		"/_gallery/acme/tool/latest",
+		"/_assets/acme/tool/1.0.0/Microsoft.VisualStudio.Code.Manifest",
+		"/_unknown",
+	}
+	for _, mode := range []string{trailingSlashRedirect, trailingSlashIgnore, trailingSlashStrict} {
+		t.Run(mode, func(t *testing.T) {
+			s, _ := newTestServer(t, map[string]interface{}{"server.trailing_slash": mode})
+			for _, path := range paths {
+				rec := serve(s, http.MethodOptions, path, "", map[string]string{
+					"Origin":                        "https://vscode.dev",
+					"Access-Control-Request-Method": http.MethodPost,
+				})
+				if rec.Code != http.StatusNoContent {
+					t.Errorf("OPTIONS %s = %d, want 204", path, rec.Code)
+				}
+				if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+					t.Errorf("OPTIONS %s: Access-Control-Allow-Origin = %q, want *", path, got)
+				}
+				if rec.Body.Len() != 0 {
+					t.Errorf("OPTIONS %s has a %d byte body, want none", path, rec.Body.Len())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+
+	"littlevsx/internal/testutil"
+)
+
+// TestOlderVersionsAreServed stores three versions of an extension and
+// checks that each can be downloaded and that queries list all of them
+// unless the client asks for the latest only.
+func TestOlderVersionsAreServed(t *testing.T) {
+	s, m := newTestServer(t, nil)
+	files := make(map[string][]byte)
+	// Stored out of order, so the latest is decided by version, not by
+	// insertion.
+	for _, version := range []string{"1.1.0", "2.0.0", "1.0.0"} {
+		path := addTestPackage(t, m, testutil.VSIX{Publisher: "acme", Name: "tool", Version: version})
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[version] = content
+	}
+
+	for version, content := range files {
+		rec := serve(s, http.MethodGet, "/_assets/acme/tool/"+version+"/"+vsixPackageAssetType, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status %d: %s", version, rec.Code, rec.Body)
+			continue
+		}
+		if !bytes.Equal(rec.Body.Bytes(), content) {
+			t.Errorf("%s: served %s", version, rec.Header().Get(contentDispositionHeader))
+		}
+	}
+
+	tests := []struct {
+		name  string
+		flags int
+		want  []string
+	}{
+		{"all versions", 0, []string{"2.0.0", "1.1.0", "1.0.0"}},
+		{"latest only", queryFlagLatestVersionOnly, []string{"2.0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"flags":` + strconv.Itoa(tt.flags) + `,"filters":[{"criteria":[{"filterType":7,"value":"acme.tool"}]}]}`
+			extensions := query(t, s, body, nil).Results[0].Extensions
+			if len(extensions) != 1 {
+				t.Fatalf("got %d extensions, want 1", len(extensions))
+			}
+			var versions []string
+			for _, version := range extensions[0].Versions {
+				versions = append(versions, version.Version)
+			}
+			if !slices.Equal(versions, tt.want) {
+				t.Errorf("versions = %v, want %v", versions, tt.want)
+			}
+		})
+	}
+}
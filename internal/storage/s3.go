@@ -0,0 +1,481 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"littlevsx/internal/config"
+)
+
+const (
+	// s3BlockSize is the size of the ranges fetched when reading archives, so
+	// that the small reads of the zip reader do not each cost a request.
+	s3BlockSize    = 256 << 10
+	s3CachedBlocks = 8
+
+	// s3StatTTL bounds how long object metadata is cached. Packages are
+	// immutable, the cache only saves a HEAD request per asset request.
+	s3StatTTL = time.Minute
+
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// s3Storage reads packages from an S3-compatible object store such as AWS S3
+// or MinIO. Objects are laid out like extensions.directory: the package
+// "extensions/a/b.vsix" is read from "<prefix>/a/b.vsix" when
+// extensions.directory is "extensions".
+type s3Storage struct {
+	client    *http.Client
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	prefix    string
+	accessKey string
+	pathStyle bool
+	baseDir   string
+
+	// secretKey is re-read from secretKeyFile by Reload, so a rotated
+	// secret is picked up on SIGHUP.
+	secretKeyFile string
+	secretKey     atomic.Pointer[string]
+
+	statMu sync.Mutex
+	stats  map[string]cachedStat
+}
+
+type cachedStat struct {
+	info    objectInfo
+	expires time.Time
+}
+
+func newS3(cfg config.Config) (*s3Storage, error) {
+	if cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("storage.s3.bucket is required for the s3 storage backend")
+	}
+
+	endpoint := cfg.S3Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.S3Region)
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Host == "" {
+		return nil, fmt.Errorf("invalid storage.s3.endpoint %q", endpoint)
+	}
+
+	accessKey := cfg.S3AccessKey
+	if accessKey == "" {
+		accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	secretKey := cfg.S3SecretKey
+	secretKeyFile := ""
+	if secretKey == "" && cfg.S3SecretKeyFile != "" {
+		secretKeyFile = cfg.S3SecretKeyFile
+		if secretKey, err = readSecretFile(secretKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if secretKey == "" {
+		secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	baseDir, err := filepath.Abs(cfg.ExtensionsDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extensions.directory: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
+	s := &s3Storage{
+		client:        &http.Client{Transport: transport},
+		endpoint:      endpointURL,
+		bucket:        cfg.S3Bucket,
+		region:        cfg.S3Region,
+		prefix:        strings.Trim(cfg.S3Prefix, "/"),
+		accessKey:     accessKey,
+		pathStyle:     cfg.S3PathStyle,
+		secretKeyFile: secretKeyFile,
+		baseDir:       baseDir,
+		stats:         make(map[string]cachedStat),
+	}
+	s.secretKey.Store(&secretKey)
+	return s, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read storage.s3.secret_key_file: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Reload re-reads storage.s3.secret_key_file when the secret key comes from
+// it. On error the current key is kept.
+func (s *s3Storage) Reload() (bool, error) {
+	if s.secretKeyFile == "" {
+		return false, nil
+	}
+	secretKey, err := readSecretFile(s.secretKeyFile)
+	if err != nil {
+		return false, err
+	}
+	if secretKey == "" {
+		secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	s.secretKey.Store(&secretKey)
+	return true, nil
+}
+
+// key maps a package path to its object key. Paths outside
+// extensions.directory are looked up by file name.
+func (s *s3Storage) key(path string) string {
+	rel := filepath.Base(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		if r, err := filepath.Rel(s.baseDir, abs); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			rel = r
+		}
+	}
+
+	key := filepath.ToSlash(rel)
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	return key
+}
+
+func (s *s3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	path := "/" + key
+	if s.pathStyle {
+		path = "/" + s.bucket + path
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + path
+	u.RawPath = uriEncode(u.Path)
+	return &u
+}
+
+// request sends a signed request for the object at key.
+func (s *s3Storage) request(ctx context.Context, method, key, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.URL = s.objectURL(key)
+	req.Host = req.URL.Host
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, &fs.PathError{Op: strings.ToLower(method), Path: key, Err: fs.ErrNotExist}
+	case resp.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: invalid status %d: %s", method, key, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 to req. Without credentials the
+// request is sent anonymously, which works for public buckets.
+func (s *s3Storage) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+
+	secretKey := *s.secretKey.Load()
+	if s.accessKey == "" || secretKey == "" {
+		return
+	}
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + emptyPayloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode escapes a path the way Signature Version 4 expects: everything
+// but unreserved characters and slashes is percent-encoded.
+func uriEncode(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (s *s3Storage) Stat(path string) (fs.FileInfo, error) {
+	info, err := s.stat(context.Background(), s.key(path))
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *s3Storage) stat(ctx context.Context, key string) (objectInfo, error) {
+	s.statMu.Lock()
+	cached, ok := s.stats[key]
+	s.statMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.info, nil
+	}
+
+	resp, err := s.request(ctx, http.MethodHead, key, "")
+	if err != nil {
+		return objectInfo{}, err
+	}
+	resp.Body.Close()
+
+	info := objectInfo{name: filepath.Base(key), size: resp.ContentLength}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.modTime = modTime
+	}
+
+	s.statMu.Lock()
+	s.stats[key] = cachedStat{info: info, expires: time.Now().Add(s3StatTTL)}
+	s.statMu.Unlock()
+
+	return info, nil
+}
+
+func (s *s3Storage) Open(path string) (File, error) {
+	return s.open(context.Background(), path)
+}
+
+func (s *s3Storage) open(ctx context.Context, path string) (*s3Object, error) {
+	key := s.key(path)
+	info, err := s.stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{storage: s, ctx: ctx, key: key, info: info, blocks: make(map[int64][]byte)}, nil
+}
+
+// Serve streams the object through the server rather than redirecting to
+// the store, so clients never need access to the bucket.
+func (s *s3Storage) Serve(w http.ResponseWriter, r *http.Request, path string) {
+	object, err := s.open(r.Context(), path)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to open package", http.StatusBadGateway)
+		return
+	}
+	defer object.Close()
+
+	http.ServeContent(w, r, object.info.name, object.info.modTime, object)
+}
+
+type objectInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i objectInfo) Name() string       { return i.name }
+func (i objectInfo) Size() int64        { return i.size }
+func (i objectInfo) Mode() fs.FileMode  { return 0444 }
+func (i objectInfo) ModTime() time.Time { return i.modTime }
+func (i objectInfo) IsDir() bool        { return false }
+func (i objectInfo) Sys() interface{}   { return nil }
+
+// s3Object reads an object with ranged GET requests. Sequential reads share
+// one streaming response; ReadAt fetches and caches fixed-size blocks.
+type s3Object struct {
+	storage *s3Storage
+	ctx     context.Context
+	key     string
+	info    objectInfo
+
+	pos     int64
+	body    io.ReadCloser
+	bodyPos int64
+
+	blocksMu sync.Mutex
+	blocks   map[int64][]byte
+	order    []int64
+}
+
+func (o *s3Object) Stat() (fs.FileInfo, error) {
+	return o.info, nil
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.pos >= o.info.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil || o.bodyPos != o.pos {
+		o.closeBody()
+		resp, err := o.storage.request(o.ctx, http.MethodGet, o.key, fmt.Sprintf("bytes=%d-", o.pos))
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusPartialContent && o.pos != 0 {
+			resp.Body.Close()
+			return 0, fmt.Errorf("read %s: range requests are not supported by the store", o.key)
+		}
+		o.body, o.bodyPos = resp.Body, o.pos
+	}
+
+	n, err := o.body.Read(p)
+	o.pos += int64(n)
+	o.bodyPos = o.pos
+	if err == io.EOF && o.pos < o.info.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.pos
+	case io.SeekEnd:
+		offset += o.info.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("seek %s: negative position", o.key)
+	}
+	o.pos = offset
+	return offset, nil
+}
+
+func (o *s3Object) ReadAt(p []byte, off int64) (int, error) {
+	if off >= o.info.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < o.info.size {
+		index := off / s3BlockSize
+		block, err := o.block(index)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], block[off-index*s3BlockSize:])
+		n += copied
+		off += int64(copied)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (o *s3Object) block(index int64) ([]byte, error) {
+	o.blocksMu.Lock()
+	block, ok := o.blocks[index]
+	o.blocksMu.Unlock()
+	if ok {
+		return block, nil
+	}
+
+	start := index * s3BlockSize
+	end := start + s3BlockSize
+	if end > o.info.size {
+		end = o.info.size
+	}
+
+	resp, err := o.storage.request(o.ctx, http.MethodGet, o.key, "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end-1, 10))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent && start != 0 {
+		return nil, fmt.Errorf("read %s: range requests are not supported by the store", o.key)
+	}
+
+	block = make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, block); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", o.key, err)
+	}
+
+	o.blocksMu.Lock()
+	if len(o.order) >= s3CachedBlocks {
+		delete(o.blocks, o.order[0])
+		o.order = o.order[1:]
+	}
+	o.blocks[index] = block
+	o.order = append(o.order, index)
+	o.blocksMu.Unlock()
+
+	return block, nil
+}
+
+func (o *s3Object) closeBody() {
+	if o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+}
+
+func (o *s3Object) Close() error {
+	o.closeBody()
+	return nil
+}
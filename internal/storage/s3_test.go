@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"littlevsx/internal/config"
+)
+
+// TestS3ReloadSecretKey rotates the secret key file and checks that requests
+// are signed with the new key after Reload, and that a failed read keeps
+// the current one.
+func TestS3ReloadSecretKey(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := newS3(config.Config{
+		S3Endpoint:      "http://minio.test",
+		S3Region:        "us-east-1",
+		S3Bucket:        "vsix",
+		S3AccessKey:     "access",
+		S3SecretKeyFile: secretFile,
+		ExtensionsDir:   "extensions",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := func() string {
+		req, _ := http.NewRequest(http.MethodGet, "http://minio.test/vsix/tool.vsix", nil)
+		s.sign(req, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+		return req.Header.Get("Authorization")
+	}
+	first := signature()
+	if !strings.Contains(first, "Credential=access/") {
+		t.Fatalf("Authorization = %q, want a signature", first)
+	}
+
+	if err := os.WriteFile(secretFile, []byte("second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := s.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload = %v, %v", reloaded, err)
+	}
+	if *s.secretKey.Load() != "second" {
+		t.Errorf("secret key = %q after reload, want second", *s.secretKey.Load())
+	}
+	rotated := signature()
+	if rotated == first {
+		t.Error("requests are still signed with the old secret key")
+	}
+
+	if err := os.Remove(secretFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reload(); err == nil {
+		t.Error("Reload of a missing file succeeded")
+	}
+	if signature() != rotated {
+		t.Error("a failed reload changed the secret key")
+	}
+}
+
+func TestS3ReloadWithoutSecretFile(t *testing.T) {
+	s, err := newS3(config.Config{S3Endpoint: "http://minio.test", S3Bucket: "vsix", S3SecretKey: "inline", ExtensionsDir: "extensions"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := s.Reload(); reloaded || err != nil {
+		t.Errorf("Reload = %v, %v, want nothing to reload", reloaded, err)
+	}
+	if *s.secretKey.Load() != "inline" {
+		t.Errorf("secret key = %q, want inline", *s.secretKey.Load())
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+
+	"littlevsx/internal/config"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// File is an open .vsix package. Archives are read through ReaderAt, whole
+// downloads through Read and Seek.
+type File interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+	Stat() (fs.FileInfo, error)
+}
+
+// Storage gives access to the .vsix packages referenced by the database.
+// Paths are the file paths stored for extensions, e.g.
+// "extensions/python-2024.1.0.vsix".
+type Storage interface {
+	// Open opens the package at path for reading.
+	Open(path string) (File, error)
+
+	// Stat returns the size and modification time of the package at path.
+	Stat(path string) (fs.FileInfo, error)
+
+	// Serve writes the package at path to the response, answering range and
+	// conditional requests.
+	Serve(w http.ResponseWriter, r *http.Request, path string)
+}
+
+// Reloader is implemented by storages holding secrets read from files.
+// Reload reads them again and reports whether there were any.
+type Reloader interface {
+	Reload() (bool, error)
+}
+
+// New returns the storage selected by storage.backend.
+func New(cfg config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "", BackendLocal:
+		return Local{}, nil
+	case BackendS3:
+		return newS3(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage.backend %q (expected %s or %s)", cfg.StorageBackend, BackendLocal, BackendS3)
+	}
+}
+
+// Local reads packages from the local filesystem.
+type Local struct{}
+
+func (Local) Open(path string) (File, error) {
+	return os.Open(path)
+}
+
+func (Local) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (Local) Serve(w http.ResponseWriter, r *http.Request, path string) {
+	http.ServeFile(w, r, path)
+}
+
+// Archive is a .vsix package opened as a zip archive.
+type Archive struct {
+	*zip.Reader
+	file File
+}
+
+// OpenArchive opens the package at path as a zip archive.
+func OpenArchive(s Storage, path string) (*Archive, error) {
+	file, err := s.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open .vsix file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat .vsix file: %w", err)
+	}
+
+	reader, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open .vsix file: %w", err)
+	}
+	return &Archive{Reader: reader, file: file}, nil
+}
+
+func (a *Archive) Close() error {
+	return a.file.Close()
+}
+
+// OpenEntry opens a file inside the package at path for streaming and
+// returns its uncompressed size. Closing the reader also closes the archive.
+func OpenEntry(s Storage, path, entry string) (io.ReadCloser, int64, error) {
+	archive, err := OpenArchive(s, path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, file := range archive.File {
+		if file.Name != entry || !IsRegular(file) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			archive.Close()
+			return nil, 0, fmt.Errorf("failed to open file %s: %w", entry, err)
+		}
+		return &entryReader{ReadCloser: rc, archive: archive}, int64(file.UncompressedSize64), nil
+	}
+
+	archive.Close()
+	return nil, 0, fmt.Errorf("file %s not found in .vsix archive", entry)
+}
+
+// IsRegular reports whether a zip entry is a regular file. Directories and
+// symlinks are never served: opening them yields an empty body or the link
+// target's path instead of content.
+func IsRegular(file *zip.File) bool {
+	return file.Mode().IsRegular()
+}
+
+// ReadEntry returns the content of a file inside the package at path.
+func ReadEntry(s Storage, path, entry string) ([]byte, error) {
+	rc, _, err := OpenEntry(s, path, entry)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", entry, err)
+	}
+	return content, nil
+}
+
+type entryReader struct {
+	io.ReadCloser
+	archive *Archive
+}
+
+func (r *entryReader) Close() error {
+	err := r.ReadCloser.Close()
+	if archiveErr := r.archive.Close(); err == nil {
+		err = archiveErr
+	}
+	return err
+}
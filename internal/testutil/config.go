@@ -0,0 +1,33 @@
+package testutil
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// TempConfig points the database, extensions and assets directories at a
+// temporary directory for the duration of the test and returns that
+// directory. overrides sets further configuration keys. Settings are
+// global, so tests using TempConfig must not run in parallel.
+func TempConfig(tb testing.TB, overrides map[string]interface{}) string {
+	tb.Helper()
+	dir := tb.TempDir()
+
+	settings := map[string]interface{}{
+		"database.path":        filepath.Join(dir, "littlevsx.db"),
+		"extensions.directory": filepath.Join(dir, "extensions"),
+		"assets.directory":     filepath.Join(dir, "assets"),
+	}
+	for key, value := range overrides {
+		settings[key] = value
+	}
+
+	for key, value := range settings {
+		previous := viper.Get(key)
+		viper.Set(key, value)
+		tb.Cleanup(func() { viper.Set(key, previous) })
+	}
+	return dir
+}
@@ -0,0 +1,225 @@
+// Package testutil builds .vsix packages in memory so parsers and handlers
+// can be exercised without downloading real extensions.
+package testutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const preReleaseProperty = "Microsoft.VisualStudio.Code.PreRelease"
+
+// PNG is a valid 1x1 transparent PNG image, usable as an extension icon.
+var PNG = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d,
+	0x49, 0x48, 0x44, 0x52, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4, 0x89, 0x00, 0x00, 0x00,
+	0x0d, 0x49, 0x44, 0x41, 0x54, 0x78, 0x9c, 0x63, 0x00, 0x01, 0x00, 0x00,
+	0x05, 0x00, 0x01, 0x0d, 0x0a, 0x2d, 0xb4, 0x00, 0x00, 0x00, 0x00, 0x49,
+	0x45, 0x4e, 0x44, 0xae, 0x42, 0x60, 0x82,
+}
+
+// VSIX describes the contents of a .vsix package. Zero values are left out of
+// the archive, so a VSIX with only Publisher, Name and Version produces the
+// smallest package LittleVSX accepts.
+type VSIX struct {
+	Publisher      string
+	Name           string
+	Version        string
+	DisplayName    string
+	Description    string
+	TargetPlatform string
+	PreRelease     bool
+
+	// PackageJSON holds additional package.json fields. They override the
+	// fields generated from the values above.
+	PackageJSON map[string]interface{}
+
+	// Readme is written to extension/README.md and Icon to extension/icon.png.
+	Readme string
+	Icon   []byte
+
+	// Manifest replaces the generated extension.vsixmanifest when set.
+	Manifest string
+
+	// NLS maps a language to its localized strings. The "" language is
+	// written to package.nls.json, any other to package.nls.<language>.json.
+	NLS map[string]map[string]string
+
+	// Files holds extra archive entries keyed by path, e.g. "extension/CHANGELOG.md".
+	Files map[string][]byte
+}
+
+// ForPlatforms returns one copy of v per target platform, for building the
+// separate packages of a platform-specific extension.
+func (v VSIX) ForPlatforms(platforms ...string) []VSIX {
+	packages := make([]VSIX, 0, len(platforms))
+	for _, platform := range platforms {
+		p := v
+		p.TargetPlatform = platform
+		packages = append(packages, p)
+	}
+	return packages
+}
+
+// FileName returns the file name marketplaces use for the package, e.g.
+// "name-1.0.0.vsix" or "name-1.0.0@linux-x64.vsix".
+func (v VSIX) FileName() string {
+	if v.TargetPlatform != "" && v.TargetPlatform != "universal" {
+		return fmt.Sprintf("%s-%s@%s.vsix", v.Name, v.Version, v.TargetPlatform)
+	}
+	return fmt.Sprintf("%s-%s.vsix", v.Name, v.Version)
+}
+
+// Bytes builds the .vsix archive.
+func (v VSIX) Bytes() ([]byte, error) {
+	packageJSON, err := v.packageJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode package.json: %w", err)
+	}
+
+	manifest := v.Manifest
+	if manifest == "" {
+		if manifest, err = v.manifest(); err != nil {
+			return nil, fmt.Errorf("failed to encode vsixmanifest: %w", err)
+		}
+	}
+
+	files := map[string][]byte{
+		"extension/package.json": packageJSON,
+		"extension.vsixmanifest": []byte(manifest),
+	}
+	if v.Readme != "" {
+		files["extension/README.md"] = []byte(v.Readme)
+	}
+	if v.Icon != nil {
+		files["extension/icon.png"] = v.Icon
+	}
+	for language, strings := range v.NLS {
+		name := "extension/package.nls.json"
+		if language != "" {
+			name = fmt.Sprintf("extension/package.nls.%s.json", language)
+		}
+		content, err := json.Marshal(strings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		files[name] = content
+	}
+	for name, content := range v.Files {
+		files[name] = content
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile builds the archive and writes it to path.
+func (v VSIX) WriteFile(path string) error {
+	content, err := v.Bytes()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// WriteTemp writes the archive into a temporary directory that is removed
+// when the test finishes and returns its path.
+func (v VSIX) WriteTemp(tb testing.TB) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), v.FileName())
+	if err := v.WriteFile(path); err != nil {
+		tb.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func (v VSIX) packageJSON() ([]byte, error) {
+	pkg := map[string]interface{}{
+		"name":    v.Name,
+		"version": v.Version,
+		"engines": map[string]string{"vscode": "^1.70.0"},
+	}
+	if v.Publisher != "" {
+		pkg["publisher"] = v.Publisher
+	}
+	if v.DisplayName != "" {
+		pkg["displayName"] = v.DisplayName
+	}
+	if v.Description != "" {
+		pkg["description"] = v.Description
+	}
+	if v.Icon != nil {
+		pkg["icon"] = "icon.png"
+	}
+	for key, value := range v.PackageJSON {
+		pkg[key] = value
+	}
+	return json.MarshalIndent(pkg, "", "  ")
+}
+
+type manifestProperty struct {
+	ID    string `xml:"Id,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+type manifestXML struct {
+	XMLName  xml.Name `xml:"PackageManifest"`
+	Version  string   `xml:"Version,attr"`
+	Metadata struct {
+		Identity struct {
+			Language       string `xml:"Language,attr"`
+			ID             string `xml:"Id,attr"`
+			Version        string `xml:"Version,attr"`
+			Publisher      string `xml:"Publisher,attr"`
+			TargetPlatform string `xml:"TargetPlatform,attr,omitempty"`
+		} `xml:"Identity"`
+		DisplayName string             `xml:"DisplayName,omitempty"`
+		Description string             `xml:"Description,omitempty"`
+		Properties  []manifestProperty `xml:"Properties>Property,omitempty"`
+	} `xml:"Metadata"`
+}
+
+func (v VSIX) manifest() (string, error) {
+	var m manifestXML
+	m.Version = "2.0.0"
+	m.Metadata.Identity.Language = "en-US"
+	m.Metadata.Identity.ID = v.Name
+	m.Metadata.Identity.Version = v.Version
+	m.Metadata.Identity.Publisher = v.Publisher
+	m.Metadata.Identity.TargetPlatform = v.TargetPlatform
+	m.Metadata.DisplayName = v.DisplayName
+	m.Metadata.Description = v.Description
+	if v.PreRelease {
+		m.Metadata.Properties = append(m.Metadata.Properties, manifestProperty{
+			ID:    preReleaseProperty,
+			Value: strconv.FormatBool(v.PreRelease),
+		})
+	}
+
+	content, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(content), nil
+}
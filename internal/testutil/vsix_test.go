@@ -0,0 +1,198 @@
+package testutil_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"littlevsx/internal/extensions"
+	"littlevsx/internal/testutil"
+)
+
+func TestVSIXReadBack(t *testing.T) {
+	testutil.TempConfig(t, nil)
+	manager, err := extensions.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	tests := []struct {
+		name            string
+		vsix            testutil.VSIX
+		wantID          string
+		wantDisplayName string
+		wantPlatform    string
+		wantPreRelease  bool
+		wantReadme      string
+		wantIcon        string
+	}{
+		{
+			name:            "minimal",
+			vsix:            testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"},
+			wantID:          "acme.tool",
+			wantDisplayName: "",
+			wantPlatform:    "universal",
+		},
+		{
+			name: "readme and icon",
+			vsix: testutil.VSIX{
+				Publisher: "acme", Name: "tool", Version: "1.0.0", DisplayName: "Tool",
+				Readme: "# Tool", Icon: testutil.PNG,
+			},
+			wantID:          "acme.tool",
+			wantDisplayName: "Tool",
+			wantPlatform:    "universal",
+			wantReadme:      "# Tool",
+			wantIcon:        "icon.png",
+		},
+		{
+			name: "localized",
+			vsix: testutil.VSIX{
+				Publisher: "acme", Name: "tool", Version: "1.0.0", DisplayName: "%displayName%",
+				NLS: map[string]map[string]string{
+					"":   {"displayName": "Localized Tool"},
+					"de": {"displayName": "Lokalisiertes Werkzeug"},
+				},
+			},
+			wantID:          "acme.tool",
+			wantDisplayName: "Localized Tool",
+			wantPlatform:    "universal",
+		},
+		{
+			name:            "platform build",
+			vsix:            testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0", TargetPlatform: "linux-x64"},
+			wantID:          "acme.tool",
+			wantDisplayName: "",
+			wantPlatform:    "linux-x64",
+		},
+		{
+			name:            "pre-release",
+			vsix:            testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.1.0", PreRelease: true},
+			wantID:          "acme.tool",
+			wantDisplayName: "",
+			wantPlatform:    "universal",
+			wantPreRelease:  true,
+		},
+		{
+			name: "publisher from manifest only",
+			vsix: testutil.VSIX{
+				Publisher: "acme", Name: "tool", Version: "1.0.0",
+				PackageJSON: map[string]interface{}{"publisher": ""},
+			},
+			wantID:       "acme.tool",
+			wantPlatform: "universal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ext, err := manager.ReadExtensionInfo(tt.vsix.WriteTemp(t))
+			if err != nil {
+				t.Fatalf("ReadExtensionInfo: %v", err)
+			}
+			if ext.ID != tt.wantID {
+				t.Errorf("ID = %q, want %q", ext.ID, tt.wantID)
+			}
+			if ext.Version != tt.vsix.Version {
+				t.Errorf("Version = %q, want %q", ext.Version, tt.vsix.Version)
+			}
+			if ext.DisplayName != tt.wantDisplayName {
+				t.Errorf("DisplayName = %q, want %q", ext.DisplayName, tt.wantDisplayName)
+			}
+			if ext.TargetPlatform != tt.wantPlatform {
+				t.Errorf("TargetPlatform = %q, want %q", ext.TargetPlatform, tt.wantPlatform)
+			}
+			if ext.PreRelease != tt.wantPreRelease {
+				t.Errorf("PreRelease = %v, want %v", ext.PreRelease, tt.wantPreRelease)
+			}
+			if ext.ReadmeContent != tt.wantReadme {
+				t.Errorf("ReadmeContent = %q, want %q", ext.ReadmeContent, tt.wantReadme)
+			}
+			if ext.Icon != tt.wantIcon {
+				t.Errorf("Icon = %q, want %q", ext.Icon, tt.wantIcon)
+			}
+			if err := manager.ValidateVSIX(ext.FilePath); err != nil {
+				t.Errorf("ValidateVSIX: %v", err)
+			}
+		})
+	}
+}
+
+func TestVSIXFileName(t *testing.T) {
+	tests := []struct {
+		platform string
+		want     string
+	}{
+		{"", "tool-1.0.0.vsix"},
+		{"universal", "tool-1.0.0.vsix"},
+		{"linux-x64", "tool-1.0.0@linux-x64.vsix"},
+	}
+	for _, tt := range tests {
+		v := testutil.VSIX{Name: "tool", Version: "1.0.0", TargetPlatform: tt.platform}
+		if got := v.FileName(); got != tt.want {
+			t.Errorf("FileName() with platform %q = %q, want %q", tt.platform, got, tt.want)
+		}
+	}
+}
+
+func TestVSIXForPlatforms(t *testing.T) {
+	base := testutil.VSIX{Publisher: "acme", Name: "tool", Version: "1.0.0"}
+	packages := base.ForPlatforms("linux-x64", "win32-x64")
+	if len(packages) != 2 {
+		t.Fatalf("got %d packages, want 2", len(packages))
+	}
+	dir := t.TempDir()
+	for i, want := range []string{"linux-x64", "win32-x64"} {
+		if packages[i].TargetPlatform != want {
+			t.Errorf("package %d has platform %q, want %q", i, packages[i].TargetPlatform, want)
+		}
+		if err := packages[i].WriteFile(filepath.Join(dir, packages[i].FileName())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if base.TargetPlatform != "" {
+		t.Errorf("ForPlatforms changed the receiver to %q", base.TargetPlatform)
+	}
+}
+
+func TestVSIXFiles(t *testing.T) {
+	content, err := testutil.VSIX{
+		Publisher: "acme", Name: "tool", Version: "1.0.0",
+		Manifest: "<PackageManifest/>",
+		Files:    map[string][]byte{"extension/CHANGELOG.md": []byte("## 1.0.0")},
+	}.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"extension/CHANGELOG.md": "## 1.0.0",
+		"extension.vsixmanifest": "<PackageManifest/>",
+	}
+	for _, file := range reader.File {
+		expected, ok := want[file.Name]
+		if !ok {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(got) != expected {
+			t.Errorf("%s = %q, want %q", file.Name, got, expected)
+		}
+		delete(want, file.Name)
+	}
+	for name := range want {
+		t.Errorf("archive has no %s", name)
+	}
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EngineRange is the span of VS Code versions an engines.vscode value
+// accepts. Min is inclusive and Max exclusive; an empty bound is open.
+type EngineRange struct {
+	Min string
+	Max string
+}
+
+// ParseEngineRange parses an engines.vscode value the way VS Code reads it:
+// "^1.80.0", ">=1.80.0", "~1.80.0", "1.80.x" and "*" are understood, and
+// wildcard parts count as 0. A caret range ends at the next major version
+// and a tilde range at the next minor version.
+func ParseEngineRange(engine string) EngineRange {
+	engine = strings.TrimSpace(engine)
+	if engine == "" || engine == "*" || engine == "x" {
+		return EngineRange{}
+	}
+
+	upper := ""
+	switch {
+	case strings.HasPrefix(engine, "^"):
+		upper = "^"
+	case strings.HasPrefix(engine, "~"):
+		upper = "~"
+	}
+	version := strings.TrimLeft(engine, "^~>=v ")
+
+	parts := strings.Split(version, ".")
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			parts[i] = "0"
+		}
+	}
+	r := EngineRange{Min: strings.Join(parts, ".")}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return r
+	}
+	switch {
+	case upper == "^" && major > 0:
+		r.Max = strconv.Itoa(major+1) + ".0.0"
+	case upper == "~" && len(parts) > 1:
+		if minor, err := strconv.Atoi(parts[1]); err == nil {
+			r.Max = strconv.Itoa(major) + "." + strconv.Itoa(minor+1) + ".0"
+		}
+	}
+	return r
+}
+
+// Allows reports whether a VS Code of the given version satisfies the range.
+// Pre-release tags of the client version, e.g. "-insider", are ignored.
+func (r EngineRange) Allows(version string) bool {
+	core, _ := splitVersion(version)
+	if r.Min != "" && CompareVersions(core, r.Min) < 0 {
+		return false
+	}
+	if r.Max != "" && CompareVersions(core, r.Max) >= 0 {
+		return false
+	}
+	return true
+}
@@ -1,9 +1,10 @@
 package utils
 
 import (
-	"archive/zip"
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,33 +16,8 @@ func NewFileUtils() *FileUtils {
 	return &FileUtils{}
 }
 
-func (fu *FileUtils) ExtractFileFromVSIX(vsixPath, filePath string) ([]byte, error) {
-	reader, err := zip.OpenReader(vsixPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open .vsix file: %w", err)
-	}
-	defer reader.Close()
-
-	for _, file := range reader.File {
-		if file.Name == filePath {
-			rc, err := file.Open()
-			if err != nil {
-				return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
-			}
-			defer rc.Close()
-
-			content, err := io.ReadAll(rc)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
-			}
-
-			return content, nil
-		}
-	}
-
-	return nil, fmt.Errorf("file %s not found in .vsix archive", filePath)
-}
-
+// DetectContentType returns the content type of the file at filePath,
+// sniffed from its first 512 bytes.
 func (fu *FileUtils) DetectContentType(filePath string) string {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -50,19 +26,24 @@ func (fu *FileUtils) DetectContentType(filePath string) string {
 	defer file.Close()
 
 	buffer := make([]byte, 512)
-	bytesRead, err := file.Read(buffer)
-	if err != nil && err != io.EOF {
+	bytesRead, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return OctetStreamContentType
 	}
 
-	contentType := detectContentType(buffer[:bytesRead])
+	return fu.SniffContentType(buffer[:bytesRead])
+}
 
-	if strings.Contains(string(buffer[:bytesRead]), "<?xml") ||
-		strings.Contains(string(buffer[:bytesRead]), "<svg") {
+// SniffContentType returns the content type of a file from its first bytes,
+// for files whose name does not tell. SVG documents are recognized by their
+// XML declaration or <svg> tag.
+func (fu *FileUtils) SniffContentType(data []byte) string {
+	if strings.Contains(string(data), "<?xml") ||
+		strings.Contains(string(data), "<svg") {
 		return "image/svg+xml; charset=utf-8"
 	}
 
-	return contentType
+	return detectContentType(data)
 }
 
 func (fu *FileUtils) GetMimeTypeByExtension(filename string) string {
@@ -112,34 +93,24 @@ func (fu *FileUtils) FileExists(filePath string) bool {
 	return !os.IsNotExist(err)
 }
 
-func detectContentType(data []byte) string {
-	if len(data) == 0 {
-		return OctetStreamContentType
-	}
-
-	if len(data) >= 2 {
-		switch {
-		case data[0] == 0xFF && data[1] == 0xD8:
-			return "image/jpeg"
-		case data[0] == 0x89 && data[1] == 0x50 && data[2] == 0x4E && data[3] == 0x47:
-			return "image/png"
-		case data[0] == 0x47 && data[1] == 0x49 && data[2] == 0x46:
-			return "image/gif"
-		}
+// SHA256 returns the hex-encoded SHA-256 checksum of the file at filePath.
+func (fu *FileUtils) SHA256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
 	}
+	defer file.Close()
 
-	if isText(data) {
-		return "text/plain; charset=utf-8"
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
 	}
-
-	return OctetStreamContentType
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-func isText(data []byte) bool {
-	for _, b := range data {
-		if b < 0x20 && b != 0x09 && b != 0x0A && b != 0x0D {
-			return false
-		}
+func detectContentType(data []byte) string {
+	if len(data) == 0 {
+		return OctetStreamContentType
 	}
-	return true
+	return http.DetectContentType(data)
 }
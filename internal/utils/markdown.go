@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdownRenderer renders GitHub-flavored markdown. Raw HTML and unsafe link
+// schemes (e.g. javascript:) are dropped, so the output is safe to embed.
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+)
+
+func RenderMarkdown(source []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdownRenderer.Convert(source, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
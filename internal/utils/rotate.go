@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingWriter is an io.Writer that writes to a file and rotates it once it
+// grows past maxSize, keeping at most maxBackups old files no older than maxAge.
+type RotatingWriter struct {
+	mu         sync.Mutex
+	filename   string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func NewRotatingWriter(filename string, maxSizeMB, maxAgeDays, maxBackups int) (*RotatingWriter, error) {
+	w := &RotatingWriter{
+		filename:   filename,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		maxBackups: maxBackups,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *RotatingWriter) open() error {
+	file, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *RotatingWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+		w.file = nil
+	}
+
+	ext := filepath.Ext(w.filename)
+	base := strings.TrimSuffix(w.filename, ext)
+	backupName := fmt.Sprintf("%s-%s%s", base, time.Now().Format(backupTimeFormat), ext)
+
+	if err := os.Rename(w.filename, backupName); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.removeOldBackups(base, ext)
+	return nil
+}
+
+func (w *RotatingWriter) removeOldBackups(base, ext string) {
+	backups, err := listBackups(base, ext)
+	if err != nil {
+		return
+	}
+
+	// Backup names embed a sortable timestamp, so newest come last.
+	sort.Strings(backups)
+
+	for i, backup := range backups {
+		expired := false
+		if w.maxBackups > 0 && i < len(backups)-w.maxBackups {
+			expired = true
+		}
+		if w.maxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && time.Since(info.ModTime()) > w.maxAge {
+				expired = true
+			}
+		}
+		if expired {
+			os.Remove(backup)
+		}
+	}
+}
+
+// listBackups returns the backups rotate made of base+ext. Only names whose
+// middle part is a backup timestamp count, so that siblings such as
+// littlevsx-access.log next to littlevsx.log are left alone.
+func listBackups(base, ext string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(base) + "-"
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) || len(name) < len(prefix)+len(ext) {
+			continue
+		}
+		stamp := name[len(prefix) : len(name)-len(ext)]
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(filepath.Dir(base), name))
+	}
+	return backups, nil
+}
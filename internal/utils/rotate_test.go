@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestRotatingWriterKeepsSiblings rotates a log with maxBackups 1 next to
+// files sharing its name prefix, which must survive the cleanup.
+func TestRotatingWriterKeepsSiblings(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "littlevsx.log")
+
+	siblings := []string{"littlevsx-access.log", "littlevsx-old.log", "littlevsx-2024.log", "littlevsx-.log"}
+	for _, name := range siblings {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("keep"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldBackup := "littlevsx-" + time.Now().Add(-time.Hour).Format(backupTimeFormat) + ".log"
+	if err := os.WriteFile(filepath.Join(dir, oldBackup), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewRotatingWriter(filename, 1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.maxSize = 10
+	for range 3 {
+		if _, err := w.Write([]byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	for _, name := range siblings {
+		if !slices.Contains(names, name) {
+			t.Errorf("%s was deleted, have %v", name, names)
+		}
+	}
+	if slices.Contains(names, oldBackup) {
+		t.Errorf("old backup %s was kept beyond maxBackups, have %v", oldBackup, names)
+	}
+
+	backups, err := listBackups(filepath.Join(dir, "littlevsx"), ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Errorf("got backups %v, want 1", backups)
+	}
+}
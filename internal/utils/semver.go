@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two semantic versions and returns -1, 0 or 1.
+// Build metadata is ignored and a release ranks above its pre-releases,
+// e.g. 1.2.0-beta.2 < 1.2.0 < 1.10.0.
+func CompareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+
+	if c := compareIdentifiers(strings.Split(aCore, "."), strings.Split(bCore, "."), true); c != 0 {
+		return c
+	}
+
+	switch {
+	case aPre == "" && bPre == "":
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+
+	return compareIdentifiers(strings.Split(aPre, "."), strings.Split(bPre, "."), false)
+}
+
+// IsPreReleaseVersion reports whether the version carries a pre-release tag.
+func IsPreReleaseVersion(version string) bool {
+	_, pre := splitVersion(version)
+	return pre != ""
+}
+
+func splitVersion(version string) (string, string) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+	if i := strings.Index(version, "-"); i >= 0 {
+		return version[:i], version[i+1:]
+	}
+	return version, ""
+}
+
+func compareIdentifiers(a, b []string, padWithZero bool) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y string
+		if i < len(a) {
+			x = a[i]
+		} else if padWithZero {
+			x = "0"
+		} else {
+			return -1
+		}
+		if i < len(b) {
+			y = b[i]
+		} else if padWithZero {
+			y = "0"
+		} else {
+			return 1
+		}
+
+		if c := compareIdentifier(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareIdentifier(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
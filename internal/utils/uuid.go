@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// NamespaceLittleVSX is the namespace of the name-based UUIDs LittleVSX
+// derives for publishers and extensions. It is the UUIDv5 of
+// "https://github.com/i2Phoenix/LittleVSX" in the URL namespace.
+var NamespaceLittleVSX = [16]byte{
+	0x8d, 0x9c, 0x3f, 0x04, 0x9e, 0x6e, 0x53, 0xea,
+	0x8c, 0xdf, 0x5f, 0x8b, 0x71, 0x2f, 0x7a, 0xdd,
+}
+
+var (
+	uuidMu     sync.Mutex
+	uuidSource io.Reader = rand.Reader
+)
+
+// SetUUIDSource replaces the source of random bytes used by NewUUID and
+// returns a function restoring the previous one. Tests use it with a seeded
+// reader to get reproducible UUIDs.
+func SetUUIDSource(r io.Reader) (restore func()) {
+	uuidMu.Lock()
+	defer uuidMu.Unlock()
+
+	previous := uuidSource
+	uuidSource = r
+	return func() {
+		uuidMu.Lock()
+		defer uuidMu.Unlock()
+		uuidSource = previous
+	}
+}
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() string {
+	var b [16]byte
+
+	uuidMu.Lock()
+	_, err := io.ReadFull(uuidSource, b[:])
+	uuidMu.Unlock()
+	if err != nil {
+		return "00000000-0000-0000-0000-000000000000"
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// UUIDv5 returns the name-based (version 5, SHA-1) UUID of name within
+// namespace, so the same name always yields the same UUID.
+func UUIDv5(namespace [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+
+	var b [16]byte
+	copy(b[:], h.Sum(nil))
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IsUUID reports whether s is a UUID in its canonical textual form.
+func IsUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// ExtensionUUID returns the gallery UUID of the extension with the given
+// "publisher.name" ID: extensionID if that already is a UUID, otherwise the
+// UUIDv5 of the lowercased ID.
+func ExtensionUUID(extensionID, id string) string {
+	if IsUUID(extensionID) {
+		return extensionID
+	}
+	return UUIDv5(NamespaceLittleVSX, "extension:"+strings.ToLower(id))
+}
+
+// PublisherUUID returns the gallery UUID of the publisher with the given
+// name, the UUIDv5 of the lowercased name.
+func PublisherUUID(name string) string {
+	return UUIDv5(NamespaceLittleVSX, "publisher:"+strings.ToLower(name))
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUUIDv5(t *testing.T) {
+	dnsNamespace := [16]byte{
+		0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1,
+		0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+	}
+	urlNamespace := [16]byte{
+		0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1,
+		0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+	}
+
+	tests := []struct {
+		name      string
+		namespace [16]byte
+		input     string
+		want      string
+	}{
+		{"RFC 4122 DNS example", dnsNamespace, "www.example.com", "2ed6657d-e927-568b-95e1-2665a8aea6a2"},
+		{"LittleVSX namespace", urlNamespace, "https://github.com/i2Phoenix/LittleVSX", formatUUID(NamespaceLittleVSX)},
+		{"extension", NamespaceLittleVSX, "extension:acme.tool", "9dd848a3-2f07-56a1-9dc3-90c56d36789b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UUIDv5(tt.namespace, tt.input)
+			if got != tt.want {
+				t.Errorf("UUIDv5(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+			if again := UUIDv5(tt.namespace, tt.input); again != got {
+				t.Errorf("UUIDv5(%q) changed from %s to %s", tt.input, got, again)
+			}
+			if got[14] != '5' {
+				t.Errorf("UUIDv5(%q) = %s is not version 5", tt.input, got)
+			}
+		})
+	}
+}
+
+func TestIsUUID(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"9dd848a3-2f07-56a1-9dc3-90c56d36789b", true},
+		{"9DD848A3-2F07-56A1-9DC3-90C56D36789B", true},
+		{"acme.tool", false},
+		{"", false},
+		{"9dd848a32f0756a19dc390c56d36789b", false},
+		{"9dd848a3-2f07-56a1-9dc3-90c56d36789", false},
+		{"{9dd848a3-2f07-56a1-9dc3-90c56d36789b}", false},
+		{"9dd848a3-2f07-56a1-9dc3-90c56d36789g", false},
+	}
+	for _, tt := range tests {
+		if got := IsUUID(tt.s); got != tt.want {
+			t.Errorf("IsUUID(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestExtensionUUID(t *testing.T) {
+	const derived = "9dd848a3-2f07-56a1-9dc3-90c56d36789b"
+	tests := []struct {
+		name        string
+		extensionID string
+		id          string
+		want        string
+	}{
+		{"no extension ID", "", "acme.tool", derived},
+		{"ID stored as extension ID", "acme.tool", "acme.tool", derived},
+		{"ID case is ignored", "", "Acme.Tool", derived},
+		{"gallery UUID is kept", "f1f59ae4-9318-4f3c-a9b5-81b2eaa5f8a5", "acme.tool", "f1f59ae4-9318-4f3c-a9b5-81b2eaa5f8a5"},
+	}
+	for _, tt := range tests {
+		if got := ExtensionUUID(tt.extensionID, tt.id); got != tt.want {
+			t.Errorf("%s: ExtensionUUID(%q, %q) = %s, want %s", tt.name, tt.extensionID, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestPublisherUUID(t *testing.T) {
+	const want = "39522166-cb6d-57cb-acec-8c1a0ecc39a0"
+	for _, name := range []string{"acme", "Acme", "ACME"} {
+		if got := PublisherUUID(name); got != want {
+			t.Errorf("PublisherUUID(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestNewUUIDSeeded(t *testing.T) {
+	seed := bytes.Repeat([]byte{0xff}, 32)
+	restore := SetUUIDSource(bytes.NewReader(seed))
+	first, second := NewUUID(), NewUUID()
+	restore()
+
+	if first != "ffffffff-ffff-4fff-bfff-ffffffffffff" || second != first {
+		t.Errorf("seeded NewUUID = %s, %s", first, second)
+	}
+	if exhausted := func() string {
+		defer SetUUIDSource(strings.NewReader(""))()
+		return NewUUID()
+	}(); exhausted != "00000000-0000-0000-0000-000000000000" {
+		t.Errorf("NewUUID with an exhausted source = %s", exhausted)
+	}
+	if random := NewUUID(); !IsUUID(random) || random[14] != '4' || random == first {
+		t.Errorf("NewUUID after restore = %s, want a random version 4 UUID", random)
+	}
+}